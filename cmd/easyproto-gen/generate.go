@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// generator generates Go code for genFile.
+type generator struct {
+	gf   *genFile
+	opts *options
+
+	// body contains the generated code after imports.
+	body bytes.Buffer
+
+	// needsUnsafe is set to true if the generated code uses unsafe package.
+	needsUnsafe bool
+}
+
+// generate returns formatted Go code for gf.
+func generate(gf *genFile, opts *options) ([]byte, error) {
+	g := &generator{
+		gf:   gf,
+		opts: opts,
+	}
+	for _, e := range gf.enums {
+		g.genEnum(e)
+	}
+	for _, m := range gf.messages {
+		if gf.emitTypes {
+			g.genMessageType(m)
+		}
+		g.genMarshal(m)
+		g.genUnmarshal(m)
+	}
+	if len(gf.messages) > 0 {
+		g.p("var marshalerPool easyproto.MarshalerPool")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by easyproto-gen from %s. DO NOT EDIT.\n\n", strings.Join(gf.sources, ", "))
+	fmt.Fprintf(&out, "package %s\n\n", gf.pkg)
+	if len(gf.messages) > 0 {
+		out.WriteString("import (\n\t\"fmt\"\n")
+		if g.needsUnsafe {
+			out.WriteString("\t\"unsafe\"\n")
+		}
+		out.WriteString("\n\t\"github.com/VictoriaMetrics/easyproto\"\n)\n\n")
+	}
+	out.Write(g.body.Bytes())
+
+	code, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("BUG: cannot format the generated code: %w\n%s", err, out.Bytes())
+	}
+	return code, nil
+}
+
+// p writes a line formatted with the given format and args to the generated code.
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.body, format, args...)
+	g.body.WriteByte('\n')
+}
+
+func (g *generator) genEnum(e *genEnum) {
+	g.p("// %s is generated from %s enum.", e.goName, e.protoName)
+	g.p("type %s int32", e.goName)
+	g.p("")
+	g.p("// %s values.", e.goName)
+	g.p("const (")
+	for _, v := range e.values {
+		g.p("%s %s = %d", v.goName, e.goName, v.number)
+	}
+	g.p(")")
+	g.p("")
+}
+
+func (g *generator) genMessageType(m *genMessage) {
+	for _, o := range m.oneofs {
+		g.genOneofCase(o)
+	}
+	g.p("// %s is generated from %s message.", m.goName, m.protoName)
+	g.p("type %s struct {", m.goName)
+	for _, f := range m.fields {
+		if o := f.oneof; o != nil && o.fields[0] == f {
+			g.p("%s %s", o.goName, o.caseType)
+		}
+		g.p("%s %s", f.goName, f.goFieldType())
+	}
+	g.p("}")
+	g.p("")
+}
+
+// genOneofCase generates the type for the oneof case, whose values are numbers of oneof fields.
+func (g *generator) genOneofCase(o *genOneof) {
+	g.p("// %s is the case for %s oneof. It contains the number of the set oneof field.", o.caseType, o.protoName)
+	g.p("type %s uint32", o.caseType)
+	g.p("")
+	g.p("// %s values.", o.caseType)
+	g.p("const (")
+	g.p("%s %s = 0", o.caseName(nil), o.caseType)
+	for _, f := range o.fields {
+		g.p("%s %s = %d", o.caseName(f), o.caseType, f.num)
+	}
+	g.p(")")
+	g.p("")
+}
+
+func (g *generator) genMarshal(m *genMessage) {
+	g.p("// MarshalProtobuf marshals x into protobuf message, appends this message to dst and returns the result.")
+	g.p("//")
+	g.p("// This function doesn't allocate memory on repeated calls.")
+	if hasMaps(m) {
+		g.p("//")
+		g.p("// Map entries are marshaled in the ascending order of keys. Sorting maps with more than 64 entries allocates memory.")
+	}
+	g.p("func (x *%s) MarshalProtobuf(dst []byte) []byte {", m.goName)
+	g.p("m := marshalerPool.Get()")
+	g.p("x.marshalProtobuf(m.MessageMarshaler())")
+	g.p("dst = m.Marshal(dst)")
+	g.p("marshalerPool.Put(m)")
+	g.p("return dst")
+	g.p("}")
+	g.p("")
+	g.p("func (x *%s) marshalProtobuf(mm *easyproto.MessageMarshaler) {", m.goName)
+	for _, f := range m.fields {
+		switch o := f.oneof; {
+		case o == nil:
+			g.genMarshalField(f)
+		case o.fields[0] == f:
+			g.genMarshalOneof(o)
+		}
+	}
+	g.p("}")
+	g.p("")
+}
+
+func (g *generator) genMarshalField(f *genField) {
+	x := "x." + f.goName
+	switch {
+	case f.mapKey != nil && f.mapKey.kind == easyproto.KindBool:
+		// easyproto.AppendMap doesn't support bool keys, so entries are marshaled in the ascending order of keys here.
+		for _, k := range []string{"false", "true"} {
+			g.p("if v, ok := %s[%s]; ok {", x, k)
+			g.p("mmEntry := mm.AppendMessage(%d)", f.num)
+			g.genMarshalValue("mmEntry", f.mapKey, k)
+			g.genMarshalValue("mmEntry", f.mapValue, "v")
+			g.p("}")
+		}
+	case f.mapKey != nil:
+		// Map entries are marshaled in the ascending order of keys, so the result is deterministic.
+		g.p("easyproto.AppendMap(mm, %d, %s, true, func(mm *easyproto.MessageMarshaler, _ uint32, k %s) {", f.num, x, f.mapKey.goType)
+		g.genMarshalValue("mm", f.mapKey, "k")
+		g.p("}, func(mm *easyproto.MessageMarshaler, _ uint32, v %s) {", f.mapValue.goType)
+		g.genMarshalValue("mm", f.mapValue, "v")
+		g.p("})")
+	case f.repeated && f.packed:
+		g.p("if len(%s) > 0 {", x)
+		if f.needsConversion() {
+			// Packed fields with conversion are generated only for enums, which have int32 underlying type,
+			// so the slice is converted to []int32 without copying.
+			g.needsUnsafe = true
+			x = fmt.Sprintf("*(*[]%s)(unsafe.Pointer(&%s))", kindGoType(f.kind), x)
+		}
+		g.p("mm.Append%s(%d, %s)", kindPackedSuffix(f.kind), f.num, x)
+		g.p("}")
+	case f.repeated && f.isMessage():
+		g.p("for i := range %s {", x)
+		g.genMarshalValue("mm", f, x+"[i]")
+		g.p("}")
+	case f.repeated:
+		g.p("for _, v := range %s {", x)
+		g.genMarshalValue("mm", f, "v")
+		g.p("}")
+	case f.pointer:
+		g.p("if %s != nil {", x)
+		if f.isMessage() {
+			g.genMarshalValue("mm", f, x)
+		} else {
+			g.genMarshalValue("mm", f, "*"+x)
+		}
+		g.p("}")
+	case f.required || f.isMessage():
+		g.genMarshalValue("mm", f, x)
+	default:
+		g.p("if %s {", nonZeroCondition(f, x))
+		g.genMarshalValue("mm", f, x)
+		g.p("}")
+	}
+}
+
+// genMarshalOneof generates the code for marshaling the set field of the oneof o.
+func (g *generator) genMarshalOneof(o *genOneof) {
+	g.p("switch x.%s {", o.goName)
+	for _, f := range o.fields {
+		g.p("case %s:", o.caseName(f))
+		if f.pointer {
+			g.genMarshalField(f)
+		} else {
+			g.genMarshalValue("mm", f, "x."+f.goName)
+		}
+	}
+	g.p("}")
+}
+
+// genMarshalValue generates the code for marshaling a single value of f stored at expr into mm.
+func (g *generator) genMarshalValue(mm string, f *genField, expr string) {
+	switch f.kind {
+	case easyproto.KindMessage:
+		g.p("%s.marshalProtobuf(%s.AppendMessage(%d))", expr, mm, f.num)
+	case easyproto.KindGroup:
+		g.p("%s.marshalProtobuf(%s.AppendGroup(%d))", expr, mm, f.num)
+	default:
+		if f.needsConversion() {
+			expr = fmt.Sprintf("%s(%s)", kindGoType(f.kind), expr)
+		}
+		g.p("%s.Append%s(%d, %s)", mm, kindMethodSuffix(f.kind), f.num, expr)
+	}
+}
+
+// nonZeroCondition returns Go condition, which is true if expr for f contains non-zero value.
+func nonZeroCondition(f *genField, expr string) string {
+	switch f.kind {
+	case easyproto.KindBool:
+		return expr
+	case easyproto.KindString:
+		return expr + ` != ""`
+	case easyproto.KindBytes:
+		return "len(" + expr + ") > 0"
+	default:
+		return expr + " != 0"
+	}
+}
+
+func (g *generator) genUnmarshal(m *genMessage) {
+	g.p("// UnmarshalProtobuf unmarshals x from protobuf message at src.")
+	if reusesMemory(m) {
+		g.p("//")
+		g.p("// Slices, maps and pointers in x are reused, so their previous contents are overwritten.")
+	}
+	if !g.opts.cloneStrings && refersToSrc(m) {
+		g.p("//")
+		g.p("// String and bytes fields in x refer to src, so they change when src changes.")
+	}
+	g.p("func (x *%s) UnmarshalProtobuf(src []byte) (err error) {", m.goName)
+	g.p("// Set default %s values", m.goName)
+	for _, o := range m.oneofs {
+		g.p("x.%s = %s", o.goName, o.caseName(nil))
+	}
+	for _, f := range m.fields {
+		if f.pointer {
+			// Remember the previous pointer, so its value can be reused.
+			g.p("%s := x.%s", prevVarName(f), f.goName)
+		}
+		g.genResetField(f)
+	}
+	g.p("")
+	g.p("// Parse %s message at src", m.goName)
+	g.p("var fc easyproto.FieldContext")
+	g.p("for len(src) > 0 {")
+	g.p("src, err = fc.NextField(src)")
+	g.p("if err != nil {")
+	g.p("return fmt.Errorf(\"cannot read next field in %s message: %%w\", err)", m.protoName)
+	g.p("}")
+	g.p("switch fc.FieldNum {")
+	for _, f := range m.fields {
+		g.p("case %d:", f.num)
+		g.genUnmarshalField(m, f)
+		if o := f.oneof; o != nil {
+			g.p("x.%s = %s", o.goName, o.caseName(f))
+			for _, sibling := range o.fields {
+				if sibling != f {
+					g.genResetField(sibling)
+				}
+			}
+		}
+	}
+	g.p("}")
+	g.p("}")
+	g.p("return nil")
+	g.p("}")
+	g.p("")
+
+	for _, f := range m.fields {
+		if f.mapKey != nil {
+			g.genUnmarshalMapEntry(m, f)
+		}
+	}
+}
+
+// refersToSrc returns true if m contains string or bytes fields, which may refer to the unmarshaled data.
+func refersToSrc(m *genMessage) bool {
+	for _, f := range m.fields {
+		if f.kind == easyproto.KindString || f.kind == easyproto.KindBytes {
+			return true
+		}
+		if f.mapKey != nil && (f.mapValue.kind == easyproto.KindString || f.mapValue.kind == easyproto.KindBytes) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMaps returns true if m contains map fields.
+func hasMaps(m *genMessage) bool {
+	for _, f := range m.fields {
+		if f.mapKey != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// reusesMemory returns true if m contains slices, maps or pointers, which are reused during unmarshaling.
+func reusesMemory(m *genMessage) bool {
+	for _, f := range m.fields {
+		if f.repeated || f.mapKey != nil || f.pointer {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) genResetField(f *genField) {
+	x := "x." + f.goName
+	switch {
+	case f.mapKey != nil:
+		g.p("for k := range %s {", x)
+		g.p("delete(%s, k)", x)
+		g.p("}")
+	case f.repeated:
+		g.p("%s = %s[:0]", x, x)
+	case f.pointer:
+		g.p("%s = nil", x)
+	case f.isMessage():
+		g.p("%s = %s{}", x, f.goType)
+	case f.kind == easyproto.KindBytes:
+		if g.opts.cloneStrings {
+			g.p("%s = %s[:0]", x, x)
+		} else {
+			g.p("%s = nil", x)
+		}
+	case f.kind == easyproto.KindBool:
+		g.p("%s = false", x)
+	case f.kind == easyproto.KindString:
+		g.p("%s = \"\"", x)
+	default:
+		g.p("%s = 0", x)
+	}
+}
+
+func (g *generator) genUnmarshalField(m *genMessage, f *genField) {
+	x := "x." + f.goName
+	name := m.protoName + "." + f.protoName
+	switch {
+	case f.mapKey != nil:
+		g.p("data, ok := fc.MessageData()")
+		g.genCheckOK("cannot read %s entry", name)
+		g.p("k, v, err := %s(data)", mapEntryFuncName(m, f))
+		g.p("if err != nil {")
+		g.p("return fmt.Errorf(\"cannot unmarshal %s entry: %%w\", err)", name)
+		g.p("}")
+		g.p("if %s == nil {", x)
+		g.p("%s = make(%s)", x, f.goFieldType())
+		g.p("}")
+		g.p("%s[k] = v", x)
+	case f.isMessage():
+		g.genReadMessageData(f)
+		g.genCheckOK("cannot read %s data", name)
+		switch {
+		case f.repeated:
+			g.genGrowSlice(x, f.goType)
+			x = fmt.Sprintf("%s[len(%s)-1]", x, x)
+		case f.pointer:
+			g.genReusePointer(f)
+		}
+		g.p("if err := %s.UnmarshalProtobuf(data); err != nil {", x)
+		g.p("return fmt.Errorf(\"cannot unmarshal %s: %%w\", err)", name)
+		g.p("}")
+	case f.repeated && f.kind.IsPackable() && !f.needsConversion():
+		g.p("vs, ok := fc.Unpack%s(%s)", kindPackedSuffix(f.kind), x)
+		g.genCheckOK("cannot read %s", name)
+		g.p("%s = vs", x)
+	case f.repeated && f.kind.IsPackable():
+		g.p("ok := fc.Range%s(func(v %s) bool {", kindPackedSuffix(f.kind), kindGoType(f.kind))
+		g.p("%s = append(%s, %s(v))", x, x, f.goType)
+		g.p("return true")
+		g.p("})")
+		g.genCheckOK("cannot read %s", name)
+	case f.repeated && f.kind == easyproto.KindBytes && g.opts.cloneStrings:
+		g.genReadValue(f, name)
+		if g.opts.poolSlices {
+			g.p("if n := len(%s); n < cap(%s) {", x, x)
+			g.p("%s = %s[:n+1]", x, x)
+			g.p("%s[n] = append(%s[n][:0], v...)", x, x)
+			g.p("} else {")
+			g.p("%s = append(%s, append([]byte(nil), v...))", x, x)
+			g.p("}")
+		} else {
+			g.p("%s = append(%s, append([]byte(nil), v...))", x, x)
+		}
+	case f.repeated:
+		g.genReadValue(f, name)
+		g.p("%s = append(%s, %s)", x, x, g.convertValue(f, "v"))
+	case f.pointer:
+		g.genReadValue(f, name)
+		g.genReusePointer(f)
+		g.p("*%s = %s", x, g.convertValue(f, "v"))
+	case f.kind == easyproto.KindBytes && g.opts.cloneStrings:
+		g.genReadValue(f, name)
+		g.p("%s = append(%s[:0], v...)", x, x)
+	default:
+		g.genReadValue(f, name)
+		g.p("%s = %s", x, g.convertValue(f, "v"))
+	}
+}
+
+// genReusePointer generates the code for setting the pointer field f to the previous pointer, which was set before unmarshaling.
+//
+// The new value is allocated only if the previous pointer is nil.
+func (g *generator) genReusePointer(f *genField) {
+	prev := prevVarName(f)
+	g.p("if %s == nil {", prev)
+	g.p("%s = new(%s)", prev, f.goType)
+	g.p("}")
+	g.p("x.%s = %s", f.goName, prev)
+}
+
+func prevVarName(f *genField) string {
+	return "prev" + f.goName
+}
+
+// genUnmarshalMapEntry generates a function for unmarshaling map entry for the map field f.
+func (g *generator) genUnmarshalMapEntry(m *genMessage, f *genField) {
+	name := m.protoName + "." + f.protoName
+	key, value := f.mapKey, f.mapValue
+	g.p("func %s(src []byte) (key %s, value %s, err error) {", mapEntryFuncName(m, f), key.goType, value.goType)
+	g.p("var fc easyproto.FieldContext")
+	g.p("for len(src) > 0 {")
+	g.p("src, err = fc.NextField(src)")
+	g.p("if err != nil {")
+	g.p("return key, value, fmt.Errorf(\"cannot read next field in %s entry: %%w\", err)", name)
+	g.p("}")
+	g.p("switch fc.FieldNum {")
+	g.p("case 1:")
+	g.genReadValueWithResult(key, name+" key", "return key, value, ")
+	// String keys are always cloned, since map entries cannot be deleted if their keys refer to the modified src.
+	g.p("key = %s", g.convertValueClone(key, "v", true))
+	g.p("case 2:")
+	if value.isMessage() {
+		g.genReadMessageData(value)
+		g.p("if !ok {")
+		g.p("return key, value, fmt.Errorf(\"cannot read %s value data\")", name)
+		g.p("}")
+		g.p("if err := value.UnmarshalProtobuf(data); err != nil {")
+		g.p("return key, value, fmt.Errorf(\"cannot unmarshal %s value: %%w\", err)", name)
+		g.p("}")
+	} else {
+		g.genReadValueWithResult(value, name+" value", "return key, value, ")
+		if value.kind == easyproto.KindBytes && g.opts.cloneStrings {
+			g.p("value = append([]byte(nil), v...)")
+		} else {
+			g.p("value = %s", g.convertValue(value, "v"))
+		}
+	}
+	g.p("}")
+	g.p("}")
+	g.p("return key, value, nil")
+	g.p("}")
+	g.p("")
+}
+
+func mapEntryFuncName(m *genMessage, f *genField) string {
+	return "unmarshal" + strings.ReplaceAll(m.goName, "_", "") + f.goName + "Entry"
+}
+
+func (g *generator) genReadMessageData(f *genField) {
+	if f.kind == easyproto.KindGroup {
+		g.p("data, ok := fc.GroupData()")
+	} else {
+		g.p("data, ok := fc.MessageData()")
+	}
+}
+
+// genReadValue generates the code for reading scalar value for f into v variable.
+func (g *generator) genReadValue(f *genField, name string) {
+	g.genReadValueWithResult(f, name, "return ")
+}
+
+func (g *generator) genReadValueWithResult(f *genField, name, returnPrefix string) {
+	g.p("v, ok := fc.%s()", kindGetterName(f.kind))
+	g.p("if !ok {")
+	g.p("%sfmt.Errorf(\"cannot read %s\")", returnPrefix, name)
+	g.p("}")
+}
+
+func (g *generator) genCheckOK(format, name string) {
+	g.p("if !ok {")
+	g.p("return fmt.Errorf(%q)", fmt.Sprintf(format, name))
+	g.p("}")
+}
+
+// genGrowSlice generates the code for appending an element to the slice at x.
+//
+// The element at the end of the slice is reused if poolSlices option is set, so its nested slices may be reused.
+func (g *generator) genGrowSlice(x, goType string) {
+	if g.opts.poolSlices {
+		g.p("if n := len(%s); n < cap(%s) {", x, x)
+		g.p("%s = %s[:n+1]", x, x)
+		g.p("} else {")
+		g.p("%s = append(%s, %s{})", x, x, goType)
+		g.p("}")
+	} else {
+		g.p("%s = append(%s, %s{})", x, x, goType)
+	}
+}
+
+// convertValue returns Go expression for converting v read from FieldContext into Go value for f.
+func (g *generator) convertValue(f *genField, v string) string {
+	return g.convertValueClone(f, v, g.opts.cloneStrings)
+}
+
+// convertValueClone returns Go expression for converting v read from FieldContext into Go value for f.
+//
+// String values are cloned if cloneStrings is set.
+func (g *generator) convertValueClone(f *genField, v string, cloneStrings bool) string {
+	if f.kind == easyproto.KindString && cloneStrings {
+		// strings.Clone isn't used, since it requires Go 1.20.
+		v = "string(append([]byte(nil), " + v + "...))"
+	}
+	if f.needsConversion() {
+		v = fmt.Sprintf("%s(%s)", f.goType, v)
+	}
+	return v
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGenerateFromProto(t *testing.T) {
+	opts := &options{
+		cloneStrings:    true,
+		pointerOptional: true,
+		poolSlices:      true,
+	}
+	code, err := run("", []string{"timeseries.proto", "legacy.proto"}, []string{"testdata"}, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testGeneratedCode(t, code, "", testProtoHarness)
+}
+
+func TestGenerateFromGoStructs(t *testing.T) {
+	opts := &options{}
+	structsPath := "testdata/structs/structs.go"
+	code, err := run(structsPath, nil, nil, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testGeneratedCode(t, code, structsPath, testStructsHarness)
+}
+
+func TestGenerateSimple(t *testing.T) {
+	f := func(src string, opts *options, linesExpected []string) {
+		t.Helper()
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "foo.proto")
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatalf("cannot write %s: %s", path, err)
+		}
+		code, err := run("", []string{"foo.proto"}, []string{dir}, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for _, line := range linesExpected {
+			if !strings.Contains(string(code), line) {
+				t.Fatalf("missing %q in the generated code:\n%s", line, code)
+			}
+		}
+	}
+
+	// default options
+	f(`syntax = "proto3"; message Foo { string s = 1; optional int32 n = 2; repeated Foo foos = 3; }`, &options{}, []string{
+		"package foo\n",
+		"\tS    string\n",
+		"\tN    int32\n",
+		"\tFoos []Foo\n",
+		"\t\tmm.AppendString(1, x.S)\n",
+		"\t\t\tx.S = v\n",
+		"\t\t\tx.Foos = append(x.Foos, Foo{})\n",
+		"// String and bytes fields in x refer to src, so they change when src changes.\n",
+	})
+
+	// oneofs and repeated enums
+	f(`syntax = "proto3"; enum E { A = 0; } message Foo { oneof v { int32 n = 1; Foo foo = 2; } repeated E es = 3; }`, &options{pointerOptional: true}, []string{
+		"\tFooVCase_None FooVCase = 0\n",
+		"\tFooVCase_N    FooVCase = 1\n",
+		"\tVCase FooVCase\n",
+		"\tN     int32\n",
+		"\tFoo   *Foo\n",
+		"\tEs    []E\n",
+		"\tcase FooVCase_N:\n\t\tmm.AppendInt32(1, x.N)\n",
+		"\t\t\tx.VCase = FooVCase_Foo\n\t\t\tx.N = 0\n",
+		"\t\tmm.AppendInt32s(3, *(*[]int32)(unsafe.Pointer(&x.Es)))\n",
+		"\t\t\t\tx.Es = append(x.Es, E(v))\n",
+	})
+
+	// all the options
+	f(`syntax = "proto3"; package a.b; message Foo { string s = 1; optional int32 n = 2; repeated Foo foos = 3; }`, &options{
+		pkg:             "custom",
+		cloneStrings:    true,
+		pointerOptional: true,
+		poolSlices:      true,
+	}, []string{
+		"package custom\n",
+		"\tN    *int32\n",
+		"\t\t\tx.S = string(append([]byte(nil), v...))\n",
+		"\tprevN := x.N\n",
+		"\t\t\tx.N = prevN\n",
+		"\t\t\t*x.N = v\n",
+		"\t\t\tif n := len(x.Foos); n < cap(x.Foos) {\n",
+	})
+}
+
+func TestRunFailure(t *testing.T) {
+	f := func(goStructsPath string, protoNames []string, opts *options, errExpected string) {
+		t.Helper()
+
+		_, err := run(goStructsPath, protoNames, []string{"testdata"}, opts)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("unexpected error; got %q; want it to contain %q", err, errExpected)
+		}
+	}
+
+	f("", nil, &options{}, "missing .proto files")
+	f("testdata/structs/structs.go", []string{"timeseries.proto"}, &options{}, "cannot be mixed")
+	f("testdata/structs/structs.go", nil, &options{pointerOptional: true}, "-pointer-optional cannot be used")
+	f("", []string{"missing.proto"}, &options{}, "cannot find")
+}
+
+// testGeneratedCode compiles the generated code together with the harness test in a temporary module and runs the harness test.
+//
+// structsPath is the path to Go file with structs the code was generated for. It is empty for the code generated from .proto files.
+func testGeneratedCode(t *testing.T, code []byte, structsPath, harness string) {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("skipping test, which runs go toolchain, in short mode")
+	}
+	repoRoot, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatalf("cannot obtain repository root: %s", err)
+	}
+
+	dir := t.TempDir()
+	goMod := "module example.com/gentest\n\ngo 1.18\n\n" +
+		"require github.com/VictoriaMetrics/easyproto v0.0.0\n\n" +
+		"replace github.com/VictoriaMetrics/easyproto => " + repoRoot + "\n"
+	files := map[string]string{
+		"go.mod":          goMod,
+		"generated.go":    string(code),
+		"harness_test.go": harness,
+	}
+	if structsPath != "" {
+		data, err := os.ReadFile(structsPath)
+		if err != nil {
+			t.Fatalf("cannot read %s: %s", structsPath, err)
+		}
+		files["structs.go"] = string(data)
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("cannot write %s: %s", path, err)
+		}
+	}
+
+	cmd := exec.Command(filepath.Join(runtime.GOROOT(), "bin", "go"), "test", "-count=1", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated code test failed: %s\n%s\ngenerated code:\n%s", err, out, code)
+	}
+}
+
+const testProtoHarness = `package testdata
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+func TestRoundTrip(t *testing.T) {
+	flag := true
+	ts := &Timeseries{
+		Name: "foo",
+		Samples: []TimeseriesSample{
+			{Value: 1.5, Timestamp: 10},
+			{Value: -2, Timestamp: -20},
+		},
+		Labels: map[string]Label{
+			"a": {Value: "b", Next: &Label{Value: "c"}},
+			"d": {},
+		},
+		Ids:         []uint32{1, 2, 300},
+		Deltas:      []int64{-1, 0, 1 << 40},
+		Status:      Status_OK,
+		ValueCase:   TimeseriesValueCase_DoubleValue,
+		DoubleValue: 1.5,
+		Flag:        &flag,
+		Statuses:    []Status{Status_FAILED, Status_UNKNOWN},
+		Names:       map[int32]string{-1: "minus one", 5: ""},
+		First:       &TimeseriesSample{Value: 3},
+		Blobs:       [][]byte{[]byte("x"), []byte("yz")},
+		Tags:        []string{"t1", "", "t3"},
+		F64:         1 << 63,
+		Sf32:        -5,
+		Ratio:       0.25,
+		U64:         1 << 60,
+	}
+	data := ts.MarshalProtobuf(nil)
+
+	var result Timeseries
+	for i := 0; i < 3; i++ {
+		if err := result.UnmarshalProtobuf(data); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(&result, ts) {
+			t.Fatalf("unexpected result\ngot\n%+v\nwant\n%+v", &result, ts)
+		}
+	}
+
+	// Strings must be cloned
+	for i := range data {
+		data[i] = 0
+	}
+	if result.Name != "foo" || result.Tags[2] != "t3" || string(result.Blobs[1]) != "yz" {
+		t.Fatalf("unmarshaled values must not refer to the source data")
+	}
+
+	// Unmarshal empty message
+	if err := result.UnmarshalProtobuf(nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Name != "" || len(result.Samples) != 0 || len(result.Labels) != 0 || result.Flag != nil || result.First != nil {
+		t.Fatalf("unexpected result for empty message: %+v", &result)
+	}
+	if result.ValueCase != TimeseriesValueCase_None || result.DoubleValue != 0 {
+		t.Fatalf("unexpected oneof for empty message: %+v", &result)
+	}
+}
+
+func TestOneof(t *testing.T) {
+	// Only the field selected by the oneof case must be marshaled
+	ts := &Timeseries{
+		ValueCase:   TimeseriesValueCase_BytesValue,
+		DoubleValue: 1.5,
+	}
+	data := ts.MarshalProtobuf(nil)
+	var fc easyproto.FieldContext
+	if ok, err := fc.FieldByNum(data, 7); err != nil || ok {
+		t.Fatalf("unexpected double_value field; ok=%v, err=%v", ok, err)
+	}
+	if ok, err := fc.FieldByNum(data, 8); err != nil || !ok {
+		t.Fatalf("missing empty bytes_value field; ok=%v, err=%v", ok, err)
+	}
+	var result Timeseries
+	if err := result.UnmarshalProtobuf(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.ValueCase != TimeseriesValueCase_BytesValue || len(result.BytesValue) != 0 || result.DoubleValue != 0 {
+		t.Fatalf("unexpected result: %+v", &result)
+	}
+
+	// The last oneof field wins
+	var m easyproto.Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendBytes(8, []byte("foo"))
+	mm.AppendDouble(7, 2.5)
+	data = m.Marshal(nil)
+	if err := result.UnmarshalProtobuf(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.ValueCase != TimeseriesValueCase_DoubleValue || result.DoubleValue != 2.5 || len(result.BytesValue) != 0 {
+		t.Fatalf("unexpected result: %+v", &result)
+	}
+}
+
+func TestWireCompatibility(t *testing.T) {
+	// Marshal the message with easyproto directly, using non-default encodings.
+	var m easyproto.Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendUint32s(4, []uint32{1, 2})
+	mm.AppendUint32(4, 3)
+	mm.AppendSint64(5, -7)
+	mm.AppendInt32(6, 2)
+	mm.AppendBool(9, false)
+	mmSample := mm.AppendMessage(12)
+	mmSample.AppendDouble(1, 1.5)
+	mmSample.AppendString(100, "unknown field")
+	data := m.Marshal(nil)
+
+	var ts Timeseries
+	if err := ts.UnmarshalProtobuf(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ts.Name != "foo" || !reflect.DeepEqual(ts.Ids, []uint32{1, 2, 3}) || !reflect.DeepEqual(ts.Deltas, []int64{-7}) {
+		t.Fatalf("unexpected result: %+v", &ts)
+	}
+	if ts.Status != Status_FAILED || ts.Flag == nil || *ts.Flag || ts.First == nil || ts.First.Value != 1.5 {
+		t.Fatalf("unexpected result: %+v", &ts)
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	name := "bar"
+	l := &Legacy{
+		Id:     0,
+		Name:   &name,
+		Values: []int64{1, -2},
+		Item: []LegacyItem{
+			{Key: &name},
+			{},
+		},
+	}
+	data := l.MarshalProtobuf(nil)
+
+	// Required field must be marshaled even if it is zero
+	var fc easyproto.FieldContext
+	if ok, err := fc.FieldByNum(data, 1); err != nil || !ok {
+		t.Fatalf("missing required field; ok=%v, err=%v", ok, err)
+	}
+
+	var result Legacy
+	if err := result.UnmarshalProtobuf(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(&result, l) {
+		t.Fatalf("unexpected result\ngot\n%+v\nwant\n%+v", &result, l)
+	}
+}
+`
+
+const testStructsHarness = `package structs
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	offset := int64(-123)
+	ts := &Timeseries{
+		Name: "foo",
+		Samples: []Sample{
+			{Value: 1.5, Timestamp: -10},
+			{},
+		},
+		Labels:    map[string]string{"a": "b", "": ""},
+		IDs:       []uint32{1, 2, 3},
+		Status:    2,
+		Statuses:  []Status{1, 0, 3},
+		Offset:    &offset,
+		Data:      []byte("data"),
+		Meta:      &Meta{Host: "localhost", Port: 8080},
+		Group:     Meta{Host: "group"},
+		Timestamp: -1,
+		Counts:    map[uint32]int64{1: -1, 2: 0},
+		Flags:     map[bool]string{true: "yes", false: "no"},
+	}
+	data := ts.MarshalProtobuf(nil)
+
+	var result Timeseries
+	for i := 0; i < 3; i++ {
+		if err := result.UnmarshalProtobuf(data); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(&result, ts) {
+			t.Fatalf("unexpected result\ngot\n%+v\nwant\n%+v", &result, ts)
+		}
+	}
+}
+
+func TestMapKeysClone(t *testing.T) {
+	// Map keys must not refer to the unmarshaled data, since otherwise map lookups fail after the data is changed.
+	var data []byte
+	var result Timeseries
+	for _, key := range []string{"a", "b", "c"} {
+		ts := &Timeseries{
+			Labels: map[string]string{key: "value"},
+		}
+		data = ts.MarshalProtobuf(data[:0])
+		if err := result.UnmarshalProtobuf(data); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for i := range data {
+			data[i] = 0
+		}
+		if _, ok := result.Labels[key]; !ok || len(result.Labels) != 1 {
+			t.Fatalf("cannot find %q key in the unmarshaled map %q", key, result.Labels)
+		}
+	}
+}
+
+func TestMapDeterministic(t *testing.T) {
+	ts := &Timeseries{
+		Labels: make(map[string]string),
+		Counts: make(map[uint32]int64),
+		Flags:  map[bool]string{true: "yes", false: "no"},
+	}
+	for i := 0; i < 100; i++ {
+		ts.Labels[fmt.Sprintf("key_%d", i)] = "value"
+		ts.Counts[uint32(i)] = int64(i)
+	}
+	dataExpected := ts.MarshalProtobuf(nil)
+	for i := 0; i < 10; i++ {
+		data := ts.MarshalProtobuf(nil)
+		if !bytes.Equal(data, dataExpected) {
+			t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", data, dataExpected)
+		}
+	}
+}
+
+func TestZeroAlloc(t *testing.T) {
+	f := func(src, result interface {
+		MarshalProtobuf(dst []byte) []byte
+		UnmarshalProtobuf(src []byte) error
+	}) {
+		t.Helper()
+
+		var data []byte
+		n := testing.AllocsPerRun(100, func() {
+			data = src.MarshalProtobuf(data[:0])
+			if err := result.UnmarshalProtobuf(data); err != nil {
+				panic(err)
+			}
+		})
+		if n != 0 {
+			t.Fatalf("unexpected number of allocations; got %v; want 0", n)
+		}
+		if !reflect.DeepEqual(result, src) {
+			t.Fatalf("unexpected result\ngot\n%+v\nwant\n%+v", result, src)
+		}
+	}
+
+	// flat message
+	f(&Sample{Value: 1.5, Timestamp: 123}, &Sample{})
+
+	// nested messages, repeated messages, maps and pointers.
+	// Maps with string keys aren't checked, since their keys are cloned during unmarshaling.
+	offset := int64(-123)
+	f(&Timeseries{
+		Name: "foo",
+		Samples: []Sample{
+			{Value: 1.5, Timestamp: -10},
+			{Value: 2},
+		},
+		Counts:    map[uint32]int64{1: -1, 2: 0, 3: 1 << 40},
+		Flags:     map[bool]string{true: "yes"},
+		IDs:       []uint32{1, 2, 3},
+		Statuses:  []Status{1, 0, 3},
+		Offset:    &offset,
+		Data:      []byte("data"),
+		Meta:      &Meta{Host: "localhost", Port: 8080},
+		Group:     Meta{Host: "group"},
+		Timestamp: -1,
+	}, &Timeseries{})
+}
+`
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// fromGoFile parses Go file at path and converts structs with `proto` field tags into genFile.
+//
+// The tag has the following format: `proto:"<fieldNum>[,<kind>][,packed]"`, where <kind> is the protobuf kind
+// such as sint64 or fixed32. The kind is detected from the Go type if it is missing.
+// Fields without `proto` tag are ignored.
+func fromGoFile(path string, opts *options) (*genFile, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect structs with proto tags at first, since they may refer to each other.
+	var structs []*ast.TypeSpec
+	structNames := make(map[string]bool)
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || !hasProtoTags(st) {
+				continue
+			}
+			structs = append(structs, ts)
+			structNames[ts.Name.Name] = true
+		}
+	}
+	if len(structs) == 0 {
+		return nil, fmt.Errorf("%s: cannot find structs with `proto` field tags", path)
+	}
+
+	gf := &genFile{
+		pkg:     opts.pkg,
+		sources: []string{path},
+	}
+	if gf.pkg == "" {
+		gf.pkg = file.Name.Name
+	}
+	for _, ts := range structs {
+		gm, err := newGoGenMessage(ts, structNames)
+		if err != nil {
+			pos := fset.Position(ts.Pos())
+			return nil, fmt.Errorf("%s: %w", pos, err)
+		}
+		gf.messages = append(gf.messages, gm)
+	}
+	return gf, nil
+}
+
+func hasProtoTags(st *ast.StructType) bool {
+	for _, f := range st.Fields.List {
+		if _, ok := getProtoTag(f); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func getProtoTag(f *ast.Field) (string, bool) {
+	if f.Tag == nil {
+		return "", false
+	}
+	tag, err := strconv.Unquote(f.Tag.Value)
+	if err != nil {
+		return "", false
+	}
+	return reflect.StructTag(tag).Lookup("proto")
+}
+
+func newGoGenMessage(ts *ast.TypeSpec, structNames map[string]bool) (*genMessage, error) {
+	gm := &genMessage{
+		goName:    ts.Name.Name,
+		protoName: ts.Name.Name,
+	}
+	nums := make(map[uint32]string)
+	st := ts.Type.(*ast.StructType)
+	for _, f := range st.Fields.List {
+		tag, ok := getProtoTag(f)
+		if !ok {
+			continue
+		}
+		if len(f.Names) != 1 {
+			return nil, fmt.Errorf("struct %s must have exactly one field name per `proto` tag", gm.goName)
+		}
+		name := f.Names[0].Name
+		gfield, err := newGoGenField(name, f.Type, tag, structNames)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use %s.%s field: %w", gm.goName, name, err)
+		}
+		if prev, ok := nums[gfield.num]; ok {
+			return nil, fmt.Errorf("fields %s.%s and %s.%s have the same number %d", gm.goName, prev, gm.goName, name, gfield.num)
+		}
+		nums[gfield.num] = name
+		gm.fields = append(gm.fields, gfield)
+	}
+	return gm, nil
+}
+
+// parsedProtoTag contains parsed `proto` tag value.
+type parsedProtoTag struct {
+	num    uint32
+	kind   easyproto.Kind
+	packed bool
+}
+
+func parseProtoTag(tag string) (*parsedProtoTag, error) {
+	parts := strings.Split(tag, ",")
+	num, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || num == 0 || num > 1<<29-1 {
+		return nil, fmt.Errorf("invalid field number %q in `proto` tag; it must be in the range [1..%d]", parts[0], 1<<29-1)
+	}
+	pt := &parsedProtoTag{
+		num: uint32(num),
+	}
+	for _, part := range parts[1:] {
+		if part == "packed" {
+			pt.packed = true
+			continue
+		}
+		k, ok := kindByName(part)
+		if !ok {
+			return nil, fmt.Errorf("unknown option %q in `proto` tag", part)
+		}
+		pt.kind = k
+	}
+	return pt, nil
+}
+
+func newGoGenField(name string, typ ast.Expr, tag string, structNames map[string]bool) (*genField, error) {
+	pt, err := parseProtoTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	gfield := &genField{
+		goName:    name,
+		protoName: name,
+		num:       pt.num,
+		packed:    pt.packed,
+	}
+
+	switch t := typ.(type) {
+	case *ast.MapType:
+		if pt.kind != 0 || pt.packed {
+			return nil, fmt.Errorf("map fields cannot have kind or packed options")
+		}
+		key, err := newGoGenValue(t.Key, 0, structNames)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported map key: %w", err)
+		}
+		if key.isMessage() || key.kind == easyproto.KindBytes || key.kind == easyproto.KindDouble || key.kind == easyproto.KindFloat {
+			return nil, fmt.Errorf("unsupported map key type %s", key.goType)
+		}
+		value, err := newGoGenValue(t.Value, 0, structNames)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported map value: %w", err)
+		}
+		key.num, key.required = 1, true
+		value.num, value.required = 2, true
+		gfield.kind = easyproto.KindMessage
+		gfield.mapKey = key
+		gfield.mapValue = value
+		return gfield, nil
+	case *ast.ArrayType:
+		if t.Len == nil && !isByteIdent(t.Elt) {
+			gfield.repeated = true
+			typ = t.Elt
+		}
+	case *ast.StarExpr:
+		gfield.pointer = true
+		typ = t.X
+	}
+
+	v, err := newGoGenValue(typ, pt.kind, structNames)
+	if err != nil {
+		return nil, err
+	}
+	gfield.kind = v.kind
+	gfield.goType = v.goType
+	if gfield.packed {
+		if !gfield.repeated || !gfield.kind.IsPackable() {
+			return nil, fmt.Errorf("packed option can be used only for repeated fields with scalar numeric kinds")
+		}
+		if gfield.needsConversion() {
+			return nil, fmt.Errorf("packed field must have []%s type instead of []%s", kindGoType(gfield.kind), gfield.goType)
+		}
+	}
+	if gfield.pointer && gfield.kind == easyproto.KindBytes {
+		return nil, fmt.Errorf("pointers to []byte aren't supported; use nil []byte for missing values instead")
+	}
+	return gfield, nil
+}
+
+// newGoGenValue returns genField with kind and goType set for Go type typ.
+//
+// kind is the explicitly specified kind. It is detected from typ if it is zero.
+func newGoGenValue(typ ast.Expr, kind easyproto.Kind, structNames map[string]bool) (*genField, error) {
+	if _, ok := typ.(*ast.StarExpr); ok {
+		return nil, fmt.Errorf("unsupported type %s", types.ExprString(typ))
+	}
+	goType := types.ExprString(typ)
+	if goType == "[]byte" || goType == "[]uint8" {
+		goType = "[]byte"
+	}
+	if kind == 0 {
+		switch {
+		case structNames[goType]:
+			kind = easyproto.KindMessage
+		case goType == "[]byte":
+			kind = easyproto.KindBytes
+		default:
+			kind = defaultGoKind(goType)
+			if kind == 0 {
+				return nil, fmt.Errorf("cannot detect protobuf kind for Go type %s; specify it explicitly in `proto` tag", goType)
+			}
+		}
+	}
+	v := &genField{
+		kind:   kind,
+		goType: goType,
+	}
+	if v.isMessage() {
+		if !structNames[goType] {
+			return nil, fmt.Errorf("%s kind requires struct with `proto` tags; got %s", kind, goType)
+		}
+		return v, nil
+	}
+	if structNames[goType] {
+		return nil, fmt.Errorf("%s kind cannot be used for struct %s", kind, goType)
+	}
+	if v.needsConversion() && (isBuiltinType(goType) || kind == easyproto.KindBytes) {
+		return nil, fmt.Errorf("%s kind requires %s Go type; got %s", kind, kindGoType(kind), goType)
+	}
+	return v, nil
+}
+
+// defaultGoKind returns the default protobuf kind for the given builtin Go type.
+func defaultGoKind(goType string) easyproto.Kind {
+	switch goType {
+	case "int32":
+		return easyproto.KindInt32
+	case "int64":
+		return easyproto.KindInt64
+	case "uint32":
+		return easyproto.KindUint32
+	case "uint64":
+		return easyproto.KindUint64
+	case "bool":
+		return easyproto.KindBool
+	case "float64":
+		return easyproto.KindDouble
+	case "float32":
+		return easyproto.KindFloat
+	case "string":
+		return easyproto.KindString
+	default:
+		return 0
+	}
+}
+
+func isBuiltinType(goType string) bool {
+	return types.Universe.Lookup(goType) != nil
+}
+
+func isByteIdent(expr ast.Expr) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && (id.Name == "byte" || id.Name == "uint8")
+}
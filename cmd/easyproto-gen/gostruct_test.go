@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFromGoFileFailure(t *testing.T) {
+	f := func(src, errExpected string) {
+		t.Helper()
+
+		path := filepath.Join(t.TempDir(), "foo.go")
+		if err := os.WriteFile(path, []byte("package foo\n\n"+src), 0o644); err != nil {
+			t.Fatalf("cannot write %s: %s", path, err)
+		}
+		_, err := fromGoFile(path, &options{})
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("unexpected error; got %q; want it to contain %q", err, errExpected)
+		}
+	}
+
+	// invalid Go code
+	f("type Foo struct {", "expected")
+
+	// missing proto tags
+	f("type Foo struct { A int32 }", "cannot find structs with `proto` field tags")
+
+	// invalid tags
+	f("type Foo struct { A int32 `proto:\"0\"` }", "invalid field number")
+	f("type Foo struct { A int32 `proto:\"abc\"` }", "invalid field number")
+	f("type Foo struct { A int32 `proto:\"1,foobar\"` }", `unknown option "foobar"`)
+
+	// duplicate field numbers
+	f("type Foo struct { A int32 `proto:\"1\"`; B int32 `proto:\"1\"` }", "have the same number 1")
+
+	// multiple names per field
+	f("type Foo struct { A, B int32 `proto:\"1\"` }", "exactly one field name")
+
+	// unknown kind for Go type
+	f("type Foo struct { A int `proto:\"1\"` }", "cannot detect protobuf kind for Go type int")
+	f("type Foo struct { A Bar `proto:\"1\"` }", "cannot detect protobuf kind for Go type Bar")
+
+	// incompatible kind
+	f("type Foo struct { A int64 `proto:\"1,int32\"` }", "int32 kind requires int32 Go type; got int64")
+	f("type Foo struct { A string `proto:\"1,message\"` }", "message kind requires struct with `proto` tags")
+	f("type Foo struct { A Foo `proto:\"1,int32\"` }", "int32 kind cannot be used for struct Foo")
+
+	// invalid packed option
+	f("type Foo struct { A int32 `proto:\"1,packed\"` }", "packed option can be used only for repeated fields")
+	f("type Foo struct { A []string `proto:\"1,packed\"` }", "packed option can be used only for repeated fields")
+	f("type Bar int32\ntype Foo struct { A []Bar `proto:\"1,enum,packed\"` }", "packed field must have []int32 type instead of []Bar")
+
+	// unsupported types
+	f("type Foo struct { A *[]byte `proto:\"1\"` }", "pointers to []byte aren't supported")
+	f("type Foo struct { A []*Foo `proto:\"1\"` }", "unsupported type *Foo")
+	f("type Foo struct { A map[float64]string `proto:\"1\"` }", "unsupported map key type float64")
+	f("type Foo struct { A map[string]int32 `proto:\"1,sint32\"` }", "map fields cannot have kind or packed options")
+}
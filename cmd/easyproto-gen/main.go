@@ -0,0 +1,136 @@
+// Command easyproto-gen generates easyproto-based marshaling and unmarshaling code.
+//
+// It generates MarshalProtobuf and UnmarshalProtobuf methods, which use easyproto.MarshalerPool,
+// easyproto.MessageMarshaler and easyproto.FieldContext in the same way as the hand-written code
+// from easyproto examples. The generated UnmarshalProtobuf reuses slices, maps and pointers from the previous call,
+// so it doesn't allocate memory when it is repeatedly called for messages with the same set of fields
+// unless -clone-strings option is set. Pointer fields, which are missing in the unmarshaled message, are set to nil,
+// so their values are allocated again when they appear in the next messages. String keys of maps are always cloned,
+// since map lookups fail if the keys refer to the changed source data.
+//
+// Map entries are marshaled in the ascending order of keys, so the generated MarshalProtobuf returns deterministic results.
+//
+// The code can be generated either from .proto files:
+//
+//	easyproto-gen -I=proto -o=timeseries.pb.go timeseries.proto
+//
+// or from Go structs with `proto` field tags:
+//
+//	easyproto-gen -go-structs=timeseries.go -o=timeseries_easyproto.go
+//
+// The `proto` tag has the following format: `proto:"<fieldNum>[,<kind>][,packed]"`, for example:
+//
+//	type Sample struct {
+//		Value     float64 `proto:"1"`
+//		Timestamp int64   `proto:"2,sint64"`
+//	}
+//
+// Run `easyproto-gen -help` for the list of available options.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	goStructs       = flag.String("go-structs", "", "Path to Go file with structs containing `proto` field tags. The code is generated for these structs instead of .proto files")
+	output          = flag.String("o", "", "Path to the output file. The generated code is written to stdout if empty")
+	pkg             = flag.String("package", "", "Go package name for the generated code. It is detected automatically if empty")
+	cloneStrings    = flag.Bool("clone-strings", false, "Whether to clone unmarshaled strings and bytes, so they do not refer to the source data. This results in memory allocations during unmarshaling")
+	pointerOptional = flag.Bool("pointer-optional", false, "Whether to represent optional scalar fields from .proto files as Go pointers, so missing values can be distinguished from zero values. "+
+		"This results in memory allocations during unmarshaling when missing fields appear in the next messages")
+	poolSlices = flag.Bool("pool-slices", false, "Whether to reuse elements of repeated message fields and repeated bytes fields during unmarshaling. "+
+		"This allows reusing memory allocated by nested slices on repeated unmarshaling")
+)
+
+// importPaths contains values for -I flags.
+var importPaths stringSlice
+
+func init() {
+	flag.Var(&importPaths, "I", "Directory to search for .proto files and their imports. This flag can be set multiple times")
+}
+
+// options contains options for code generation.
+type options struct {
+	// pkg is the Go package name for the generated code.
+	pkg string
+
+	// cloneStrings enables cloning of unmarshaled strings and bytes.
+	cloneStrings bool
+
+	// pointerOptional enables representing optional scalar fields as pointers.
+	pointerOptional bool
+
+	// poolSlices enables reusing elements of repeated message fields.
+	poolSlices bool
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] [file.proto ...]\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("easyproto-gen: ")
+
+	opts := &options{
+		pkg:             *pkg,
+		cloneStrings:    *cloneStrings,
+		pointerOptional: *pointerOptional,
+		poolSlices:      *poolSlices,
+	}
+	code, err := run(*goStructs, flag.Args(), importPaths, opts)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	if *output == "" {
+		if _, err := os.Stdout.Write(code); err != nil {
+			log.Fatalf("cannot write the generated code to stdout: %s", err)
+		}
+		return
+	}
+	if err := os.WriteFile(*output, code, 0o644); err != nil {
+		log.Fatalf("cannot write the generated code: %s", err)
+	}
+}
+
+// run generates the code either for Go structs at goStructsPath or for .proto files with the given protoNames.
+func run(goStructsPath string, protoNames, importPaths []string, opts *options) ([]byte, error) {
+	var gf *genFile
+	var err error
+	if goStructsPath != "" {
+		if len(protoNames) > 0 {
+			return nil, fmt.Errorf("-go-structs cannot be mixed with .proto files")
+		}
+		if opts.pointerOptional {
+			return nil, fmt.Errorf("-pointer-optional cannot be used with -go-structs; use pointer types in Go structs instead")
+		}
+		gf, err = fromGoFile(goStructsPath, opts)
+	} else {
+		if len(protoNames) == 0 {
+			return nil, fmt.Errorf("missing .proto files or -go-structs")
+		}
+		gf, err = fromProtoFiles(protoNames, importPaths, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return generate(gf, opts)
+}
+
+// stringSlice is a flag.Value, which collects values from repeated flags.
+type stringSlice []string
+
+func (ss *stringSlice) String() string {
+	return strings.Join(*ss, ",")
+}
+
+func (ss *stringSlice) Set(s string) error {
+	*ss = append(*ss, s)
+	return nil
+}
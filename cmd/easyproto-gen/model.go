@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// genFile describes the generated Go file.
+type genFile struct {
+	// pkg is the Go package name for the generated file.
+	pkg string
+
+	// sources contains the source files the code is generated from.
+	sources []string
+
+	// emitTypes is set to true if Go types must be generated for enums and messages.
+	//
+	// It is false when the code is generated for already existing Go structs.
+	emitTypes bool
+
+	// enums contains enums to generate.
+	enums []*genEnum
+
+	// messages contains messages to generate.
+	messages []*genMessage
+}
+
+// genEnum describes the generated enum type.
+type genEnum struct {
+	// goName is the Go type name for the enum.
+	goName string
+
+	// protoName is the fully-qualified protobuf enum name.
+	protoName string
+
+	// values contains enum values.
+	values []genEnumValue
+}
+
+// genEnumValue describes the generated enum constant.
+type genEnumValue struct {
+	goName string
+	number int32
+}
+
+// genMessage describes the generated message type.
+type genMessage struct {
+	// goName is the Go type name for the message.
+	goName string
+
+	// protoName is the protobuf message name. It is used in error messages.
+	protoName string
+
+	// fields contains message fields in the order of their declaration, including oneof fields.
+	fields []*genField
+
+	// oneofs contains message oneofs.
+	oneofs []*genOneof
+}
+
+// genOneof describes a oneof, which is represented as a struct field holding the case of the set oneof field.
+type genOneof struct {
+	// goName is the Go struct field name for the oneof case.
+	goName string
+
+	// caseType is the Go type name for the oneof case.
+	caseType string
+
+	// protoName is the fully-qualified protobuf oneof name.
+	protoName string
+
+	// fields contains oneof fields.
+	fields []*genField
+}
+
+// caseName returns the name of Go constant for the oneof case, which corresponds to f.
+//
+// The constant for the unset oneof is returned if f is nil.
+func (o *genOneof) caseName(f *genField) string {
+	if f == nil {
+		return o.caseType + "_None"
+	}
+	return o.caseType + "_" + f.goName
+}
+
+// genField describes a message field.
+type genField struct {
+	// goName is the Go struct field name.
+	goName string
+
+	// protoName is the protobuf field name. It is used in error messages.
+	protoName string
+
+	// num is the field number.
+	num uint32
+
+	// kind is the field kind.
+	kind easyproto.Kind
+
+	// goType is the Go type for the field value. It is the element type for repeated fields.
+	goType string
+
+	// repeated is set to true for repeated fields, which are represented as Go slices.
+	repeated bool
+
+	// packed is set to true for repeated fields, which must be marshaled in packed form.
+	packed bool
+
+	// pointer is set to true for optional fields, which are represented as Go pointers.
+	pointer bool
+
+	// required is set to true for fields, which must be marshaled even if they contain zero value.
+	required bool
+
+	// mapKey and mapValue describe the key and the value for map fields.
+	mapKey   *genField
+	mapValue *genField
+
+	// oneof is the oneof, which contains the field. It is nil for fields outside oneofs.
+	oneof *genOneof
+}
+
+// isMessage returns true if f holds message or group.
+func (f *genField) isMessage() bool {
+	return f.kind == easyproto.KindMessage || f.kind == easyproto.KindGroup
+}
+
+// goFieldType returns Go type for the struct field f.
+func (f *genField) goFieldType() string {
+	switch {
+	case f.mapKey != nil:
+		return fmt.Sprintf("map[%s]%s", f.mapKey.goType, f.mapValue.goType)
+	case f.repeated:
+		return "[]" + f.goType
+	case f.pointer:
+		return "*" + f.goType
+	default:
+		return f.goType
+	}
+}
+
+// needsConversion returns true if f.goType differs from the Go type used by easyproto for f.kind.
+func (f *genField) needsConversion() bool {
+	return !f.isMessage() && f.goType != kindGoType(f.kind)
+}
+
+// kindGoType returns Go type used by easyproto for values of the given kind.
+func kindGoType(k easyproto.Kind) string {
+	switch k {
+	case easyproto.KindInt32, easyproto.KindSint32, easyproto.KindSfixed32, easyproto.KindEnum:
+		return "int32"
+	case easyproto.KindInt64, easyproto.KindSint64, easyproto.KindSfixed64:
+		return "int64"
+	case easyproto.KindUint32, easyproto.KindFixed32:
+		return "uint32"
+	case easyproto.KindUint64, easyproto.KindFixed64:
+		return "uint64"
+	case easyproto.KindBool:
+		return "bool"
+	case easyproto.KindDouble:
+		return "float64"
+	case easyproto.KindFloat:
+		return "float32"
+	case easyproto.KindString:
+		return "string"
+	case easyproto.KindBytes:
+		return "[]byte"
+	default:
+		return ""
+	}
+}
+
+// kindMethodSuffix returns the suffix for MessageMarshaler.Append* methods for the given kind.
+func kindMethodSuffix(k easyproto.Kind) string {
+	if k == easyproto.KindEnum {
+		return "Int32"
+	}
+	return kindGetterName(k)
+}
+
+// kindGetterName returns the name of FieldContext method for reading values of the given kind.
+func kindGetterName(k easyproto.Kind) string {
+	s := k.String()
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// kindPackedSuffix returns the suffix for MessageMarshaler.Append*s and FieldContext.Unpack*s methods for the given kind.
+func kindPackedSuffix(k easyproto.Kind) string {
+	return kindMethodSuffix(k) + "s"
+}
+
+// kindByName returns the kind for the given name as returned by easyproto.Kind.String().
+func kindByName(name string) (easyproto.Kind, bool) {
+	for k := easyproto.KindInt32; k <= easyproto.KindFloat; k++ {
+		if k.String() == name {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// goName converts protobuf name such as "foo_bar" into exported Go name such as "FooBar".
+func goName(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, c := range name {
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
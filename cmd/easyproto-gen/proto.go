@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/VictoriaMetrics/easyproto"
+	"github.com/VictoriaMetrics/easyproto/protoparse"
+)
+
+// fromProtoFiles parses .proto files with the given names and converts them into genFile.
+//
+// Imported files are used only for resolving types. The code is generated for the messages and enums from the given files.
+func fromProtoFiles(names []string, importPaths []string, opts *options) (*genFile, error) {
+	p := &protoparse.Parser{
+		ImportPaths: importPaths,
+	}
+	files, err := p.ParseFiles(names...)
+	if err != nil {
+		return nil, err
+	}
+	gf := &genFile{
+		pkg:       opts.pkg,
+		sources:   names,
+		emitTypes: true,
+	}
+	for _, f := range files {
+		if gf.pkg == "" {
+			gf.pkg = protoGoPackage(f)
+		}
+		for _, e := range f.Enums {
+			gf.enums = append(gf.enums, newGenEnum(e))
+		}
+		for _, m := range f.Messages {
+			if err := gf.addProtoMessage(m, opts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return gf, nil
+}
+
+func (gf *genFile) addProtoMessage(m *protoparse.Message, opts *options) error {
+	if m.IsMapEntry {
+		// Map entries are marshaled inline.
+		return nil
+	}
+	gm := &genMessage{
+		goName:    protoGoName(m.Parent, m.Name),
+		protoName: m.FullName,
+	}
+	gfields := make(map[*protoparse.Field]*genField, len(m.Fields))
+	for _, f := range m.Fields {
+		gfield, err := newProtoGenField(f, opts)
+		if err != nil {
+			return fmt.Errorf("%s: cannot generate code for %s.%s: %w", m.File.Name, m.FullName, f.Name, err)
+		}
+		gm.fields = append(gm.fields, gfield)
+		gfields[f] = gfield
+	}
+	for _, o := range m.Oneofs {
+		goneof := &genOneof{
+			goName:    goName(o.Name) + "Case",
+			caseType:  gm.goName + goName(o.Name) + "Case",
+			protoName: m.FullName + "." + o.Name,
+		}
+		for _, f := range o.Fields {
+			gfield := gfields[f]
+			gfield.oneof = goneof
+			goneof.fields = append(goneof.fields, gfield)
+		}
+		gm.oneofs = append(gm.oneofs, goneof)
+	}
+	gf.messages = append(gf.messages, gm)
+
+	for _, e := range m.Enums {
+		gf.enums = append(gf.enums, newGenEnum(e))
+	}
+	for _, nested := range m.Messages {
+		if err := gf.addProtoMessage(nested, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newGenEnum(e *protoparse.Enum) *genEnum {
+	ge := &genEnum{
+		goName:    protoGoName(e.Parent, e.Name),
+		protoName: e.FullName,
+	}
+	for _, v := range e.Values {
+		ge.values = append(ge.values, genEnumValue{
+			goName: ge.goName + "_" + v.Name,
+			number: v.Number,
+		})
+	}
+	return ge
+}
+
+func newProtoGenField(f *protoparse.Field, opts *options) (*genField, error) {
+	gfield := &genField{
+		goName:    goName(f.Name),
+		protoName: f.Name,
+		num:       f.Num,
+		kind:      f.Kind,
+		repeated:  f.IsRepeated(),
+		packed:    f.IsPacked(),
+		required:  f.Label == protoparse.LabelRequired,
+	}
+	if f.Map != nil {
+		key, err := newProtoGenField(f.Map.Key, opts)
+		if err != nil {
+			return nil, err
+		}
+		value, err := newProtoGenField(f.Map.Value, opts)
+		if err != nil {
+			return nil, err
+		}
+		// Map keys and values are always marshaled and they are stored in the map by value.
+		key.required = true
+		key.pointer = false
+		value.required = true
+		value.pointer = false
+		gfield.repeated = false
+		gfield.mapKey = key
+		gfield.mapValue = value
+		return gfield, nil
+	}
+
+	switch f.Kind {
+	case easyproto.KindMessage, easyproto.KindGroup:
+		gfield.goType = protoGoName(f.Message.Parent, f.Message.Name)
+		// Singular messages are always represented as pointers, since this allows recursive messages
+		// and distinguishing missing messages from empty messages.
+		gfield.pointer = !gfield.repeated
+	case easyproto.KindEnum:
+		gfield.goType = protoGoName(f.Enum.Parent, f.Enum.Name)
+	default:
+		gfield.goType = kindGoType(f.Kind)
+	}
+	// Oneof fields aren't represented as pointers, since their presence is tracked by the oneof case.
+	if opts.pointerOptional && !gfield.repeated && !gfield.isMessage() && f.Kind != easyproto.KindBytes && f.Oneof == nil && hasPresence(f) {
+		gfield.pointer = true
+	}
+	return gfield, nil
+}
+
+// hasPresence returns true if f tracks the presence of the field value.
+//
+// Required fields are always set, so they don't need presence tracking.
+func hasPresence(f *protoparse.Field) bool {
+	if f.Label == protoparse.LabelRequired {
+		return false
+	}
+	if v, ok := f.Feature("field_presence"); ok && v == "LEGACY_REQUIRED" {
+		return false
+	}
+	return f.HasPresence()
+}
+
+// protoGoName returns Go type name for the message or enum with the given name and parent.
+//
+// Nested types are prefixed with their parent names, e.g. "OuterInner" for Outer.Inner.
+func protoGoName(parent *protoparse.Message, name string) string {
+	for parent != nil {
+		name = parent.Name + "_" + name
+		parent = parent.Parent
+	}
+	return goName(name)
+}
+
+// protoGoPackage returns Go package name for f.
+//
+// It is obtained from go_package option if it is set, otherwise from the package name.
+func protoGoPackage(f *protoparse.File) string {
+	for _, o := range f.Options {
+		if o.Name != "go_package" {
+			continue
+		}
+		if n := strings.IndexByte(o.Value, ';'); n >= 0 {
+			return o.Value[n+1:]
+		}
+		return sanitizePackageName(path.Base(o.Value))
+	}
+	if f.Package != "" {
+		pkg := f.Package
+		if n := strings.LastIndexByte(pkg, '.'); n >= 0 {
+			pkg = pkg[n+1:]
+		}
+		return sanitizePackageName(pkg)
+	}
+	return sanitizePackageName(strings.TrimSuffix(path.Base(f.Name), ".proto"))
+}
+
+func sanitizePackageName(s string) string {
+	s = strings.Map(func(c rune) rune {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			return c
+		}
+		return '_'
+	}, s)
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+	return s
+}
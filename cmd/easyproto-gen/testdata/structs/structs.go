@@ -0,0 +1,32 @@
+package structs
+
+type Status int32
+
+type Timeseries struct {
+	Name      string            `proto:"1"`
+	Samples   []Sample          `proto:"2"`
+	Labels    map[string]string `proto:"3"`
+	IDs       []uint32          `proto:"4,packed"`
+	Status    Status            `proto:"5,enum"`
+	Statuses  []Status          `proto:"6,enum"`
+	Offset    *int64            `proto:"7,sint64"`
+	Data      []byte            `proto:"8"`
+	Meta      *Meta             `proto:"9"`
+	Group     Meta              `proto:"10,group"`
+	Timestamp int64             `proto:"11,sfixed64"`
+	Counts    map[uint32]int64  `proto:"12"`
+	Flags     map[bool]string   `proto:"13"`
+
+	// Fields without proto tag are ignored
+	cache []byte
+}
+
+type Sample struct {
+	Value     float64 `proto:"1"`
+	Timestamp int64   `proto:"2,sint64"`
+}
+
+type Meta struct {
+	Host string `proto:"1"`
+	Port uint32 `proto:"2,fixed32"`
+}
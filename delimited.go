@@ -0,0 +1,165 @@
+package easyproto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxMessageSize is the default maximum size of a single message read by DelimitedReader.
+const DefaultMaxMessageSize = 64 * 1024 * 1024
+
+// DelimitedReader reads length-delimited protobuf messages from io.Reader.
+//
+// Every message must be prefixed with its varint-encoded length, e.g. it must be marshaled with Marshaler.MarshalWithLen().
+//
+// It is unsafe to use a single DelimitedReader instance from multiple concurrently running goroutines.
+type DelimitedReader struct {
+	// br is buffered reader for the underlying io.Reader.
+	br *bufio.Reader
+
+	// buf contains the last message read via ReadMessage().
+	buf []byte
+
+	// maxMessageSize is the maximum size of a single message.
+	maxMessageSize int
+}
+
+// NewDelimitedReader returns DelimitedReader for reading length-delimited messages from r.
+//
+// maxMessageSize limits the maximum size of a single message. DefaultMaxMessageSize is used if maxMessageSize <= 0.
+func NewDelimitedReader(r io.Reader, maxMessageSize int) *DelimitedReader {
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultMaxMessageSize
+	}
+	return &DelimitedReader{
+		br:             bufio.NewReader(r),
+		maxMessageSize: maxMessageSize,
+	}
+}
+
+// Reset resets dr, so it reads messages from r.
+//
+// The internal buffers are re-used, so this may reduce memory allocations.
+func (dr *DelimitedReader) Reset(r io.Reader) {
+	dr.br.Reset(r)
+	dr.buf = dr.buf[:0]
+}
+
+// ReadMessage reads the next length-delimited message from dr.
+//
+// The returned message can be parsed with FieldContext.NextField().
+// It is valid until the next ReadMessage() call, so it must be copied if it is needed after that.
+//
+// io.EOF is returned if there are no more messages in the underlying reader.
+// io.ErrUnexpectedEOF is returned if the underlying reader ends in the middle of a message.
+func (dr *DelimitedReader) ReadMessage() ([]byte, error) {
+	msgLen, err := binary.ReadUvarint(dr.br)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cannot read message length: %w", err)
+	}
+	if msgLen > uint64(dr.maxMessageSize) {
+		return nil, fmt.Errorf("too big message size: %d bytes; it mustn't exceed %d bytes", msgLen, dr.maxMessageSize)
+	}
+
+	n := int(msgLen)
+	if cap(dr.buf) < n {
+		dr.buf = make([]byte, n)
+	}
+	dr.buf = dr.buf[:n]
+	if _, err := io.ReadFull(dr.br, dr.buf); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return dr.buf, nil
+}
+
+// delimitedWriterBufSize is the size of the internal buffer for DelimitedWriter.
+//
+// The buffered data is written to the underlying io.Writer when its size exceeds this value.
+const delimitedWriterBufSize = 64 * 1024
+
+// DelimitedWriter writes length-delimited protobuf messages to io.Writer.
+//
+// The written messages can be read with DelimitedReader.
+//
+// The messages are buffered, so Flush() must be called after writing the last message.
+//
+// It is unsafe to use a single DelimitedWriter instance from multiple concurrently running goroutines.
+type DelimitedWriter struct {
+	// w is the underlying io.Writer.
+	w io.Writer
+
+	// buf contains buffered messages, which weren't written to w yet.
+	buf []byte
+
+	// err is the first error occurred when writing to w.
+	err error
+
+	// mp is the pool of Marshaler structs used by WriteFunc().
+	mp MarshalerPool
+}
+
+// NewDelimitedWriter returns DelimitedWriter for writing length-delimited messages to w.
+func NewDelimitedWriter(w io.Writer) *DelimitedWriter {
+	return &DelimitedWriter{
+		w: w,
+	}
+}
+
+// Reset resets dw, so it writes messages to w.
+//
+// The buffered messages, which weren't flushed, are dropped.
+func (dw *DelimitedWriter) Reset(w io.Writer) {
+	dw.w = w
+	dw.buf = dw.buf[:0]
+	dw.err = nil
+}
+
+// WriteMessage writes the message constructed at m to dw.
+//
+// m can be re-used after the call.
+func (dw *DelimitedWriter) WriteMessage(m *Marshaler) error {
+	if dw.err != nil {
+		return dw.err
+	}
+	dw.buf = m.MarshalWithLen(dw.buf)
+	if len(dw.buf) < delimitedWriterBufSize {
+		return nil
+	}
+	return dw.Flush()
+}
+
+// WriteFunc writes the message constructed by f to dw.
+//
+// f must construct the message with Append* functions at the passed mm.
+// mm cannot be used after returning from f.
+func (dw *DelimitedWriter) WriteFunc(f func(mm *MessageMarshaler)) error {
+	m := dw.mp.Get()
+	f(m.MessageMarshaler())
+	err := dw.WriteMessage(m)
+	dw.mp.Put(m)
+	return err
+}
+
+// Flush writes the buffered messages to the underlying io.Writer.
+func (dw *DelimitedWriter) Flush() error {
+	if dw.err != nil {
+		return dw.err
+	}
+	if len(dw.buf) == 0 {
+		return nil
+	}
+	if _, err := dw.w.Write(dw.buf); err != nil {
+		dw.err = err
+		return err
+	}
+	dw.buf = dw.buf[:0]
+	return nil
+}
@@ -0,0 +1,224 @@
+package easyproto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDelimitedReaderSuccess(t *testing.T) {
+	var data []byte
+	var msgsExpected []string
+	for i := 0; i < 100; i++ {
+		s := fmt.Sprintf("message_%d", i)
+		if i%10 == 0 {
+			// Add long messages, which do not fit the internal buffer
+			s = string(bytes.Repeat([]byte(s), 1000))
+		}
+		m := mp.Get()
+		mm := m.MessageMarshaler()
+		mm.AppendString(1, s)
+		data = m.MarshalWithLen(data)
+		mp.Put(m)
+		msgsExpected = append(msgsExpected, s)
+	}
+
+	// Add empty message
+	data = append(data, 0)
+	msgsExpected = append(msgsExpected, "")
+
+	f := func(r io.Reader) {
+		t.Helper()
+
+		dr := NewDelimitedReader(r, 0)
+		for i, sExpected := range msgsExpected {
+			msg, err := dr.ReadMessage()
+			if err != nil {
+				t.Fatalf("unexpected error when reading message #%d: %s", i, err)
+			}
+			if sExpected == "" {
+				if len(msg) != 0 {
+					t.Fatalf("unexpected non-empty message #%d: %X", i, msg)
+				}
+				continue
+			}
+			s, ok, err := GetString(msg, 1)
+			if err != nil || !ok {
+				t.Fatalf("cannot read string from message #%d; ok=%v, err=%v", i, ok, err)
+			}
+			if s != sExpected {
+				t.Fatalf("unexpected string in message #%d; got %q; want %q", i, s, sExpected)
+			}
+		}
+		msg, err := dr.ReadMessage()
+		if err != io.EOF {
+			t.Fatalf("unexpected error; got %v; want io.EOF", err)
+		}
+		if msg != nil {
+			t.Fatalf("unexpected non-nil message: %X", msg)
+		}
+	}
+
+	f(bytes.NewReader(data))
+	f(iotest.OneByteReader(bytes.NewReader(data)))
+	f(iotest.HalfReader(bytes.NewReader(data)))
+}
+
+func TestDelimitedReaderFailure(t *testing.T) {
+	f := func(data []byte, maxMessageSize int, errExpected error) {
+		t.Helper()
+
+		dr := NewDelimitedReader(bytes.NewReader(data), maxMessageSize)
+		_, err := dr.ReadMessage()
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if errExpected != nil && err != errExpected {
+			t.Fatalf("unexpected error; got %v; want %v", err, errExpected)
+		}
+	}
+
+	// empty stream
+	f(nil, 0, io.EOF)
+
+	// truncated message length
+	f([]byte{0x80}, 0, io.ErrUnexpectedEOF)
+
+	// truncated message
+	f([]byte{0x05}, 0, io.ErrUnexpectedEOF)
+	f([]byte{0x05, 0x01, 0x02}, 0, io.ErrUnexpectedEOF)
+
+	// too big message
+	f([]byte{0x05, 0x01, 0x02, 0x03, 0x04, 0x05}, 4, nil)
+
+	// invalid message length
+	f([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, 0, nil)
+}
+
+func TestDelimitedReaderReadError(t *testing.T) {
+	errRead := errors.New("read error")
+	dr := NewDelimitedReader(iotest.ErrReader(errRead), 0)
+	_, err := dr.ReadMessage()
+	if !errors.Is(err, errRead) {
+		t.Fatalf("unexpected error; got %v; want %v", err, errRead)
+	}
+
+	dr.Reset(bytes.NewReader([]byte{0x02, 0x08, 0x01}))
+	msg, err := dr.ReadMessage()
+	if err != nil {
+		t.Fatalf("unexpected error after Reset: %s", err)
+	}
+	if !bytes.Equal(msg, []byte{0x08, 0x01}) {
+		t.Fatalf("unexpected message; got %X; want 0801", msg)
+	}
+}
+
+func TestDelimitedWriterReader(t *testing.T) {
+	var bb bytes.Buffer
+	dw := NewDelimitedWriter(&bb)
+
+	const messagesCount = 10000
+	for i := 0; i < messagesCount; i++ {
+		if i%2 == 0 {
+			m := mp.Get()
+			mm := m.MessageMarshaler()
+			mm.AppendInt64(1, int64(i))
+			if err := dw.WriteMessage(m); err != nil {
+				t.Fatalf("unexpected error in WriteMessage: %s", err)
+			}
+			mp.Put(m)
+		} else {
+			err := dw.WriteFunc(func(mm *MessageMarshaler) {
+				mm.AppendInt64(1, int64(i))
+			})
+			if err != nil {
+				t.Fatalf("unexpected error in WriteFunc: %s", err)
+			}
+		}
+	}
+	if err := dw.Flush(); err != nil {
+		t.Fatalf("unexpected error in Flush: %s", err)
+	}
+
+	dr := NewDelimitedReader(&bb, 0)
+	for i := 0; i < messagesCount; i++ {
+		msg, err := dr.ReadMessage()
+		if err != nil {
+			t.Fatalf("unexpected error when reading message #%d: %s", i, err)
+		}
+		n, ok, err := GetInt64(msg, 1)
+		if err != nil || !ok {
+			t.Fatalf("cannot read int64 from message #%d; ok=%v, err=%v", i, ok, err)
+		}
+		if n != int64(i) {
+			t.Fatalf("unexpected value in message #%d; got %d; want %d", i, n, i)
+		}
+	}
+	if _, err := dr.ReadMessage(); err != io.EOF {
+		t.Fatalf("unexpected error; got %v; want io.EOF", err)
+	}
+}
+
+func TestDelimitedWriterEmptyMessage(t *testing.T) {
+	var bb bytes.Buffer
+	dw := NewDelimitedWriter(&bb)
+	if err := dw.WriteFunc(func(_ *MessageMarshaler) {}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := dw.Flush(); err != nil {
+		t.Fatalf("unexpected error in Flush: %s", err)
+	}
+	if !bytes.Equal(bb.Bytes(), []byte{0}) {
+		t.Fatalf("unexpected data written; got %X; want 00", bb.Bytes())
+	}
+}
+
+func TestDelimitedWriterWriteError(t *testing.T) {
+	errWrite := errors.New("write error")
+	dw := NewDelimitedWriter(errorWriter{err: errWrite})
+	err := dw.WriteFunc(func(mm *MessageMarshaler) {
+		mm.AppendString(1, "foo")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error for buffered message: %s", err)
+	}
+	if err := dw.Flush(); err != errWrite {
+		t.Fatalf("unexpected error; got %v; want %v", err, errWrite)
+	}
+
+	// The error must be sticky
+	err = dw.WriteFunc(func(mm *MessageMarshaler) {
+		mm.AppendString(1, "bar")
+	})
+	if err != errWrite {
+		t.Fatalf("unexpected error; got %v; want %v", err, errWrite)
+	}
+
+	// Reset must clear the error
+	var bb bytes.Buffer
+	dw.Reset(&bb)
+	err = dw.WriteFunc(func(mm *MessageMarshaler) {
+		mm.AppendString(1, "baz")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error after Reset: %s", err)
+	}
+	if err := dw.Flush(); err != nil {
+		t.Fatalf("unexpected error in Flush after Reset: %s", err)
+	}
+	dataExpected := []byte{0x05, 0x0a, 0x03, 'b', 'a', 'z'}
+	if !bytes.Equal(bb.Bytes(), dataExpected) {
+		t.Fatalf("unexpected data written; got %X; want %X", bb.Bytes(), dataExpected)
+	}
+}
+
+type errorWriter struct {
+	err error
+}
+
+func (ew errorWriter) Write(_ []byte) (int, error) {
+	return 0, ew.err
+}
@@ -2,6 +2,8 @@ package easyproto
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
@@ -1082,6 +1084,234 @@ func TestMarshalUnmarshalFloats(t *testing.T) {
 	}
 }
 
+func TestRangePacked(t *testing.T) {
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendInt32s(1, []int32{0, -1, 1<<31 - 1, -1 << 31})
+	mm.AppendInt64s(2, []int64{0, -1, 1<<63 - 1, -1 << 63})
+	mm.AppendUint32s(3, []uint32{0, 1, 1<<32 - 1})
+	mm.AppendUint64s(4, []uint64{0, 1, 1<<64 - 1})
+	mm.AppendSint32s(5, []int32{0, -1, 1<<31 - 1, -1 << 31})
+	mm.AppendSint64s(6, []int64{0, -1, 1<<63 - 1, -1 << 63})
+	mm.AppendBools(7, []bool{true, false, true})
+	mm.AppendFixed64s(8, []uint64{0, 1, 1<<64 - 1})
+	mm.AppendSfixed64s(9, []int64{0, -1, 1<<63 - 1, -1 << 63})
+	mm.AppendDoubles(10, []float64{0, -1.5, 1e100})
+	mm.AppendFixed32s(11, []uint32{0, 1, 1<<32 - 1})
+	mm.AppendSfixed32s(12, []int32{0, -1, 1<<31 - 1, -1 << 31})
+	mm.AppendFloats(13, []float32{0, -1.5, 1e10})
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	var fc FieldContext
+	for len(data) > 0 {
+		var err error
+		data, err = fc.NextField(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			checkRangePacked(t, &fc, fc.RangeInt32s, fc.UnpackInt32s)
+		case 2:
+			checkRangePacked(t, &fc, fc.RangeInt64s, fc.UnpackInt64s)
+		case 3:
+			checkRangePacked(t, &fc, fc.RangeUint32s, fc.UnpackUint32s)
+		case 4:
+			checkRangePacked(t, &fc, fc.RangeUint64s, fc.UnpackUint64s)
+		case 5:
+			checkRangePacked(t, &fc, fc.RangeSint32s, fc.UnpackSint32s)
+		case 6:
+			checkRangePacked(t, &fc, fc.RangeSint64s, fc.UnpackSint64s)
+		case 7:
+			checkRangePacked(t, &fc, fc.RangeBools, fc.UnpackBools)
+		case 8:
+			checkRangePacked(t, &fc, fc.RangeFixed64s, fc.UnpackFixed64s)
+		case 9:
+			checkRangePacked(t, &fc, fc.RangeSfixed64s, fc.UnpackSfixed64s)
+		case 10:
+			checkRangePacked(t, &fc, fc.RangeDoubles, fc.UnpackDoubles)
+		case 11:
+			checkRangePacked(t, &fc, fc.RangeFixed32s, fc.UnpackFixed32s)
+		case 12:
+			checkRangePacked(t, &fc, fc.RangeSfixed32s, fc.UnpackSfixed32s)
+		case 13:
+			checkRangePacked(t, &fc, fc.RangeFloats, fc.UnpackFloats)
+		default:
+			t.Fatalf("unexpected fieldNum=%d", fc.FieldNum)
+		}
+	}
+}
+
+func TestPackedCount(t *testing.T) {
+	f := func(data []byte, countFunc func(fc *FieldContext) (int, bool), nExpected int, okExpected bool) {
+		t.Helper()
+
+		var fc FieldContext
+		if _, err := fc.NextField(data); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		n, ok := countFunc(&fc)
+		if ok != okExpected {
+			t.Fatalf("unexpected ok; got %v; want %v", ok, okExpected)
+		}
+		if n != nExpected {
+			t.Fatalf("unexpected count; got %d; want %d", n, nExpected)
+		}
+	}
+
+	varintCount := (*FieldContext).PackedVarintCount
+	fixed64Count := (*FieldContext).PackedFixed64Count
+	fixed32Count := (*FieldContext).PackedFixed32Count
+
+	m := mp.Get()
+	marshal := func(fn func(mm *MessageMarshaler)) []byte {
+		m.Reset()
+		fn(m.MessageMarshaler())
+		return m.Marshal(nil)
+	}
+
+	// packed fields
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendUint64s(1, nil) }), varintCount, 0, true)
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendUint64s(1, []uint64{0, 1, 1 << 7, 1 << 14, 1<<64 - 1}) }), varintCount, 5, true)
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendInt32s(1, []int32{-1, 0, -1 << 31}) }), varintCount, 3, true)
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendDoubles(1, []float64{1, 2, 3}) }), fixed64Count, 3, true)
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendFloats(1, []float32{1, 2, 3, 4}) }), fixed32Count, 4, true)
+
+	// scalar fields
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendUint64(1, 1<<64-1) }), varintCount, 1, true)
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendFixed64(1, 123) }), fixed64Count, 1, true)
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendFixed32(1, 123) }), fixed32Count, 1, true)
+
+	// wrong wire types
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendFixed64(1, 123) }), varintCount, 0, false)
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendFixed32(1, 123) }), fixed64Count, 0, false)
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendUint64(1, 123) }), fixed32Count, 0, false)
+
+	// invalid lengths for fixed-width values
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendBytes(1, make([]byte, 9)) }), fixed64Count, 0, false)
+	f(marshal(func(mm *MessageMarshaler) { mm.AppendBytes(1, make([]byte, 5)) }), fixed32Count, 0, false)
+
+	mp.Put(m)
+}
+
+func TestNextPackedVarint(t *testing.T) {
+	f := func(src []byte) {
+		t.Helper()
+
+		u64Expected, offsetExpected := binary.Uvarint(src)
+		u64, offset := nextPackedVarint(src)
+		if offset != offsetExpected {
+			t.Fatalf("unexpected offset for %X; got %d; want %d", src, offset, offsetExpected)
+		}
+		if u64 != u64Expected {
+			t.Fatalf("unexpected value for %X; got %d; want %d", src, u64, u64Expected)
+		}
+	}
+
+	// valid varints
+	var buf [binary.MaxVarintLen64]byte
+	for _, u64 := range []uint64{0, 1, 1<<7 - 1, 1 << 7, 1<<14 - 1, 1 << 14, 1<<63 - 1, 1 << 63, 1<<64 - 1} {
+		n := binary.PutUvarint(buf[:], u64)
+		f(buf[:n])
+		f(buf[:1])
+	}
+
+	// empty src
+	f(nil)
+
+	// truncated varint
+	f([]byte{0x80, 0x80})
+
+	// overflow at the last byte
+	f([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02})
+
+	// too long varint
+	f([]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01})
+
+	// non-minimal varint
+	f([]byte{0x81, 0x00})
+}
+
+func TestUnpackSingleAllocation(t *testing.T) {
+	if isRaceEnabled {
+		t.Skip("skipping allocation test, since the race detector makes sync.Pool to drop items randomly")
+	}
+
+	values := make([]uint64, 100_000)
+	for i := range values {
+		values[i] = uint64(i) * 1000
+	}
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendUint64s(1, values)
+	mm.AppendDoubles(2, make([]float64, 100_000))
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	var fc FieldContext
+	tail, err := fc.NextField(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		dst, ok := fc.UnpackUint64s(nil)
+		if !ok || len(dst) != len(values) {
+			panic(fmt.Errorf("cannot unpack uint64 values"))
+		}
+	})
+	if allocs != 1 {
+		t.Fatalf("unexpected number of allocations for UnpackUint64s; got %v; want 1", allocs)
+	}
+
+	if _, err := fc.NextField(tail); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	allocs = testing.AllocsPerRun(10, func() {
+		dst, ok := fc.UnpackDoubles(nil)
+		if !ok || len(dst) != 100_000 {
+			panic(fmt.Errorf("cannot unpack double values"))
+		}
+	})
+	if allocs != 1 {
+		t.Fatalf("unexpected number of allocations for UnpackDoubles; got %v; want 1", allocs)
+	}
+}
+
+func checkRangePacked[T any](t *testing.T, fc *FieldContext, rangeFunc func(f func(v T) bool) bool, unpackFunc func(dst []T) ([]T, bool)) {
+	t.Helper()
+
+	valuesExpected, ok := unpackFunc(nil)
+	if !ok {
+		t.Fatalf("cannot unpack values for fieldNum=%d", fc.FieldNum)
+	}
+
+	var values []T
+	ok = rangeFunc(func(v T) bool {
+		values = append(values, v)
+		return true
+	})
+	if !ok {
+		t.Fatalf("cannot range values for fieldNum=%d", fc.FieldNum)
+	}
+	if !reflect.DeepEqual(values, valuesExpected) {
+		t.Fatalf("unexpected values for fieldNum=%d; got %v; want %v", fc.FieldNum, values, valuesExpected)
+	}
+
+	// Verify early stop
+	calls := 0
+	ok = rangeFunc(func(_ T) bool {
+		calls++
+		return false
+	})
+	if !ok {
+		t.Fatalf("unexpected failure on early stop for fieldNum=%d", fc.FieldNum)
+	}
+	if calls != 1 {
+		t.Fatalf("unexpected number of calls for fieldNum=%d; got %d; want 1", fc.FieldNum, calls)
+	}
+}
+
 func TestMarshalUnmarshalMessage(t *testing.T) {
 	type label struct {
 		Name  string
@@ -1536,23 +1766,230 @@ func TestNextFieldFailure(t *testing.T) {
 	f([]byte{1 << 3, 0xff})
 
 	// too small i64
-	f([]byte{byte(1<<3 | wireTypeI64), 0xff})
+	f([]byte{byte(1<<3 | WireTypeI64), 0xff})
 
 	// too small i32
-	f([]byte{byte(1<<3 | wireTypeI32), 0xff})
+	f([]byte{byte(1<<3 | WireTypeI32), 0xff})
 
-	// incorrectly encoded len for wireTypeLen
-	f([]byte{byte(1<<3 | wireTypeLen), 0xff})
+	// incorrectly encoded len for WireTypeLen
+	f([]byte{byte(1<<3 | WireTypeLen), 0xff})
 
 	// too small message for wireTypelen
-	f([]byte{byte(1<<3 | wireTypeLen), 0x01})
-	f([]byte{0xff, byte(1<<3 | wireTypeLen), 0x01})
-	f([]byte{byte(1<<3 | wireTypeLen), 0xff, 0x7f})
+	f([]byte{byte(1<<3 | WireTypeLen), 0x01})
+	f([]byte{0xff, byte(1<<3 | WireTypeLen), 0x01})
+	f([]byte{byte(1<<3 | WireTypeLen), 0xff, 0x7f})
 
 	// unknown wireType
 	f([]byte{1<<3 | 7})
 }
 
+func TestNextFieldGroup(t *testing.T) {
+	// message {
+	//   int64 a = 1;
+	//   group G = 2 {
+	//     string b = 3;
+	//     group H = 4 {
+	//       fixed32 c = 5;
+	//     }
+	//     fixed64 d = 6;
+	//   }
+	//   string e = 7;
+	// }
+	data := []byte{
+		1<<3 | byte(WireTypeVarint), 0x7b,
+		2<<3 | byte(WireTypeSGroup),
+		3<<3 | byte(WireTypeLen), 0x03, 'f', 'o', 'o',
+		4<<3 | byte(WireTypeSGroup),
+		5<<3 | byte(WireTypeI32), 0x01, 0x02, 0x03, 0x04,
+		4<<3 | byte(WireTypeEGroup),
+		6<<3 | byte(WireTypeI64), 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		2<<3 | byte(WireTypeEGroup),
+		7<<3 | byte(WireTypeLen), 0x03, 'b', 'a', 'r',
+	}
+
+	var fc FieldContext
+	expectNextField := func(src []byte, fieldNum uint32, wt WireType) []byte {
+		t.Helper()
+		tail, err := fc.NextField(src)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if fc.FieldNum != fieldNum {
+			t.Fatalf("unexpected fieldNum; got %d; want %d", fc.FieldNum, fieldNum)
+		}
+		if fc.WireType() != wt {
+			t.Fatalf("unexpected wireType; got %s; want %s", fc.WireType(), wt)
+		}
+		return tail
+	}
+
+	data = expectNextField(data, 1, WireTypeVarint)
+	data = expectNextField(data, 2, WireTypeSGroup)
+	if _, ok := fc.MessageData(); ok {
+		t.Fatalf("group mustn't be returned as message data")
+	}
+	group, ok := fc.GroupData()
+	if !ok {
+		t.Fatalf("cannot read group data")
+	}
+	data = expectNextField(data, 7, WireTypeLen)
+	if len(data) > 0 {
+		t.Fatalf("unexpected data left: %X", data)
+	}
+
+	group = expectNextField(group, 3, WireTypeLen)
+	if s, _ := fc.String(); s != "foo" {
+		t.Fatalf("unexpected string in group; got %q; want %q", s, "foo")
+	}
+	group = expectNextField(group, 4, WireTypeSGroup)
+	nestedGroup, ok := fc.GroupData()
+	if !ok {
+		t.Fatalf("cannot read nested group data")
+	}
+	group = expectNextField(group, 6, WireTypeI64)
+	if len(group) > 0 {
+		t.Fatalf("unexpected group data left: %X", group)
+	}
+
+	nestedGroup = expectNextField(nestedGroup, 5, WireTypeI32)
+	if u32, _ := fc.Fixed32(); u32 != 0x04030201 {
+		t.Fatalf("unexpected fixed32 in nested group; got 0x%X; want 0x04030201", u32)
+	}
+	if len(nestedGroup) > 0 {
+		t.Fatalf("unexpected nested group data left: %X", nestedGroup)
+	}
+
+	// Verify that the group is skipped by FieldByNum
+	data = []byte{
+		1<<3 | byte(WireTypeSGroup),
+		2<<3 | byte(WireTypeVarint), 0x01,
+		1<<3 | byte(WireTypeEGroup),
+		3<<3 | byte(WireTypeVarint), 0x2a,
+	}
+	n, ok, err := GetInt64(data, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !ok {
+		t.Fatalf("cannot find field #3")
+	}
+	if n != 42 {
+		t.Fatalf("unexpected value; got %d; want 42", n)
+	}
+}
+
+func TestMarshalGroup(t *testing.T) {
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(1, 123)
+	g := mm.AppendGroup(2)
+	g.AppendString(3, "foo")
+	msg := g.AppendMessage(4)
+	nestedGroup := msg.AppendGroup(5)
+	nestedGroup.AppendFixed32(6, 0x04030201)
+	_ = g.AppendGroup(7)
+	mm.AppendString(8, "bar")
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	dataExpected := []byte{
+		1<<3 | byte(WireTypeVarint), 0x7b,
+		2<<3 | byte(WireTypeSGroup),
+		3<<3 | byte(WireTypeLen), 0x03, 'f', 'o', 'o',
+		4<<3 | byte(WireTypeLen), 0x07,
+		5<<3 | byte(WireTypeSGroup),
+		6<<3 | byte(WireTypeI32), 0x01, 0x02, 0x03, 0x04,
+		5<<3 | byte(WireTypeEGroup),
+		7<<3 | byte(WireTypeSGroup),
+		7<<3 | byte(WireTypeEGroup),
+		2<<3 | byte(WireTypeEGroup),
+		8<<3 | byte(WireTypeLen), 0x03, 'b', 'a', 'r',
+	}
+	if !bytes.Equal(data, dataExpected) {
+		t.Fatalf("unexpected data\ngot\n%X\nwant\n%X", data, dataExpected)
+	}
+
+	// Verify group with big fieldNum and big body
+	b := make([]byte, 1024)
+	m = mp.Get()
+	mm = m.MessageMarshaler()
+	g = mm.AppendGroup(1230)
+	g.AppendBytes(234, b)
+	data = m.MarshalWithLen(nil)
+	mp.Put(m)
+
+	msgLen, data, ok := UnmarshalMessageLen(data)
+	if !ok {
+		t.Fatalf("cannot read message length")
+	}
+	if msgLen != len(data) {
+		t.Fatalf("unexpected message length; got %d; want %d", msgLen, len(data))
+	}
+	var fc FieldContext
+	tail, err := fc.NextField(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tail) != 0 {
+		t.Fatalf("unexpected non-empty tail: %X", tail)
+	}
+	if fc.FieldNum != 1230 {
+		t.Fatalf("unexpected fieldNum; got %d; want 1230", fc.FieldNum)
+	}
+	groupData, ok := fc.GroupData()
+	if !ok {
+		t.Fatalf("cannot read group data")
+	}
+	b1, ok, err := GetBytes(groupData, 234)
+	if err != nil || !ok {
+		t.Fatalf("cannot read bytes from group; ok=%v, err=%v", ok, err)
+	}
+	if !bytes.Equal(b, b1) {
+		t.Fatalf("unexpected bytes read\ngot\n%X\nwant\n%X", b1, b)
+	}
+}
+
+func TestNextFieldGroupFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		var fc FieldContext
+		_, err := fc.NextField(data)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing end group tag
+	f([]byte{1<<3 | byte(WireTypeSGroup)})
+	f([]byte{1<<3 | byte(WireTypeSGroup), 2<<3 | byte(WireTypeVarint), 0x01})
+
+	// end group tag without start group tag
+	f([]byte{1<<3 | byte(WireTypeEGroup)})
+
+	// mismatched end group tag
+	f([]byte{1<<3 | byte(WireTypeSGroup), 2<<3 | byte(WireTypeEGroup)})
+	f([]byte{1<<3 | byte(WireTypeSGroup), 2<<3 | byte(WireTypeSGroup), 1<<3 | byte(WireTypeEGroup), 2<<3 | byte(WireTypeEGroup)})
+
+	// invalid fields inside group
+	f([]byte{1<<3 | byte(WireTypeSGroup), 0xff})
+	f([]byte{1<<3 | byte(WireTypeSGroup), 2<<3 | byte(WireTypeVarint), 0xff})
+	f([]byte{1<<3 | byte(WireTypeSGroup), 2<<3 | byte(WireTypeI64), 0x01})
+	f([]byte{1<<3 | byte(WireTypeSGroup), 2<<3 | byte(WireTypeI32), 0x01})
+	f([]byte{1<<3 | byte(WireTypeSGroup), 2<<3 | byte(WireTypeLen), 0x05, 0x01})
+	f([]byte{1<<3 | byte(WireTypeSGroup), 2<<3 | byte(WireTypeLen), 0xff})
+	f([]byte{1<<3 | byte(WireTypeSGroup), 2<<3 | 7, 1<<3 | byte(WireTypeEGroup)})
+
+	// too deep nesting
+	var data []byte
+	for i := 0; i <= maxGroupDepth; i++ {
+		data = append(data, 1<<3|byte(WireTypeSGroup))
+	}
+	for i := 0; i <= maxGroupDepth; i++ {
+		data = append(data, 1<<3|byte(WireTypeEGroup))
+	}
+	f(data)
+}
+
 func TestFieldContextWrongWireType(t *testing.T) {
 	m := mp.Get()
 	mm := m.MessageMarshaler()
@@ -1673,6 +2110,14 @@ func TestFieldContextWrongWireType(t *testing.T) {
 		t.Fatalf("unexpected msgData=%q; want nil", msgData)
 	}
 
+	groupData, ok := fc.GroupData()
+	if ok {
+		t.Fatalf("expecting missing group data")
+	}
+	if groupData != nil {
+		t.Fatalf("unexpected groupData=%q; want nil", groupData)
+	}
+
 	if _, ok := fc.UnpackInt32s(nil); ok {
 		t.Fatalf("expecting non-nil error")
 	}
@@ -1753,8 +2198,98 @@ func TestFieldContextWrongWireType(t *testing.T) {
 	}
 }
 
+func TestFieldContextWireType(t *testing.T) {
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(1, 123)
+	mm.AppendFixed64(2, 123)
+	mm.AppendString(3, "foo")
+	mm.AppendFixed32(4, 123)
+	mm.AppendUint64s(5, []uint64{1, 2, 3})
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	wireTypesExpected := []WireType{WireTypeVarint, WireTypeI64, WireTypeLen, WireTypeI32, WireTypeLen}
+	var fc FieldContext
+	for i, wtExpected := range wireTypesExpected {
+		var err error
+		data, err = fc.NextField(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if fc.FieldNum != uint32(i+1) {
+			t.Fatalf("unexpected fieldNum; got %d; want %d", fc.FieldNum, i+1)
+		}
+		if wt := fc.WireType(); wt != wtExpected {
+			t.Fatalf("unexpected wireType for fieldNum=%d; got %s; want %s", fc.FieldNum, wt, wtExpected)
+		}
+	}
+	if len(data) > 0 {
+		t.Fatalf("unexpected data left: %X", data)
+	}
+}
+
+func TestWireTypeString(t *testing.T) {
+	f := func(wt WireType, sExpected string) {
+		t.Helper()
+		if s := wt.String(); s != sExpected {
+			t.Fatalf("unexpected string; got %q; want %q", s, sExpected)
+		}
+	}
+
+	f(WireTypeVarint, "varint")
+	f(WireTypeI64, "i64")
+	f(WireTypeLen, "len")
+	f(WireTypeSGroup, "sgroup")
+	f(WireTypeEGroup, "egroup")
+	f(WireTypeI32, "i32")
+	f(7, "unknown (7)")
+}
+
+func TestGetFieldWireTypeError(t *testing.T) {
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendString(42, "foo")
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	_, ok, err := GetInt64(data, 42)
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if ok {
+		t.Fatalf("unexpected ok=true")
+	}
+	var wte *WireTypeError
+	if !errors.As(err, &wte) {
+		t.Fatalf("expecting WireTypeError; got %T: %s", err, err)
+	}
+	if wte.FieldNum != 42 {
+		t.Fatalf("unexpected FieldNum; got %d; want 42", wte.FieldNum)
+	}
+	if wte.Got != WireTypeLen {
+		t.Fatalf("unexpected Got; got %s; want %s", wte.Got, WireTypeLen)
+	}
+	if wte.Want != WireTypeVarint {
+		t.Fatalf("unexpected Want; got %s; want %s", wte.Want, WireTypeVarint)
+	}
+
+	// The same error must be reported via DecodeError with the field offset
+	if !errors.Is(err, ErrBadWireType) {
+		t.Fatalf("unexpected error kind; got %v; want %v", err, ErrBadWireType)
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expecting *DecodeError; got %T", err)
+	}
+	if de.Kind != ErrBadWireType || de.Offset != 0 || de.FieldNum != 42 || de.WireType != WireTypeLen {
+		t.Fatalf("unexpected DecodeError: %s", de)
+	}
+}
+
 func TestDecodeIntOverflow(t *testing.T) {
-	u64 := uint64(1<<64 - 1)
+	// This value cannot be represented neither as uint32 nor as sign-extended int32.
+	u64 := uint64(1 << 63)
 
 	m := mp.Get()
 	mm := m.MessageMarshaler()
@@ -1844,6 +2379,442 @@ func TestDecodeIntOverflow(t *testing.T) {
 	}
 }
 
+func TestInt32NegativeInterop(t *testing.T) {
+	f := func(data []byte, valueExpected int32) {
+		t.Helper()
+
+		var fc FieldContext
+		tail, err := fc.NextField(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(tail) != 0 {
+			t.Fatalf("unexpected non-empty tail; len(tail)=%d", len(tail))
+		}
+		i32, ok := fc.Int32()
+		if !ok {
+			t.Fatalf("cannot read int32")
+		}
+		if i32 != valueExpected {
+			t.Fatalf("unexpected int32; got %d; want %d", i32, valueExpected)
+		}
+		e, ok := fc.Enum()
+		if !ok {
+			t.Fatalf("cannot read enum")
+		}
+		if e != valueExpected {
+			t.Fatalf("unexpected enum; got %d; want %d", e, valueExpected)
+		}
+		i32s, ok := fc.UnpackInt32s(nil)
+		if !ok {
+			t.Fatalf("cannot unpack int32s")
+		}
+		if !reflect.DeepEqual(i32s, []int32{valueExpected}) {
+			t.Fatalf("unexpected int32s; got %d; want [%d]", i32s, valueExpected)
+		}
+		i32, ok, err = GetInt32(data, 1)
+		if err != nil || !ok {
+			t.Fatalf("cannot get int32; ok=%v, err=%v", ok, err)
+		}
+		if i32 != valueExpected {
+			t.Fatalf("unexpected GetInt32 result; got %d; want %d", i32, valueExpected)
+		}
+		e, ok, err = GetEnum(data, 1)
+		if err != nil || !ok {
+			t.Fatalf("cannot get enum; ok=%v, err=%v", ok, err)
+		}
+		if e != valueExpected {
+			t.Fatalf("unexpected GetEnum result; got %d; want %d", e, valueExpected)
+		}
+	}
+
+	// Canonical sign-extended encoding produced by protoc-generated code
+	f([]byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, -1)
+	f([]byte{0x08, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, -2)
+	f([]byte{0x08, 0x80, 0x80, 0x80, 0x80, 0xf8, 0xff, 0xff, 0xff, 0xff, 0x01}, -1<<31)
+
+	// Legacy 5-byte encoding produced by older easyproto versions
+	f([]byte{0x08, 0xff, 0xff, 0xff, 0xff, 0x0f}, -1)
+	f([]byte{0x08, 0x80, 0x80, 0x80, 0x80, 0x08}, -1<<31)
+
+	// Positive values
+	f([]byte{0x08, 0x00}, 0)
+	f([]byte{0x08, 0x96, 0x01}, 150)
+	f([]byte{0x08, 0xff, 0xff, 0xff, 0xff, 0x07}, 1<<31-1)
+}
+
+func TestInt32DecodeOverflow(t *testing.T) {
+	f := func(u64 uint64) {
+		t.Helper()
+
+		m := mp.Get()
+		mm := m.MessageMarshaler()
+		mm.AppendUint64(1, u64)
+		data := m.Marshal(nil)
+		mp.Put(m)
+
+		var fc FieldContext
+		if _, err := fc.NextField(data); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if i32, ok := fc.Int32(); ok {
+			t.Fatalf("expecting failure for Int32(); got %d", i32)
+		}
+		if e, ok := fc.Enum(); ok {
+			t.Fatalf("expecting failure for Enum(); got %d", e)
+		}
+		if i32s, ok := fc.UnpackInt32s(nil); ok {
+			t.Fatalf("expecting failure for UnpackInt32s(); got %d", i32s)
+		}
+		if _, _, err := GetInt32(data, 1); !errors.Is(err, ErrOverflow) {
+			t.Fatalf("expecting ErrOverflow from GetInt32(); got %v", err)
+		}
+		if _, _, err := GetEnum(data, 1); !errors.Is(err, ErrOverflow) {
+			t.Fatalf("expecting ErrOverflow from GetEnum(); got %v", err)
+		}
+	}
+
+	// Positive values above uint32max
+	f(1 << 32)
+	f(1 << 33)
+	f(1<<63 - 1)
+
+	// Sign-extended values below int32min
+	f(1 << 63)
+	i64 := int64(-1<<31 - 1)
+	f(uint64(i64))
+}
+
+func TestInt32NegativeMarshal(t *testing.T) {
+	f := func(value int32, dataExpected []byte) {
+		t.Helper()
+
+		m := mp.Get()
+		mm := m.MessageMarshaler()
+		mm.AppendInt32(1, value)
+		data := m.Marshal(nil)
+
+		m.Reset()
+		mm = m.MessageMarshaler()
+		mm.AppendInt32s(2, []int32{value})
+		dataPacked := m.Marshal(nil)
+		mp.Put(m)
+
+		if !bytes.Equal(data, dataExpected) {
+			t.Fatalf("unexpected data; got %X; want %X", data, dataExpected)
+		}
+
+		// The packed encoding must contain the same varint as the scalar encoding
+		dataPackedExpected := append([]byte{0x12, byte(len(dataExpected) - 1)}, dataExpected[1:]...)
+		if !bytes.Equal(dataPacked, dataPackedExpected) {
+			t.Fatalf("unexpected packed data; got %X; want %X", dataPacked, dataPackedExpected)
+		}
+	}
+
+	f(0, []byte{0x08, 0x00})
+	f(150, []byte{0x08, 0x96, 0x01})
+	f(-1, []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	f(-2, []byte{0x08, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	f(-1<<31, []byte{0x08, 0x80, 0x80, 0x80, 0x80, 0xf8, 0xff, 0xff, 0xff, 0xff, 0x01})
+}
+
+func TestRawFieldRoundTrip(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(1, -123)
+	mm.AppendString(2, "foo")
+	mm.AppendFixed64(3, 12345)
+	mm.AppendFixed32(4, 42)
+	mm.AppendDouble(5, 1.5)
+	mmChild := mm.AppendMessage(6)
+	mmChild.AppendBool(1, true)
+	mmGroup := mm.AppendGroup(7)
+	mmGroup.AppendUint32(1, 10)
+	mm.AppendString(300, "long tag")
+	data := m.Marshal(nil)
+
+	// Re-encode the message with the modified field #2, while passing through the rest of fields.
+	var m2 Marshaler
+	mm = m2.MessageMarshaler()
+	var fc FieldContext
+	src := data
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if fc.FieldNum == 2 {
+			mm.AppendString(2, "bar")
+			continue
+		}
+		mm.AppendRawField(fc.RawField())
+	}
+	result := m2.Marshal(nil)
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	mm.AppendInt64(1, -123)
+	mm.AppendString(2, "bar")
+	mm.AppendFixed64(3, 12345)
+	mm.AppendFixed32(4, 42)
+	mm.AppendDouble(5, 1.5)
+	mmChild = mm.AppendMessage(6)
+	mmChild.AppendBool(1, true)
+	mmGroup = mm.AppendGroup(7)
+	mmGroup.AppendUint32(1, 10)
+	mm.AppendString(300, "long tag")
+	resultExpected := m.Marshal(nil)
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+	}
+}
+
+func TestRawFieldFieldByNum(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendInt32(1, 12)
+	mm.AppendString(2, "foo")
+	data := m.Marshal(nil)
+
+	var fc FieldContext
+	ok, err := fc.FieldByNum(data, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !ok {
+		t.Fatalf("cannot find field #2")
+	}
+	rawFieldExpected := []byte{0x12, 0x03, 'f', 'o', 'o'}
+	if rawField := fc.RawField(); !bytes.Equal(rawField, rawFieldExpected) {
+		t.Fatalf("unexpected raw field; got %X; want %X", rawField, rawFieldExpected)
+	}
+}
+
+func TestAppendMessageBytes(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendUint64(2, 1234)
+	msgData := m.Marshal(nil)
+
+	f := func(fieldNum uint32, data []byte) {
+		t.Helper()
+
+		// Embed the message via AppendMessageBytes
+		m.Reset()
+		mm := m.MessageMarshaler()
+		mm.AppendInt32(1, 10)
+		mm.AppendMessageBytes(fieldNum, data)
+		mm.AppendInt32(3, 20)
+		result := m.Marshal(nil)
+
+		// Embed the message via AppendBytes, which gives the same encoding.
+		m.Reset()
+		mm = m.MessageMarshaler()
+		mm.AppendInt32(1, 10)
+		mm.AppendBytes(fieldNum, data)
+		mm.AppendInt32(3, 20)
+		resultExpected := m.Marshal(nil)
+
+		if !bytes.Equal(result, resultExpected) {
+			t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+		}
+
+		var fc FieldContext
+		ok, err := fc.FieldByNum(result, fieldNum)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !ok {
+			t.Fatalf("cannot find field #%d", fieldNum)
+		}
+		msgData, ok := fc.MessageData()
+		if !ok {
+			t.Fatalf("cannot obtain message data")
+		}
+		if !bytes.Equal(msgData, data) {
+			t.Fatalf("unexpected message data; got %X; want %X", msgData, data)
+		}
+	}
+
+	f(2, nil)
+	f(2, msgData)
+	f(300, msgData)
+	f(2, bytes.Repeat(msgData, 100))
+}
+
+func TestAppendMessageBytesValidated(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	msgData := m.Marshal(nil)
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	if err := mm.AppendMessageBytesValidated(1, msgData, ValidateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Invalid message mustn't be appended
+	err := mm.AppendMessageBytesValidated(2, []byte{0x0a, 0x05, 'f'}, ValidateOptions{})
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expecting *ValidationError; got %v", err)
+	}
+
+	// Too big message mustn't be appended
+	err = mm.AppendMessageBytesValidated(3, msgData, ValidateOptions{
+		MaxSize: 2,
+	})
+	if !errors.As(err, &ve) {
+		t.Fatalf("expecting *ValidationError; got %v", err)
+	}
+
+	result := m.Marshal(nil)
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	mm.AppendBytes(1, msgData)
+	resultExpected := m.Marshal(nil)
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+	}
+}
+
+func TestMarshalDeterministic(t *testing.T) {
+	f := func(appendFields func(mm *MessageMarshaler), resultExpected []byte) {
+		t.Helper()
+
+		m := mp.Get()
+		m.SetDeterministic(true)
+		appendFields(m.MessageMarshaler())
+		result := m.Marshal(nil)
+		if !bytes.Equal(result, resultExpected) {
+			t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+		}
+
+		// Verify that the second call returns the same result
+		result = m.Marshal(nil)
+		if !bytes.Equal(result, resultExpected) {
+			t.Fatalf("unexpected result on the second call\ngot\n%X\nwant\n%X", result, resultExpected)
+		}
+
+		resultWithLen := m.MarshalWithLen(nil)
+		resultWithLenExpected := marshalVarUint64(nil, uint64(len(resultExpected)))
+		resultWithLenExpected = append(resultWithLenExpected, resultExpected...)
+		if !bytes.Equal(resultWithLen, resultWithLenExpected) {
+			t.Fatalf("unexpected result with len\ngot\n%X\nwant\n%X", resultWithLen, resultWithLenExpected)
+		}
+		mp.Put(m)
+	}
+
+	// Marshal the expected message with the fields appended in the ascending order
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendInt32(1, 10)
+	mm.AppendString(2, "foo")
+	mm.AppendString(2, "bar")
+	mmChild := mm.AppendMessage(3)
+	mmChild.AppendBool(1, true)
+	mmChild.AppendDouble(2, 1.5)
+	mm.AppendUint64s(4, []uint64{3, 1, 2})
+	mmGroup := mm.AppendGroup(5)
+	mmGroup.AppendFixed32(1, 42)
+	mmGroup.AppendFixed64(7, 43)
+	mm.AppendSint64(300, -1)
+	resultExpected := m.Marshal(nil)
+
+	// fields in the ascending order
+	f(func(mm *MessageMarshaler) {
+		mm.AppendInt32(1, 10)
+		mm.AppendString(2, "foo")
+		mm.AppendString(2, "bar")
+		mmChild := mm.AppendMessage(3)
+		mmChild.AppendBool(1, true)
+		mmChild.AppendDouble(2, 1.5)
+		mm.AppendUint64s(4, []uint64{3, 1, 2})
+		mmGroup := mm.AppendGroup(5)
+		mmGroup.AppendFixed32(1, 42)
+		mmGroup.AppendFixed64(7, 43)
+		mm.AppendSint64(300, -1)
+	}, resultExpected)
+
+	// fields in the mixed order
+	f(func(mm *MessageMarshaler) {
+		mm.AppendSint64(300, -1)
+		mmGroup := mm.AppendGroup(5)
+		mmGroup.AppendFixed64(7, 43)
+		mmGroup.AppendFixed32(1, 42)
+		mm.AppendString(2, "foo")
+		mm.AppendUint64s(4, []uint64{3, 1, 2})
+		mmChild := mm.AppendMessage(3)
+		mmChild.AppendDouble(2, 1.5)
+		mmChild.AppendBool(1, true)
+		mm.AppendString(2, "bar")
+		mm.AppendInt32(1, 10)
+	}, resultExpected)
+
+	// raw fields in the mixed order
+	f(func(mm *MessageMarshaler) {
+		var fc FieldContext
+		src := resultExpected
+		var rawFields [][]byte
+		for len(src) > 0 {
+			var err error
+			src, err = fc.NextField(src)
+			if err != nil {
+				panic(fmt.Errorf("unexpected error: %w", err))
+			}
+			rawFields = append(rawFields, fc.RawField())
+		}
+		// Move the first three fields to the end, so the order for repeated fields with fieldNum=2 is preserved.
+		for _, rawField := range append(rawFields[3:], rawFields[:3]...) {
+			mm.AppendRawField(rawField)
+		}
+	}, resultExpected)
+}
+
+func TestMarshalDeterministicMap(t *testing.T) {
+	m := map[string]string{
+		"c": "3",
+		"a": "1",
+		"b": "2",
+		"d": "4",
+	}
+
+	var mr Marshaler
+	mr.SetDeterministic(true)
+	mm := mr.MessageMarshaler()
+	mm.AppendStringStringMap(1, m, false)
+	mm.AppendInt32(2, 123)
+	result := mr.Marshal(nil)
+
+	mr.Reset()
+	mm = mr.MessageMarshaler()
+	mm.AppendStringStringMap(1, m, true)
+	mm.AppendInt32(2, 123)
+	resultExpected := mr.Marshal(nil)
+
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+	}
+}
+
+func TestMarshalDeterministicReset(t *testing.T) {
+	var m Marshaler
+	m.SetDeterministic(true)
+	m.Reset()
+	mm := m.MessageMarshaler()
+	mm.AppendInt32(2, 1)
+	mm.AppendInt32(1, 2)
+	result := m.Marshal(nil)
+	resultExpected := []byte{0x10, 0x01, 0x08, 0x02}
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+	}
+}
+
 func TestUnmarshalWithoutMessageMarshaler(t *testing.T) {
 	m := mp.Get()
 	data := m.Marshal(nil)
@@ -1909,6 +2880,46 @@ func TestDecodeArrayInvalidVarint(t *testing.T) {
 	if _, ok := fc.UnpackFloats(nil); ok {
 		t.Fatalf("expecting non-nil err")
 	}
+
+	if fc.RangeInt32s(func(_ int32) bool { return true }) {
+		t.Fatalf("expecting RangeInt32s failure")
+	}
+	if fc.RangeInt64s(func(_ int64) bool { return true }) {
+		t.Fatalf("expecting RangeInt64s failure")
+	}
+	if fc.RangeUint32s(func(_ uint32) bool { return true }) {
+		t.Fatalf("expecting RangeUint32s failure")
+	}
+	if fc.RangeUint64s(func(_ uint64) bool { return true }) {
+		t.Fatalf("expecting RangeUint64s failure")
+	}
+	if fc.RangeSint32s(func(_ int32) bool { return true }) {
+		t.Fatalf("expecting RangeSint32s failure")
+	}
+	if fc.RangeSint64s(func(_ int64) bool { return true }) {
+		t.Fatalf("expecting RangeSint64s failure")
+	}
+	if fc.RangeBools(func(_ bool) bool { return true }) {
+		t.Fatalf("expecting RangeBools failure")
+	}
+	if fc.RangeFixed64s(func(_ uint64) bool { return true }) {
+		t.Fatalf("expecting RangeFixed64s failure")
+	}
+	if fc.RangeSfixed64s(func(_ int64) bool { return true }) {
+		t.Fatalf("expecting RangeSfixed64s failure")
+	}
+	if fc.RangeDoubles(func(_ float64) bool { return true }) {
+		t.Fatalf("expecting RangeDoubles failure")
+	}
+	if fc.RangeFixed32s(func(_ uint32) bool { return true }) {
+		t.Fatalf("expecting RangeFixed32s failure")
+	}
+	if fc.RangeSfixed32s(func(_ int32) bool { return true }) {
+		t.Fatalf("expecting RangeSfixed32s failure")
+	}
+	if fc.RangeFloats(func(_ float32) bool { return true }) {
+		t.Fatalf("expecting RangeFloats failure")
+	}
 }
 
 func TestMarshalWithLen(t *testing.T) {
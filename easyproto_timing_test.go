@@ -29,6 +29,66 @@ func BenchmarkUnpackUint64s(b *testing.B) {
 	})
 }
 
+func BenchmarkUnpackUint64sNilDst(b *testing.B) {
+	m := mp.Get()
+	defer mp.Put(m)
+
+	const fieldNum = 1
+	values := make([]uint64, 1000)
+	for i := range values {
+		values[i] = uint64(i)
+	}
+	mm := m.MessageMarshaler()
+	mm.AppendUint64s(fieldNum, values)
+	buf := m.Marshal(nil)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			dst, err := UnpackUint64s(buf, fieldNum, nil)
+			if err != nil {
+				panic(fmt.Errorf("unexpected error: %s", err))
+			}
+			if len(dst) != len(values) {
+				panic(fmt.Errorf("unexpected number of values; got %d; want %d", len(dst), len(values)))
+			}
+		}
+	})
+}
+
+func BenchmarkRangeUint64s(b *testing.B) {
+	m := mp.Get()
+	defer mp.Put(m)
+
+	const fieldNum = 1
+	mm := m.MessageMarshaler()
+	mm.AppendUint64s(fieldNum, make([]uint64, 1000))
+	buf := m.Marshal(nil)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	b.RunParallel(func(pb *testing.PB) {
+		var fc FieldContext
+		sum := uint64(0)
+		for pb.Next() {
+			if _, err := fc.NextField(buf); err != nil {
+				panic(fmt.Errorf("unexpected error: %s", err))
+			}
+			ok := fc.RangeUint64s(func(v uint64) bool {
+				sum += v
+				return true
+			})
+			if !ok {
+				panic(fmt.Errorf("cannot range uint64 values"))
+			}
+		}
+		if sum != 0 {
+			panic(fmt.Errorf("unexpected sum; got %d; want 0", sum))
+		}
+	})
+}
+
 func BenchmarkMarshalComplexMessage(b *testing.B) {
 	const seriesCount = 1_000
 
@@ -251,3 +311,24 @@ func unmarshalComplexMessage(src []byte) {
 }
 
 var writeRequestPool sync.Pool
+
+func BenchmarkHash64ManyFields(b *testing.B) {
+	m := mp.Get()
+	defer mp.Put(m)
+
+	mm := m.MessageMarshaler()
+	for i := 5000; i > 0; i-- {
+		mm.AppendUint32(uint32(i), uint32(i))
+	}
+	buf := m.Marshal(nil)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := Hash64(buf); err != nil {
+				panic(fmt.Errorf("unexpected error: %s", err))
+			}
+		}
+	})
+}
@@ -0,0 +1,469 @@
+package easyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Equal returns true if protobuf-encoded messages a and b are semantically equal.
+//
+// The messages are compared without the need in message schema according to the following rules:
+//
+//   - Fields with distinct field numbers may be located in arbitrary order.
+//   - The order of values for the field with the same field number is preserved.
+//   - Packed and unpacked repeated scalars are equal if they contain the same values.
+//   - Embedded messages and groups are compared recursively according to these rules.
+//
+// Since the wire format doesn't distinguish embedded messages from strings and packed scalars,
+// length-delimited values are treated as embedded messages if they can be parsed as messages.
+// Length-delimited values are compared as packed scalars in the following cases:
+//
+//   - The field has unpacked scalar values in the same message.
+//   - All the values for the field can be decoded as packed varints and none of them can be parsed
+//     as message with multiple fields or with non-varint field. Such values are merged into a single stream,
+//     so a packed field split into multiple chunks is equal to the same field in a single chunk.
+//   - The field has a single value, which cannot be parsed as message.
+//
+// An error is returned if a or b contains invalid protobuf message.
+//
+// See also Hash64.
+func Equal(a, b []byte) (bool, error) {
+	if err := checkMessage(a); err != nil {
+		return false, fmt.Errorf("cannot parse the first message: %w", err)
+	}
+	if err := checkMessage(b); err != nil {
+		return false, fmt.Errorf("cannot parse the second message: %w", err)
+	}
+	return equalMessages(a, b, 0), nil
+}
+
+// Hash64 returns 64-bit hash for protobuf-encoded message at src.
+//
+// The hash is calculated over the canonical representation of the message,
+// so messages, which are equal according to Equal(), have the same hash.
+//
+// An error is returned if src contains invalid protobuf message.
+func Hash64(src []byte) (uint64, error) {
+	if err := checkMessage(src); err != nil {
+		return 0, err
+	}
+	return hashMessage(src, 0), nil
+}
+
+// maxCanonicalDepth is the maximum nesting depth for embedded messages processed by Equal and Hash64.
+//
+// Deeper length-delimited values are treated as bytes and deeper groups are treated as raw group bodies.
+const maxCanonicalDepth = 100
+
+// checkMessage returns an error if src cannot be parsed as protobuf message.
+func checkMessage(src []byte) error {
+	fc := getFieldContext()
+	defer putFieldContext(fc)
+
+	srcOrig := src
+	for len(src) > 0 {
+		var err error
+		tail := src
+		src, err = fc.NextField(src)
+		if err != nil {
+			addErrorOffset(err, len(srcOrig)-len(tail))
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+	}
+	return nil
+}
+
+// isCanonicalMessage returns true if the length-delimited data must be treated as embedded message at the given depth.
+//
+// Data with zero field numbers isn't treated as message, since valid messages cannot contain such fields.
+func isCanonicalMessage(data []byte, depth int) bool {
+	if len(data) == 0 || depth >= maxCanonicalDepth {
+		return false
+	}
+	_, _, se := scanFields(data, 0, 0, true)
+	return se.kind == nil
+}
+
+// canonicalField is a field collected by getCanonicalFields.
+type canonicalField struct {
+	// fieldNum is the field number.
+	fieldNum uint32
+
+	// wireType is the wire type of the field.
+	wireType WireType
+
+	// idx is the position of the field in the message. It is used for stable sorting of fields.
+	idx int
+
+	// intValue is the value for scalar fields.
+	intValue uint64
+
+	// data is the value for length-delimited fields and the body for groups.
+	data []byte
+}
+
+// canonicalFields contains message fields sorted by field number.
+//
+// The order of fields with the same field number is preserved.
+type canonicalFields struct {
+	a []canonicalField
+}
+
+func (cf *canonicalFields) Len() int {
+	return len(cf.a)
+}
+
+func (cf *canonicalFields) Less(i, j int) bool {
+	a := cf.a
+	if a[i].fieldNum != a[j].fieldNum {
+		return a[i].fieldNum < a[j].fieldNum
+	}
+	return a[i].idx < a[j].idx
+}
+
+func (cf *canonicalFields) Swap(i, j int) {
+	a := cf.a
+	a[i], a[j] = a[j], a[i]
+}
+
+// getCanonicalFields returns fields from src sorted by field number.
+//
+// src must contain valid protobuf message.
+//
+// Return the result to the pool via putCanonicalFields when it is no longer needed.
+func getCanonicalFields(src []byte) *canonicalFields {
+	v := canonicalFieldsPool.Get()
+	if v == nil {
+		v = &canonicalFields{}
+	}
+	cf := v.(*canonicalFields)
+
+	var fc FieldContext
+	a := cf.a[:0]
+	for len(src) > 0 {
+		src, _ = fc.NextField(src)
+		a = append(a, canonicalField{
+			fieldNum: fc.FieldNum,
+			wireType: fc.wireType,
+			idx:      len(a),
+			intValue: fc.intValue,
+			data:     fc.data,
+		})
+	}
+	cf.a = a
+	if !sort.IsSorted(cf) {
+		sort.Sort(cf)
+	}
+	return cf
+}
+
+func putCanonicalFields(cf *canonicalFields) {
+	a := cf.a
+	for i := range a {
+		// Remove references to external byte slices, so GC could release them.
+		a[i].data = nil
+	}
+	cf.a = a[:0]
+	canonicalFieldsPool.Put(cf)
+}
+
+var canonicalFieldsPool sync.Pool
+
+// nextFieldGroup returns the number of fields at the start of fields, which share the same field number.
+func nextFieldGroup(fields []canonicalField) int {
+	n := 1
+	for n < len(fields) && fields[n].fieldNum == fields[0].fieldNum {
+		n++
+	}
+	return n
+}
+
+const (
+	// fieldKindStream is the kind for fields with scalar values, which are compared as a stream of encoded values.
+	//
+	// This allows treating packed and unpacked scalars as equal.
+	fieldKindStream = iota
+
+	// fieldKindValues is the kind for fields with length-delimited values and groups, which are compared value by value.
+	fieldKindValues
+)
+
+// getFieldKind returns the kind for the given values of a single field.
+func getFieldKind(fields []canonicalField, depth int) int {
+	isPacked := true
+	for i := range fields {
+		f := &fields[i]
+		switch f.wireType {
+		case WireTypeVarint, WireTypeI64, WireTypeI32:
+			return fieldKindStream
+		case WireTypeLen:
+			if isPacked && !isPackedVarintsValue(f.data, depth) {
+				isPacked = false
+			}
+		default:
+			isPacked = false
+		}
+	}
+	if isPacked {
+		return fieldKindStream
+	}
+	if len(fields) == 1 && fields[0].wireType == WireTypeLen && !isCanonicalMessage(fields[0].data, depth+1) {
+		// A single length-delimited value, which isn't a message, may contain packed fixed-size scalars.
+		return fieldKindStream
+	}
+	return fieldKindValues
+}
+
+// isPackedVarintsValue returns true if the length-delimited data must be treated as packed varints at the given depth.
+//
+// Packed varints may be also parsed as message with a single varint field, e.g. packed [8, 1] is parsed as message
+// with the field 1 set to 1. It is safe to treat such messages as packed varints, since they have no fields, which could be reordered.
+func isPackedVarintsValue(data []byte, depth int) bool {
+	if !isPackedVarints(data) {
+		return false
+	}
+	if !isCanonicalMessage(data, depth+1) {
+		return true
+	}
+	tag, n := binary.Uvarint(data)
+	if WireType(tag&0x07) != WireTypeVarint {
+		return false
+	}
+	_, m := binary.Uvarint(data[n:])
+	return m > 0 && n+m == len(data)
+}
+
+// isPackedVarints returns true if non-empty data consists of valid varints.
+func isPackedVarints(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	n := 0
+	for _, c := range data {
+		if c < 0x80 {
+			n = 0
+			continue
+		}
+		n++
+		if n >= binary.MaxVarintLen64 {
+			return false
+		}
+	}
+	return n == 0
+}
+
+// fieldStream iterates over the encoded values of a single field.
+//
+// Length-delimited values are returned as is, while scalar values are re-encoded,
+// so packed and unpacked scalars result in the same stream.
+type fieldStream struct {
+	// fields contains the remaining field values.
+	fields []canonicalField
+
+	// buf is a buffer for encoding scalar values.
+	buf [binary.MaxVarintLen64]byte
+}
+
+// next returns the next chunk of the stream.
+//
+// False is returned if there are no more chunks.
+func (fs *fieldStream) next() ([]byte, bool) {
+	if len(fs.fields) == 0 {
+		return nil, false
+	}
+	f := &fs.fields[0]
+	fs.fields = fs.fields[1:]
+	switch f.wireType {
+	case WireTypeVarint:
+		n := binary.PutUvarint(fs.buf[:], f.intValue)
+		return fs.buf[:n], true
+	case WireTypeI64:
+		binary.LittleEndian.PutUint64(fs.buf[:], f.intValue)
+		return fs.buf[:8], true
+	case WireTypeI32:
+		binary.LittleEndian.PutUint32(fs.buf[:], uint32(f.intValue))
+		return fs.buf[:4], true
+	default:
+		return f.data, true
+	}
+}
+
+// isCanonicalValueMessage returns true if the value of f must be treated as embedded message at the given depth.
+func isCanonicalValueMessage(f *canonicalField, depth int) bool {
+	if f.wireType == WireTypeSGroup {
+		return depth+1 < maxCanonicalDepth
+	}
+	return isCanonicalMessage(f.data, depth+1)
+}
+
+func equalMessages(a, b []byte, depth int) bool {
+	cfA := getCanonicalFields(a)
+	cfB := getCanonicalFields(b)
+	ok := equalCanonicalFields(cfA.a, cfB.a, depth)
+	putCanonicalFields(cfA)
+	putCanonicalFields(cfB)
+	return ok
+}
+
+func equalCanonicalFields(fieldsA, fieldsB []canonicalField, depth int) bool {
+	for len(fieldsA) > 0 && len(fieldsB) > 0 {
+		if fieldsA[0].fieldNum != fieldsB[0].fieldNum {
+			return false
+		}
+		nA := nextFieldGroup(fieldsA)
+		nB := nextFieldGroup(fieldsB)
+		valuesA := fieldsA[:nA]
+		valuesB := fieldsB[:nB]
+		fieldsA = fieldsA[nA:]
+		fieldsB = fieldsB[nB:]
+
+		kind := getFieldKind(valuesA, depth)
+		if kind != getFieldKind(valuesB, depth) {
+			return false
+		}
+		if kind == fieldKindStream {
+			if !equalFieldStreams(valuesA, valuesB) {
+				return false
+			}
+		} else if !equalFieldValues(valuesA, valuesB, depth) {
+			return false
+		}
+	}
+	return len(fieldsA) == len(fieldsB)
+}
+
+func equalFieldStreams(valuesA, valuesB []canonicalField) bool {
+	fsA := fieldStream{
+		fields: valuesA,
+	}
+	fsB := fieldStream{
+		fields: valuesB,
+	}
+	var chunkA, chunkB []byte
+	for {
+		if len(chunkA) == 0 {
+			var ok bool
+			for len(chunkA) == 0 {
+				if chunkA, ok = fsA.next(); !ok {
+					break
+				}
+			}
+		}
+		if len(chunkB) == 0 {
+			var ok bool
+			for len(chunkB) == 0 {
+				if chunkB, ok = fsB.next(); !ok {
+					break
+				}
+			}
+		}
+		if len(chunkA) == 0 || len(chunkB) == 0 {
+			return len(chunkA) == len(chunkB)
+		}
+		n := len(chunkA)
+		if n > len(chunkB) {
+			n = len(chunkB)
+		}
+		if !bytes.Equal(chunkA[:n], chunkB[:n]) {
+			return false
+		}
+		chunkA = chunkA[n:]
+		chunkB = chunkB[n:]
+	}
+}
+
+func equalFieldValues(valuesA, valuesB []canonicalField, depth int) bool {
+	if len(valuesA) != len(valuesB) {
+		return false
+	}
+	for i := range valuesA {
+		fA := &valuesA[i]
+		fB := &valuesB[i]
+		isMessageA := isCanonicalValueMessage(fA, depth)
+		if isMessageA != isCanonicalValueMessage(fB, depth) {
+			return false
+		}
+		if isMessageA {
+			if !equalMessages(fA.data, fB.data, depth+1) {
+				return false
+			}
+		} else if !bytes.Equal(fA.data, fB.data) {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	// hashOffset64 is the initial value for FNV-1a 64-bit hash.
+	hashOffset64 = 14695981039346656037
+
+	// hashPrime64 is the prime for FNV-1a 64-bit hash.
+	hashPrime64 = 1099511628211
+)
+
+func hashBytes(h uint64, b []byte) uint64 {
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= hashPrime64
+	}
+	return h
+}
+
+func hashUint64(h, u64 uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		h ^= u64 & 0xff
+		h *= hashPrime64
+		u64 >>= 8
+	}
+	return h
+}
+
+func hashMessage(src []byte, depth int) uint64 {
+	cf := getCanonicalFields(src)
+	h := hashCanonicalFields(cf.a, depth)
+	putCanonicalFields(cf)
+	return h
+}
+
+func hashCanonicalFields(fields []canonicalField, depth int) uint64 {
+	h := uint64(hashOffset64)
+	for len(fields) > 0 {
+		n := nextFieldGroup(fields)
+		values := fields[:n]
+		fields = fields[n:]
+
+		h = hashUint64(h, uint64(values[0].fieldNum))
+		kind := getFieldKind(values, depth)
+		h = hashUint64(h, uint64(kind))
+		if kind == fieldKindStream {
+			fs := fieldStream{
+				fields: values,
+			}
+			for {
+				chunk, ok := fs.next()
+				if !ok {
+					break
+				}
+				h = hashBytes(h, chunk)
+			}
+			continue
+		}
+
+		for i := range values {
+			f := &values[i]
+			if isCanonicalValueMessage(f, depth) {
+				h = hashUint64(h, 1)
+				h = hashUint64(h, hashMessage(f.data, depth+1))
+			} else {
+				h = hashUint64(h, 0)
+				h = hashUint64(h, uint64(len(f.data)))
+				h = hashBytes(h, f.data)
+			}
+		}
+	}
+	return h
+}
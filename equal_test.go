@@ -0,0 +1,319 @@
+package easyproto
+
+import (
+	"testing"
+)
+
+func TestEqualHash64(t *testing.T) {
+	marshal := func(appendFields func(mm *MessageMarshaler)) []byte {
+		var m Marshaler
+		appendFields(m.MessageMarshaler())
+		return m.Marshal(nil)
+	}
+
+	f := func(a, b func(mm *MessageMarshaler), equalExpected bool) {
+		t.Helper()
+
+		dataA := marshal(a)
+		dataB := marshal(b)
+		equal, err := Equal(dataA, dataB)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if equal != equalExpected {
+			t.Fatalf("unexpected Equal result for\n%X\nand\n%X\ngot %v; want %v", dataA, dataB, equal, equalExpected)
+		}
+		equal, err = Equal(dataB, dataA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if equal != equalExpected {
+			t.Fatalf("unexpected Equal result for swapped args\n%X\nand\n%X\ngot %v; want %v", dataB, dataA, equal, equalExpected)
+		}
+
+		hashA, err := Hash64(dataA)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		hashB, err := Hash64(dataB)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if equalExpected && hashA != hashB {
+			t.Fatalf("unexpected hash mismatch for equal messages\n%X\nand\n%X", dataA, dataB)
+		}
+		if !equalExpected && hashA == hashB {
+			t.Fatalf("unexpected hash collision for distinct messages\n%X\nand\n%X", dataA, dataB)
+		}
+	}
+
+	// empty messages
+	f(func(mm *MessageMarshaler) {}, func(mm *MessageMarshaler) {}, true)
+
+	// empty and non-empty message
+	f(func(mm *MessageMarshaler) {}, func(mm *MessageMarshaler) {
+		mm.AppendInt32(1, 0)
+	}, false)
+
+	// different field order
+	f(func(mm *MessageMarshaler) {
+		mm.AppendInt32(1, 123)
+		mm.AppendString(2, "foo")
+		mm.AppendDouble(3, 1.5)
+	}, func(mm *MessageMarshaler) {
+		mm.AppendDouble(3, 1.5)
+		mm.AppendString(2, "foo")
+		mm.AppendInt32(1, 123)
+	}, true)
+
+	// different values
+	f(func(mm *MessageMarshaler) {
+		mm.AppendInt32(1, 123)
+		mm.AppendString(2, "foo")
+	}, func(mm *MessageMarshaler) {
+		mm.AppendInt32(1, 123)
+		mm.AppendString(2, "bar")
+	}, false)
+
+	// different field numbers
+	f(func(mm *MessageMarshaler) {
+		mm.AppendInt32(1, 123)
+	}, func(mm *MessageMarshaler) {
+		mm.AppendInt32(2, 123)
+	}, false)
+
+	// the order of repeated values is preserved
+	f(func(mm *MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mm.AppendString(1, "bar")
+	}, func(mm *MessageMarshaler) {
+		mm.AppendString(1, "bar")
+		mm.AppendString(1, "foo")
+	}, false)
+
+	// repeated values interleaved with other fields
+	f(func(mm *MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mm.AppendInt32(2, 1)
+		mm.AppendString(1, "bar")
+	}, func(mm *MessageMarshaler) {
+		mm.AppendInt32(2, 1)
+		mm.AppendString(1, "foo")
+		mm.AppendString(1, "bar")
+	}, true)
+
+	// string boundaries are preserved for strings, which cannot be decoded as packed varints
+	f(func(mm *MessageMarshaler) {
+		mm.AppendString(1, "aé")
+		mm.AppendString(1, "cé")
+	}, func(mm *MessageMarshaler) {
+		mm.AppendString(1, "a")
+		mm.AppendString(1, "écé")
+	}, false)
+
+	// packed and unpacked varints
+	f(func(mm *MessageMarshaler) {
+		mm.AppendUint64s(1, []uint64{1, 200, 3})
+	}, func(mm *MessageMarshaler) {
+		mm.AppendUint64(1, 1)
+		mm.AppendUint64(1, 200)
+		mm.AppendUint64(1, 3)
+	}, true)
+
+	// packed varints, which can be parsed as message
+	f(func(mm *MessageMarshaler) {
+		mm.AppendUint64s(1, []uint64{8, 1})
+		mm.AppendUint64(1, 5)
+	}, func(mm *MessageMarshaler) {
+		mm.AppendUint64(1, 8)
+		mm.AppendUint64(1, 1)
+		mm.AppendUint64s(1, []uint64{5})
+	}, true)
+
+	// single packed chunk, which can be parsed as message, and unpacked varints
+	f(func(mm *MessageMarshaler) {
+		mm.AppendInt32s(1, []int32{8, 1})
+	}, func(mm *MessageMarshaler) {
+		mm.AppendInt32(1, 8)
+		mm.AppendInt32(1, 1)
+	}, true)
+
+	// multiple packed chunks and a single packed chunk
+	f(func(mm *MessageMarshaler) {
+		mm.AppendInt32s(1, []int32{1000, 2000})
+		mm.AppendInt32s(1, []int32{3000})
+	}, func(mm *MessageMarshaler) {
+		mm.AppendInt32s(1, []int32{1000, 2000, 3000})
+	}, true)
+
+	// multiple packed chunks with distinct values
+	f(func(mm *MessageMarshaler) {
+		mm.AppendInt32s(1, []int32{1000, 2000})
+		mm.AppendInt32s(1, []int32{3000})
+	}, func(mm *MessageMarshaler) {
+		mm.AppendInt32s(1, []int32{1000, 3000, 2000})
+	}, false)
+
+	// packed and unpacked fixed64
+	f(func(mm *MessageMarshaler) {
+		mm.AppendDoubles(1, []float64{1.5, -2.25})
+	}, func(mm *MessageMarshaler) {
+		mm.AppendDouble(1, 1.5)
+		mm.AppendDouble(1, -2.25)
+	}, true)
+
+	// packed and unpacked fixed32
+	f(func(mm *MessageMarshaler) {
+		mm.AppendFixed32(1, 10)
+		mm.AppendFixed32s(1, []uint32{20, 30})
+	}, func(mm *MessageMarshaler) {
+		mm.AppendFixed32s(1, []uint32{10, 20})
+		mm.AppendFixed32(1, 30)
+	}, true)
+
+	// packed values with different order
+	f(func(mm *MessageMarshaler) {
+		mm.AppendUint64s(1, []uint64{1, 2})
+	}, func(mm *MessageMarshaler) {
+		mm.AppendUint64(1, 2)
+		mm.AppendUint64(1, 1)
+	}, false)
+
+	// embedded messages with different field order
+	f(func(mm *MessageMarshaler) {
+		mmChild := mm.AppendMessage(1)
+		mmChild.AppendInt32(1, 10)
+		mmChild.AppendString(2, "foo")
+		mmGrandChild := mmChild.AppendMessage(3)
+		mmGrandChild.AppendBool(1, true)
+		mmGrandChild.AppendSint64(2, -1)
+		mm.AppendInt32(2, 3)
+	}, func(mm *MessageMarshaler) {
+		mm.AppendInt32(2, 3)
+		mmChild := mm.AppendMessage(1)
+		mmGrandChild := mmChild.AppendMessage(3)
+		mmGrandChild.AppendSint64(2, -1)
+		mmGrandChild.AppendBool(1, true)
+		mmChild.AppendString(2, "foo")
+		mmChild.AppendInt32(1, 10)
+	}, true)
+
+	// embedded messages with different values
+	f(func(mm *MessageMarshaler) {
+		mmChild := mm.AppendMessage(1)
+		mmChild.AppendInt32(1, 10)
+		mmChild.AppendString(2, "foo")
+	}, func(mm *MessageMarshaler) {
+		mmChild := mm.AppendMessage(1)
+		mmChild.AppendString(2, "foo")
+		mmChild.AppendInt32(1, 11)
+	}, false)
+
+	// groups with different field order
+	f(func(mm *MessageMarshaler) {
+		mmGroup := mm.AppendGroup(1)
+		mmGroup.AppendInt32(1, 10)
+		mmGroup.AppendFixed64(2, 20)
+	}, func(mm *MessageMarshaler) {
+		mmGroup := mm.AppendGroup(1)
+		mmGroup.AppendFixed64(2, 20)
+		mmGroup.AppendInt32(1, 10)
+	}, true)
+
+	// packed field and string
+	f(func(mm *MessageMarshaler) {
+		mm.AppendString(1, "abc")
+	}, func(mm *MessageMarshaler) {
+		mm.AppendString(1, "abd")
+	}, false)
+}
+
+func TestEqualHash64Failure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+
+		if _, err := Equal(data, nil); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if _, err := Equal(nil, data); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if _, err := Hash64(data); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// truncated varint
+	f([]byte{0x08, 0x80})
+
+	// truncated length-delimited field
+	f([]byte{0x0a, 0x05, 'f'})
+
+	// missing end group tag
+	f([]byte{0x0b, 0x08, 0x01})
+}
+
+func TestEqualHash64ZeroAlloc(t *testing.T) {
+	if isRaceEnabled {
+		t.Skip("skipping allocation test, since the race detector makes sync.Pool to drop items randomly")
+	}
+
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendInt32(1, 123)
+	mm.AppendString(2, "foo")
+	mm.AppendUint64s(3, []uint64{1, 2, 3})
+	mmChild := mm.AppendMessage(4)
+	mmChild.AppendDouble(1, 1.5)
+	mmChild.AppendString(2, "bar")
+	data := m.Marshal(nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := Equal(data, data); err != nil {
+			panic(err)
+		}
+		if _, err := Hash64(data); err != nil {
+			panic(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("unexpected allocations; got %v; want 0", allocs)
+	}
+}
+
+func TestEqualHash64ManyFields(t *testing.T) {
+	// Messages with many distinct fields must be processed in linear time.
+	const fieldsCount = 100_000
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	for i := fieldsCount; i > 0; i-- {
+		mm.AppendUint32(uint32(i), uint32(i))
+	}
+	a := m.Marshal(nil)
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	for i := 1; i <= fieldsCount; i++ {
+		mm.AppendUint32(uint32(i), uint32(i))
+	}
+	b := m.Marshal(nil)
+
+	equal, err := Equal(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !equal {
+		t.Fatalf("messages with the same fields in distinct order must be equal")
+	}
+	hashA, err := Hash64(a)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	hashB, err := Hash64(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hashA != hashB {
+		t.Fatalf("unexpected hash mismatch; got %d and %d", hashA, hashB)
+	}
+}
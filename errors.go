@@ -0,0 +1,139 @@
+package easyproto
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTruncated is the kind of DecodeError for truncated protobuf data.
+	ErrTruncated = errors.New("truncated data")
+
+	// ErrBadVarint is the kind of DecodeError for incorrectly encoded varint.
+	ErrBadVarint = errors.New("invalid varint")
+
+	// ErrBadWireType is the kind of DecodeError for unknown or unexpected wire type.
+	ErrBadWireType = errors.New("invalid wire type")
+
+	// ErrBadGroup is the kind of DecodeError for unbalanced or too deeply nested proto2 groups.
+	ErrBadGroup = errors.New("invalid group")
+
+	// ErrOverflow is the kind of DecodeError for values, which do not fit the destination type.
+	ErrOverflow = errors.New("value overflow")
+)
+
+// DecodeError is returned when protobuf-encoded data cannot be decoded.
+//
+// Use errors.Is(err, ErrTruncated) and the like for checking the error kind,
+// and errors.As(err, &decodeErr) for obtaining the error details.
+type DecodeError struct {
+	// Kind is the error kind. It is one of ErrTruncated, ErrBadVarint, ErrBadWireType, ErrBadGroup or ErrOverflow.
+	Kind error
+
+	// Offset is the byte offset of the invalid field.
+	//
+	// It is relative to the start of the buffer passed to the function, which returned the error.
+	Offset int
+
+	// FieldNum is the number of the invalid field.
+	//
+	// It is set to 0 if the field tag cannot be decoded.
+	FieldNum uint32
+
+	// WireType is the wire type of the invalid field.
+	WireType WireType
+
+	// Path contains field numbers for embedded messages, which contain the invalid field.
+	//
+	// It is relative to the buffer passed to the function, which returned the error, e.g. it is empty for top-level fields.
+	Path []uint32
+
+	// detail contains human-readable details for the error.
+	detail string
+
+	// cause is the underlying error. It is returned from Unwrap instead of Kind if set.
+	cause error
+}
+
+// Error implements error interface.
+func (e *DecodeError) Error() string {
+	if len(e.Path) > 0 {
+		return fmt.Sprintf("%s at offset %d (path=%s, fieldNum=%d, wireType=%s): %s", e.Kind, e.Offset, formatPath(e.Path), e.FieldNum, e.WireType, e.detail)
+	}
+	return fmt.Sprintf("%s at offset %d (fieldNum=%d, wireType=%s): %s", e.Kind, e.Offset, e.FieldNum, e.WireType, e.detail)
+}
+
+// Unwrap returns e.Kind, so errors.Is(err, ErrTruncated) and the like work for DecodeError.
+//
+// *WireTypeError is returned for ErrBadWireType kind if it is known. It unwraps to ErrBadWireType,
+// so both errors.Is(err, ErrBadWireType) and errors.As(err, &wireTypeErr) work in this case.
+func (e *DecodeError) Unwrap() error {
+	if e.cause != nil {
+		return e.cause
+	}
+	return e.Kind
+}
+
+func newDecodeError(kind error, offset int, fieldNum uint32, wt WireType, format string, args ...interface{}) *DecodeError {
+	return &DecodeError{
+		Kind:     kind,
+		Offset:   offset,
+		FieldNum: fieldNum,
+		WireType: wt,
+		detail:   fmt.Sprintf(format, args...),
+	}
+}
+
+// newWireTypeError returns DecodeError with ErrBadWireType kind for the field with the given fieldNum at the given offset.
+//
+// The returned error unwraps to WireTypeError with the got and want wire types.
+func newWireTypeError(offset int, fieldNum uint32, got, want WireType) *DecodeError {
+	return &DecodeError{
+		Kind:     ErrBadWireType,
+		Offset:   offset,
+		FieldNum: fieldNum,
+		WireType: got,
+		detail:   fmt.Sprintf("want wireType=%s", want),
+		cause: &WireTypeError{
+			FieldNum: fieldNum,
+			Got:      got,
+			Want:     want,
+		},
+	}
+}
+
+// varintErrorKind returns the error kind for the given non-positive offset returned from binary.Uvarint().
+func varintErrorKind(offset int) error {
+	if offset == 0 {
+		return ErrTruncated
+	}
+	return ErrBadVarint
+}
+
+// withPath sets e.Path to a copy of path and returns e.
+func (e *DecodeError) withPath(path []uint32) *DecodeError {
+	if len(path) > 0 {
+		e.Path = append([]uint32{}, path...)
+	}
+	return e
+}
+
+// setErrorPath sets the path of embedded messages for DecodeError contained in err.
+//
+// The path is set only if it isn't set yet, since errors from deeper levels already contain the full path.
+func setErrorPath(err error, path []uint32) {
+	var de *DecodeError
+	if errors.As(err, &de) && len(de.Path) == 0 && len(path) > 0 {
+		de.Path = append([]uint32{}, path...)
+	}
+}
+
+// addErrorOffset adds delta to the offset of DecodeError contained in err.
+//
+// This allows reporting offsets relative to the original buffer when decoding its tail.
+func addErrorOffset(err error, delta int) {
+	var de *DecodeError
+	if errors.As(err, &de) {
+		de.Offset += delta
+	}
+}
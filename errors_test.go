@@ -0,0 +1,205 @@
+package easyproto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNextFieldDecodeError(t *testing.T) {
+	f := func(src []byte, kindExpected error, offsetExpected int, fieldNumExpected uint32, wireTypeExpected WireType) {
+		t.Helper()
+
+		var fc FieldContext
+		var err error
+		for len(src) > 0 && err == nil {
+			src, err = fc.NextField(src)
+		}
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !errors.Is(err, kindExpected) {
+			t.Fatalf("unexpected error kind; got %v; want %v", err, kindExpected)
+		}
+		var de *DecodeError
+		if !errors.As(err, &de) {
+			t.Fatalf("expecting *DecodeError; got %T", err)
+		}
+		if de.Offset != offsetExpected {
+			t.Fatalf("unexpected offset; got %d; want %d", de.Offset, offsetExpected)
+		}
+		if de.FieldNum != fieldNumExpected {
+			t.Fatalf("unexpected fieldNum; got %d; want %d", de.FieldNum, fieldNumExpected)
+		}
+		if de.WireType != wireTypeExpected {
+			t.Fatalf("unexpected wireType; got %s; want %s", de.WireType, wireTypeExpected)
+		}
+	}
+
+	// truncated i64
+	f([]byte{0x09, 1, 2, 3}, ErrTruncated, 0, 1, WireTypeI64)
+
+	// truncated i32
+	f([]byte{0x15, 1, 2}, ErrTruncated, 0, 2, WireTypeI32)
+
+	// truncated varint
+	f([]byte{0x08, 0x80}, ErrTruncated, 0, 1, WireTypeVarint)
+
+	// truncated length-delimited field
+	f([]byte{0x0a, 0x05, 1, 2}, ErrTruncated, 0, 1, WireTypeLen)
+
+	// too long varint
+	f([]byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, ErrBadVarint, 0, 1, WireTypeVarint)
+
+	// unknown wire type
+	f([]byte{0x0e}, ErrBadWireType, 0, 1, WireType(6))
+
+	// unexpected end group tag
+	f([]byte{0x0c}, ErrBadGroup, 0, 1, WireTypeEGroup)
+
+	// missing end group tag
+	f([]byte{0x0b, 0x10, 0x01}, ErrTruncated, 3, 1, WireTypeSGroup)
+
+	// missing end group tag after non-minimal start group tag
+	f([]byte{0x8b, 0x00, 0x10, 0x01}, ErrTruncated, 4, 1, WireTypeSGroup)
+
+	// the invalid field is located after valid fields
+	f([]byte{0x08, 0x01, 0x10, 0x02, 0x19, 1, 2}, ErrTruncated, 0, 3, WireTypeI64)
+}
+
+func TestFieldByNumDecodeErrorOffset(t *testing.T) {
+	src := []byte{0x08, 0x01, 0x10, 0x02, 0x19, 1, 2}
+
+	var fc FieldContext
+	_, err := fc.FieldByNum(src, 3)
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expecting *DecodeError; got %v", err)
+	}
+	if de.Offset != 4 {
+		t.Fatalf("unexpected offset; got %d; want %d", de.Offset, 4)
+	}
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("unexpected error kind; got %v; want %v", err, ErrTruncated)
+	}
+}
+
+func TestUnpackDecodeError(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(1, 123)
+	data := m.Marshal(nil)
+
+	// Put too long packed varint into the field #2.
+	data = append(data, 0x12, 0x0b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01)
+
+	_, err := UnpackUint64s(data, 2, nil)
+	if !errors.Is(err, ErrBadVarint) {
+		t.Fatalf("unexpected error kind; got %v; want %v", err, ErrBadVarint)
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expecting *DecodeError; got %T", err)
+	}
+	if de.Offset != 2 {
+		t.Fatalf("unexpected offset; got %d; want %d", de.Offset, 2)
+	}
+	if de.FieldNum != 2 {
+		t.Fatalf("unexpected fieldNum; got %d; want %d", de.FieldNum, 2)
+	}
+	if !strings.Contains(err.Error(), "uint64") {
+		t.Fatalf("the error must mention the unpacked type; got %q", err)
+	}
+
+	// Truncated packed fixed64 values.
+	data = append(data[:0], 0x1a, 0x03, 1, 2, 3)
+	_, err = UnpackDoubles(data, 3, nil)
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("unexpected error kind; got %v; want %v", err, ErrTruncated)
+	}
+	if !strings.Contains(err.Error(), "double") {
+		t.Fatalf("the error must mention the unpacked type; got %q", err)
+	}
+}
+
+func TestGetFieldOverflowDecodeError(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendUint64(1, 1<<40)
+	data := m.Marshal(nil)
+
+	_, _, err := GetUint32(data, 1)
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("unexpected error kind; got %v; want %v", err, ErrOverflow)
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expecting *DecodeError; got %T", err)
+	}
+	if de.FieldNum != 1 || de.WireType != WireTypeVarint {
+		t.Fatalf("unexpected field; got fieldNum=%d, wireType=%s; want fieldNum=1, wireType=varint", de.FieldNum, de.WireType)
+	}
+}
+
+func TestWireTypeErrorIsBadWireType(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	data := m.Marshal(nil)
+
+	_, _, err := GetInt64(data, 1)
+	if !errors.Is(err, ErrBadWireType) {
+		t.Fatalf("unexpected error kind; got %v; want %v", err, ErrBadWireType)
+	}
+}
+
+func checkDecodeError(t *testing.T, err error, kindExpected error, offsetExpected int, fieldNumExpected uint32, pathExpected []uint32) {
+	t.Helper()
+
+	if !errors.Is(err, kindExpected) {
+		t.Fatalf("unexpected error kind; got %v; want %v", err, kindExpected)
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expecting *DecodeError; got %T", err)
+	}
+	if de.Offset != offsetExpected {
+		t.Fatalf("unexpected offset; got %d; want %d", de.Offset, offsetExpected)
+	}
+	if de.FieldNum != fieldNumExpected {
+		t.Fatalf("unexpected fieldNum; got %d; want %d", de.FieldNum, fieldNumExpected)
+	}
+	if formatPath(de.Path) != formatPath(pathExpected) {
+		t.Fatalf("unexpected path; got %s; want %s", formatPath(de.Path), formatPath(pathExpected))
+	}
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendInt32(1, 123)
+	mmChild := mm.AppendMessage(2)
+	mmChild.AppendUint64(3, 1<<40)
+	mmChild.AppendString(4, "foo")
+	data := m.Marshal(nil)
+
+	// overflow in embedded message
+	_, _, err := GetUint32ByPath(data, 2, 3)
+	checkDecodeError(t, err, ErrOverflow, 4, 3, []uint32{2})
+	if !strings.Contains(err.Error(), "path=[2]") {
+		t.Fatalf("missing path in error message: %s", err)
+	}
+
+	// wire type mismatch in embedded message
+	_, _, err = GetInt64ByPath(data, 2, 4)
+	checkDecodeError(t, err, ErrBadWireType, 11, 4, []uint32{2})
+
+	// non-message field in the middle of the path
+	_, _, err = GetInt64ByPath(data, 1, 4)
+	checkDecodeError(t, err, ErrBadWireType, 0, 1, nil)
+
+	// invalid embedded message
+	data = []byte{0x08, 0x01, 0x12, 0x02, 0x19, 0x01}
+	_, _, err = GetInt64ByPath(data, 2, 3)
+	checkDecodeError(t, err, ErrTruncated, 4, 3, []uint32{2})
+}
@@ -0,0 +1,491 @@
+package easyproto
+
+import (
+	"fmt"
+)
+
+// Extractor extracts multiple fields from protobuf-encoded message in a single pass.
+//
+// Register destinations for the needed fields with the Extractor methods and then call Extract()
+// for every protobuf-encoded message. For example:
+//
+//	var e easyproto.Extractor
+//	e.String(1, &name).Int64(2, &timestamp).Messages(3, &samples)
+//	if err := e.Extract(src); err != nil {
+//		...
+//	}
+//
+// Scalar destinations are set to the last value for the given fieldNum, while repeated destinations are appended.
+// Destinations for missing fields are left untouched, so they must be set to default values before calling Extract() if needed.
+//
+// It is unsafe to use a single Extractor instance from multiple concurrently running goroutines.
+type Extractor struct {
+	// fields contains the registered fields.
+	fields []extractorField
+}
+
+type extractorField struct {
+	// fieldNum is the number of the field to extract.
+	fieldNum uint32
+
+	// wireType is the expected wireType for scalar field or for a single value of repeated field.
+	//
+	// Repeated fields with scalar values may also be encoded as packed values with WireTypeLen.
+	wireType WireType
+
+	// isRepeated is set to true for repeated field, which may be encoded as packed or unpacked.
+	isRepeated bool
+
+	// typeName is the name of the field type, which is used in error messages.
+	typeName string
+
+	// extract extracts the field value from fc into the registered destination.
+	//
+	// It returns false if fc contains invalid value.
+	extract func(fc *FieldContext) bool
+}
+
+// Extract extracts the registered fields from protobuf-encoded message at src in a single pass.
+//
+// The extracted strings, bytes and messages refer to src, so they are valid while src isn't changed.
+func (e *Extractor) Extract(src []byte) error {
+	fc := getFieldContext()
+	defer putFieldContext(fc)
+
+	srcOrig := src
+	for len(src) > 0 {
+		var err error
+		tail := src
+		src, err = fc.NextField(src)
+		if err != nil {
+			addErrorOffset(err, len(srcOrig)-len(tail))
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		for i := range e.fields {
+			ef := &e.fields[i]
+			if ef.fieldNum != fc.FieldNum {
+				continue
+			}
+			if ef.extract(fc) {
+				continue
+			}
+			offset := len(srcOrig) - len(tail)
+			kind := ErrBadWireType
+			if ef.isRepeated {
+				kind = fc.unpackErrorKind(ef.wireType)
+			} else if fc.wireType == ef.wireType {
+				// Scalar value doesn't fit the destination type.
+				kind = ErrOverflow
+			}
+			if kind == ErrBadWireType {
+				return newWireTypeError(offset, fc.FieldNum, fc.wireType, ef.wireType)
+			}
+			return newDecodeError(kind, offset, fc.FieldNum, fc.wireType, "cannot read %s value for fieldNum=%d", ef.typeName, fc.FieldNum)
+		}
+	}
+	return nil
+}
+
+func (e *Extractor) addField(fieldNum uint32, wt WireType, isRepeated bool, typeName string, extract func(fc *FieldContext) bool) *Extractor {
+	e.fields = append(e.fields, extractorField{
+		fieldNum:   fieldNum,
+		wireType:   wt,
+		isRepeated: isRepeated,
+		typeName:   typeName,
+		extract:    extract,
+	})
+	return e
+}
+
+// Int32 registers dst for int32 value with the given fieldNum.
+func (e *Extractor) Int32(fieldNum uint32, dst *int32) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, false, "int32", func(fc *FieldContext) bool {
+		v, ok := fc.Int32()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Int64 registers dst for int64 value with the given fieldNum.
+func (e *Extractor) Int64(fieldNum uint32, dst *int64) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, false, "int64", func(fc *FieldContext) bool {
+		v, ok := fc.Int64()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Uint32 registers dst for uint32 value with the given fieldNum.
+func (e *Extractor) Uint32(fieldNum uint32, dst *uint32) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, false, "uint32", func(fc *FieldContext) bool {
+		v, ok := fc.Uint32()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Uint64 registers dst for uint64 value with the given fieldNum.
+func (e *Extractor) Uint64(fieldNum uint32, dst *uint64) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, false, "uint64", func(fc *FieldContext) bool {
+		v, ok := fc.Uint64()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Sint32 registers dst for sint32 value with the given fieldNum.
+func (e *Extractor) Sint32(fieldNum uint32, dst *int32) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, false, "sint32", func(fc *FieldContext) bool {
+		v, ok := fc.Sint32()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Sint64 registers dst for sint64 value with the given fieldNum.
+func (e *Extractor) Sint64(fieldNum uint32, dst *int64) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, false, "sint64", func(fc *FieldContext) bool {
+		v, ok := fc.Sint64()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Bool registers dst for bool value with the given fieldNum.
+func (e *Extractor) Bool(fieldNum uint32, dst *bool) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, false, "bool", func(fc *FieldContext) bool {
+		v, ok := fc.Bool()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Enum registers dst for enum value with the given fieldNum.
+func (e *Extractor) Enum(fieldNum uint32, dst *int32) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, false, "enum", func(fc *FieldContext) bool {
+		v, ok := fc.Enum()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Fixed64 registers dst for fixed64 value with the given fieldNum.
+func (e *Extractor) Fixed64(fieldNum uint32, dst *uint64) *Extractor {
+	return e.addField(fieldNum, WireTypeI64, false, "fixed64", func(fc *FieldContext) bool {
+		v, ok := fc.Fixed64()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Sfixed64 registers dst for sfixed64 value with the given fieldNum.
+func (e *Extractor) Sfixed64(fieldNum uint32, dst *int64) *Extractor {
+	return e.addField(fieldNum, WireTypeI64, false, "sfixed64", func(fc *FieldContext) bool {
+		v, ok := fc.Sfixed64()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Double registers dst for double value with the given fieldNum.
+func (e *Extractor) Double(fieldNum uint32, dst *float64) *Extractor {
+	return e.addField(fieldNum, WireTypeI64, false, "double", func(fc *FieldContext) bool {
+		v, ok := fc.Double()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// String registers dst for string value with the given fieldNum.
+func (e *Extractor) String(fieldNum uint32, dst *string) *Extractor {
+	return e.addField(fieldNum, WireTypeLen, false, "string", func(fc *FieldContext) bool {
+		v, ok := fc.String()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Bytes registers dst for bytes value with the given fieldNum.
+func (e *Extractor) Bytes(fieldNum uint32, dst *[]byte) *Extractor {
+	return e.addField(fieldNum, WireTypeLen, false, "bytes", func(fc *FieldContext) bool {
+		v, ok := fc.Bytes()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Message registers dst for message data with the given fieldNum.
+//
+// The message data can be parsed with FieldContext.NextField().
+func (e *Extractor) Message(fieldNum uint32, dst *[]byte) *Extractor {
+	return e.addField(fieldNum, WireTypeLen, false, "message", func(fc *FieldContext) bool {
+		v, ok := fc.MessageData()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Fixed32 registers dst for fixed32 value with the given fieldNum.
+func (e *Extractor) Fixed32(fieldNum uint32, dst *uint32) *Extractor {
+	return e.addField(fieldNum, WireTypeI32, false, "fixed32", func(fc *FieldContext) bool {
+		v, ok := fc.Fixed32()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Sfixed32 registers dst for sfixed32 value with the given fieldNum.
+func (e *Extractor) Sfixed32(fieldNum uint32, dst *int32) *Extractor {
+	return e.addField(fieldNum, WireTypeI32, false, "sfixed32", func(fc *FieldContext) bool {
+		v, ok := fc.Sfixed32()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Float registers dst for float value with the given fieldNum.
+func (e *Extractor) Float(fieldNum uint32, dst *float32) *Extractor {
+	return e.addField(fieldNum, WireTypeI32, false, "float", func(fc *FieldContext) bool {
+		v, ok := fc.Float()
+		if !ok {
+			return false
+		}
+		*dst = v
+		return true
+	})
+}
+
+// Int32s registers dst for repeated int32 values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Int32s(fieldNum uint32, dst *[]int32) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, true, "int32", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackInt32s(*dst)
+		return ok
+	})
+}
+
+// Int64s registers dst for repeated int64 values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Int64s(fieldNum uint32, dst *[]int64) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, true, "int64", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackInt64s(*dst)
+		return ok
+	})
+}
+
+// Uint32s registers dst for repeated uint32 values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Uint32s(fieldNum uint32, dst *[]uint32) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, true, "uint32", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackUint32s(*dst)
+		return ok
+	})
+}
+
+// Uint64s registers dst for repeated uint64 values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Uint64s(fieldNum uint32, dst *[]uint64) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, true, "uint64", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackUint64s(*dst)
+		return ok
+	})
+}
+
+// Sint32s registers dst for repeated sint32 values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Sint32s(fieldNum uint32, dst *[]int32) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, true, "sint32", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackSint32s(*dst)
+		return ok
+	})
+}
+
+// Sint64s registers dst for repeated sint64 values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Sint64s(fieldNum uint32, dst *[]int64) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, true, "sint64", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackSint64s(*dst)
+		return ok
+	})
+}
+
+// Bools registers dst for repeated bool values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Bools(fieldNum uint32, dst *[]bool) *Extractor {
+	return e.addField(fieldNum, WireTypeVarint, true, "bool", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackBools(*dst)
+		return ok
+	})
+}
+
+// Fixed64s registers dst for repeated fixed64 values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Fixed64s(fieldNum uint32, dst *[]uint64) *Extractor {
+	return e.addField(fieldNum, WireTypeI64, true, "fixed64", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackFixed64s(*dst)
+		return ok
+	})
+}
+
+// Sfixed64s registers dst for repeated sfixed64 values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Sfixed64s(fieldNum uint32, dst *[]int64) *Extractor {
+	return e.addField(fieldNum, WireTypeI64, true, "sfixed64", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackSfixed64s(*dst)
+		return ok
+	})
+}
+
+// Doubles registers dst for repeated double values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Doubles(fieldNum uint32, dst *[]float64) *Extractor {
+	return e.addField(fieldNum, WireTypeI64, true, "double", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackDoubles(*dst)
+		return ok
+	})
+}
+
+// Fixed32s registers dst for repeated fixed32 values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Fixed32s(fieldNum uint32, dst *[]uint32) *Extractor {
+	return e.addField(fieldNum, WireTypeI32, true, "fixed32", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackFixed32s(*dst)
+		return ok
+	})
+}
+
+// Sfixed32s registers dst for repeated sfixed32 values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Sfixed32s(fieldNum uint32, dst *[]int32) *Extractor {
+	return e.addField(fieldNum, WireTypeI32, true, "sfixed32", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackSfixed32s(*dst)
+		return ok
+	})
+}
+
+// Floats registers dst for repeated float values with the given fieldNum.
+//
+// The values are appended to dst. Both packed and unpacked encodings are supported.
+func (e *Extractor) Floats(fieldNum uint32, dst *[]float32) *Extractor {
+	return e.addField(fieldNum, WireTypeI32, true, "float", func(fc *FieldContext) bool {
+		var ok bool
+		*dst, ok = fc.UnpackFloats(*dst)
+		return ok
+	})
+}
+
+// Strings registers dst for repeated string values with the given fieldNum.
+//
+// The values are appended to dst.
+func (e *Extractor) Strings(fieldNum uint32, dst *[]string) *Extractor {
+	return e.addField(fieldNum, WireTypeLen, false, "string", func(fc *FieldContext) bool {
+		v, ok := fc.String()
+		if !ok {
+			return false
+		}
+		*dst = append(*dst, v)
+		return true
+	})
+}
+
+// RepeatedBytes registers dst for repeated bytes values with the given fieldNum.
+//
+// The values are appended to dst.
+func (e *Extractor) RepeatedBytes(fieldNum uint32, dst *[][]byte) *Extractor {
+	return e.addField(fieldNum, WireTypeLen, false, "bytes", func(fc *FieldContext) bool {
+		v, ok := fc.Bytes()
+		if !ok {
+			return false
+		}
+		*dst = append(*dst, v)
+		return true
+	})
+}
+
+// Messages registers dst for repeated message values with the given fieldNum.
+//
+// The values are appended to dst.
+//
+// The message data can be parsed with FieldContext.NextField().
+func (e *Extractor) Messages(fieldNum uint32, dst *[][]byte) *Extractor {
+	return e.addField(fieldNum, WireTypeLen, false, "message", func(fc *FieldContext) bool {
+		v, ok := fc.MessageData()
+		if !ok {
+			return false
+		}
+		*dst = append(*dst, v)
+		return true
+	})
+}
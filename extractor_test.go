@@ -0,0 +1,161 @@
+package easyproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExtractorSuccess(t *testing.T) {
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendInt64(2, -123)
+	msg := mm.AppendMessage(3)
+	msg.AppendDouble(1, 1.5)
+	mm.AppendUint64s(4, []uint64{1, 2})
+	mm.AppendUint64(4, 3)
+	mm.AppendString(5, "a")
+	mm.AppendString(5, "b")
+	mm.AppendBool(6, true)
+	mm.AppendInt32(7, -1)
+	mm.AppendFixed32(8, 42)
+	mm.AppendFloat(9, 2.5)
+	mm.AppendSint64(10, -5)
+	mm.AppendString(1, "bar")
+	mm.AppendInt64(100, 1)
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	var name string
+	var ts int64
+	var msgData []byte
+	var u64s []uint64
+	var strs []string
+	var b bool
+	var enum int32
+	var u32 uint32
+	var f32 float32
+	var i64 int64
+	var e Extractor
+	e.String(1, &name).Int64(2, &ts).Message(3, &msgData).Uint64s(4, &u64s).Strings(5, &strs).
+		Bool(6, &b).Enum(7, &enum).Fixed32(8, &u32).Float(9, &f32).Sint64(10, &i64)
+
+	if err := e.Extract(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if name != "bar" {
+		t.Fatalf("unexpected name; got %q; want %q", name, "bar")
+	}
+	if ts != -123 {
+		t.Fatalf("unexpected ts; got %d; want -123", ts)
+	}
+	d, ok, err := GetDouble(msgData, 1)
+	if err != nil || !ok {
+		t.Fatalf("cannot read double from message; ok=%v, err=%v", ok, err)
+	}
+	if d != 1.5 {
+		t.Fatalf("unexpected double; got %v; want 1.5", d)
+	}
+	if !reflect.DeepEqual(u64s, []uint64{1, 2, 3}) {
+		t.Fatalf("unexpected u64s; got %d; want [1 2 3]", u64s)
+	}
+	if !reflect.DeepEqual(strs, []string{"a", "b"}) {
+		t.Fatalf("unexpected strs; got %q; want [a b]", strs)
+	}
+	if !b {
+		t.Fatalf("unexpected bool; got false; want true")
+	}
+	if enum != -1 {
+		t.Fatalf("unexpected enum; got %d; want -1", enum)
+	}
+	if u32 != 42 {
+		t.Fatalf("unexpected fixed32; got %d; want 42", u32)
+	}
+	if f32 != 2.5 {
+		t.Fatalf("unexpected float; got %v; want 2.5", f32)
+	}
+	if i64 != -5 {
+		t.Fatalf("unexpected sint64; got %d; want -5", i64)
+	}
+
+	// Repeated fields must be appended on the second call
+	if err := e.Extract(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(u64s, []uint64{1, 2, 3, 1, 2, 3}) {
+		t.Fatalf("unexpected u64s; got %d; want [1 2 3 1 2 3]", u64s)
+	}
+
+	// Missing fields must be left untouched
+	name = "default"
+	if err := e.Extract(nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if name != "default" {
+		t.Fatalf("unexpected name; got %q; want %q", name, "default")
+	}
+}
+
+func TestExtractorFailure(t *testing.T) {
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendUint64(2, 1<<40)
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	// wrong wire type
+	var n int64
+	var e Extractor
+	e.Int64(1, &n)
+	err := e.Extract(data)
+	var wte *WireTypeError
+	if !errors.As(err, &wte) {
+		t.Fatalf("expecting WireTypeError; got %v", err)
+	}
+	if wte.FieldNum != 1 || wte.Got != WireTypeLen || wte.Want != WireTypeVarint {
+		t.Fatalf("unexpected WireTypeError: %s", wte)
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expecting *DecodeError; got %T", err)
+	}
+	if de.Kind != ErrBadWireType || de.Offset != 0 || de.FieldNum != 1 {
+		t.Fatalf("unexpected DecodeError: %s", de)
+	}
+
+	// too big value
+	var u32 uint32
+	e = Extractor{}
+	e.Uint32(2, &u32)
+	checkDecodeError(t, e.Extract(data), ErrOverflow, 5, 2, nil)
+
+	// too big value for repeated field
+	var bs []bool
+	e = Extractor{}
+	e.Bools(2, &bs)
+	checkDecodeError(t, e.Extract(data), ErrOverflow, 5, 2, nil)
+
+	// invalid packed data
+	var fs []float64
+	e = Extractor{}
+	e.Doubles(1, &fs)
+	checkDecodeError(t, e.Extract(data), ErrTruncated, 0, 1, nil)
+
+	// wrong wire type for repeated field
+	e = Extractor{}
+	e.Doubles(2, &fs)
+	err = e.Extract(data)
+	checkDecodeError(t, err, ErrBadWireType, 5, 2, nil)
+	if !errors.As(err, &wte) {
+		t.Fatalf("expecting WireTypeError; got %v", err)
+	}
+	if wte.Got != WireTypeVarint || wte.Want != WireTypeI64 {
+		t.Fatalf("unexpected WireTypeError: %s", wte)
+	}
+
+	// invalid message
+	e = Extractor{}
+	checkDecodeError(t, e.Extract([]byte{0x08, 0x01, 0xff}), ErrTruncated, 2, 0, nil)
+}
@@ -0,0 +1,1134 @@
+package easyproto
+
+import (
+	"fmt"
+)
+
+// Field is implemented by typed handles for singular fields such as DoubleField and StringField.
+//
+// Typed handles bind the field number to the protobuf kind, so the compiler catches attempts
+// to read or write the field with the wrong kind or Go type. For example:
+//
+//	var (
+//		SampleValue     = easyproto.DoubleField(1)
+//		SampleTimestamp = easyproto.Sint64Field(2)
+//	)
+//
+//	func (s *Sample) marshalProtobuf(mm *easyproto.MessageMarshaler) {
+//		SampleValue.Append(mm, s.Value)
+//		SampleTimestamp.Append(mm, s.Timestamp)
+//	}
+//
+// Typed handles are thin wrappers over MessageMarshaler.Append* methods, FieldContext accessors and Get* functions,
+// so they have no additional runtime cost.
+//
+// The field number must be in the range [1..2^29-1] according to protobuf spec. It isn't verified at runtime,
+// except of builds with easyproto_debug build tag, where Append panics on invalid field numbers.
+//
+// See also RepeatedField and MessageField.
+type Field[T any] interface {
+	// Num returns the field number.
+	Num() uint32
+
+	// Append appends v under the field number to mm.
+	Append(mm *MessageMarshaler, v T)
+
+	// Get returns the value for the field number from protobuf-encoded message at src.
+	//
+	// ok=false is returned if src doesn't contain the field.
+	Get(src []byte) (v T, ok bool, err error)
+
+	// From returns the value from fc.
+	//
+	// False is returned if fc contains another field number or if it doesn't contain the value of the needed kind.
+	From(fc *FieldContext) (T, bool)
+}
+
+// RepeatedField is implemented by typed handles for packed repeated fields such as DoublesField and Int64sField.
+//
+// See Field for details.
+type RepeatedField[T any] interface {
+	// Num returns the field number.
+	Num() uint32
+
+	// Append appends vs in packed form under the field number to mm.
+	Append(mm *MessageMarshaler, vs []T)
+
+	// Get appends all the values for the field number from protobuf-encoded message at src to dst and returns the result.
+	Get(src []byte, dst []T) ([]T, error)
+
+	// From appends the values from fc to dst and returns the result.
+	//
+	// False is returned if fc contains another field number or if it doesn't contain values of the needed kind.
+	From(fc *FieldContext, dst []T) ([]T, bool)
+}
+
+// Int32Field is a typed handle for int32 field with the given field number.
+//
+// See Field for details.
+type Int32Field uint32
+
+// Num returns the field number for f.
+func (f Int32Field) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f Int32Field) Append(mm *MessageMarshaler, v int32) {
+	checkFieldNum(uint32(f))
+	mm.AppendInt32(uint32(f), v)
+}
+
+// Get returns int32 value for f field number from protobuf-encoded message at src.
+//
+// See GetInt32.
+func (f Int32Field) Get(src []byte) (int32, bool, error) {
+	return GetInt32(src, uint32(f))
+}
+
+// From returns int32 value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Int32.
+func (f Int32Field) From(fc *FieldContext) (int32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Int32()
+}
+
+// Int64Field is a typed handle for int64 field with the given field number.
+//
+// See Field for details.
+type Int64Field uint32
+
+// Num returns the field number for f.
+func (f Int64Field) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f Int64Field) Append(mm *MessageMarshaler, v int64) {
+	checkFieldNum(uint32(f))
+	mm.AppendInt64(uint32(f), v)
+}
+
+// Get returns int64 value for f field number from protobuf-encoded message at src.
+//
+// See GetInt64.
+func (f Int64Field) Get(src []byte) (int64, bool, error) {
+	return GetInt64(src, uint32(f))
+}
+
+// From returns int64 value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Int64.
+func (f Int64Field) From(fc *FieldContext) (int64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Int64()
+}
+
+// Uint32Field is a typed handle for uint32 field with the given field number.
+//
+// See Field for details.
+type Uint32Field uint32
+
+// Num returns the field number for f.
+func (f Uint32Field) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f Uint32Field) Append(mm *MessageMarshaler, v uint32) {
+	checkFieldNum(uint32(f))
+	mm.AppendUint32(uint32(f), v)
+}
+
+// Get returns uint32 value for f field number from protobuf-encoded message at src.
+//
+// See GetUint32.
+func (f Uint32Field) Get(src []byte) (uint32, bool, error) {
+	return GetUint32(src, uint32(f))
+}
+
+// From returns uint32 value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Uint32.
+func (f Uint32Field) From(fc *FieldContext) (uint32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Uint32()
+}
+
+// Uint64Field is a typed handle for uint64 field with the given field number.
+//
+// See Field for details.
+type Uint64Field uint32
+
+// Num returns the field number for f.
+func (f Uint64Field) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f Uint64Field) Append(mm *MessageMarshaler, v uint64) {
+	checkFieldNum(uint32(f))
+	mm.AppendUint64(uint32(f), v)
+}
+
+// Get returns uint64 value for f field number from protobuf-encoded message at src.
+//
+// See GetUint64.
+func (f Uint64Field) Get(src []byte) (uint64, bool, error) {
+	return GetUint64(src, uint32(f))
+}
+
+// From returns uint64 value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Uint64.
+func (f Uint64Field) From(fc *FieldContext) (uint64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Uint64()
+}
+
+// Sint32Field is a typed handle for sint32 field with the given field number.
+//
+// See Field for details.
+type Sint32Field uint32
+
+// Num returns the field number for f.
+func (f Sint32Field) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f Sint32Field) Append(mm *MessageMarshaler, v int32) {
+	checkFieldNum(uint32(f))
+	mm.AppendSint32(uint32(f), v)
+}
+
+// Get returns sint32 value for f field number from protobuf-encoded message at src.
+//
+// See GetSint32.
+func (f Sint32Field) Get(src []byte) (int32, bool, error) {
+	return GetSint32(src, uint32(f))
+}
+
+// From returns sint32 value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Sint32.
+func (f Sint32Field) From(fc *FieldContext) (int32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Sint32()
+}
+
+// Sint64Field is a typed handle for sint64 field with the given field number.
+//
+// See Field for details.
+type Sint64Field uint32
+
+// Num returns the field number for f.
+func (f Sint64Field) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f Sint64Field) Append(mm *MessageMarshaler, v int64) {
+	checkFieldNum(uint32(f))
+	mm.AppendSint64(uint32(f), v)
+}
+
+// Get returns sint64 value for f field number from protobuf-encoded message at src.
+//
+// See GetSint64.
+func (f Sint64Field) Get(src []byte) (int64, bool, error) {
+	return GetSint64(src, uint32(f))
+}
+
+// From returns sint64 value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Sint64.
+func (f Sint64Field) From(fc *FieldContext) (int64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Sint64()
+}
+
+// BoolField is a typed handle for bool field with the given field number.
+//
+// See Field for details.
+type BoolField uint32
+
+// Num returns the field number for f.
+func (f BoolField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f BoolField) Append(mm *MessageMarshaler, v bool) {
+	checkFieldNum(uint32(f))
+	mm.AppendBool(uint32(f), v)
+}
+
+// Get returns bool value for f field number from protobuf-encoded message at src.
+//
+// See GetBool.
+func (f BoolField) Get(src []byte) (bool, bool, error) {
+	return GetBool(src, uint32(f))
+}
+
+// From returns bool value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Bool.
+func (f BoolField) From(fc *FieldContext) (bool, bool) {
+	if fc.FieldNum != uint32(f) {
+		return false, false
+	}
+	return fc.Bool()
+}
+
+// Fixed64Field is a typed handle for fixed64 field with the given field number.
+//
+// See Field for details.
+type Fixed64Field uint32
+
+// Num returns the field number for f.
+func (f Fixed64Field) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f Fixed64Field) Append(mm *MessageMarshaler, v uint64) {
+	checkFieldNum(uint32(f))
+	mm.AppendFixed64(uint32(f), v)
+}
+
+// Get returns fixed64 value for f field number from protobuf-encoded message at src.
+//
+// See GetFixed64.
+func (f Fixed64Field) Get(src []byte) (uint64, bool, error) {
+	return GetFixed64(src, uint32(f))
+}
+
+// From returns fixed64 value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Fixed64.
+func (f Fixed64Field) From(fc *FieldContext) (uint64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Fixed64()
+}
+
+// Sfixed64Field is a typed handle for sfixed64 field with the given field number.
+//
+// See Field for details.
+type Sfixed64Field uint32
+
+// Num returns the field number for f.
+func (f Sfixed64Field) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f Sfixed64Field) Append(mm *MessageMarshaler, v int64) {
+	checkFieldNum(uint32(f))
+	mm.AppendSfixed64(uint32(f), v)
+}
+
+// Get returns sfixed64 value for f field number from protobuf-encoded message at src.
+//
+// See GetSfixed64.
+func (f Sfixed64Field) Get(src []byte) (int64, bool, error) {
+	return GetSfixed64(src, uint32(f))
+}
+
+// From returns sfixed64 value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Sfixed64.
+func (f Sfixed64Field) From(fc *FieldContext) (int64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Sfixed64()
+}
+
+// DoubleField is a typed handle for double field with the given field number.
+//
+// See Field for details.
+type DoubleField uint32
+
+// Num returns the field number for f.
+func (f DoubleField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f DoubleField) Append(mm *MessageMarshaler, v float64) {
+	checkFieldNum(uint32(f))
+	mm.AppendDouble(uint32(f), v)
+}
+
+// Get returns double value for f field number from protobuf-encoded message at src.
+//
+// See GetDouble.
+func (f DoubleField) Get(src []byte) (float64, bool, error) {
+	return GetDouble(src, uint32(f))
+}
+
+// From returns double value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Double.
+func (f DoubleField) From(fc *FieldContext) (float64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Double()
+}
+
+// StringField is a typed handle for string field with the given field number.
+//
+// See Field for details.
+type StringField uint32
+
+// Num returns the field number for f.
+func (f StringField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f StringField) Append(mm *MessageMarshaler, v string) {
+	checkFieldNum(uint32(f))
+	mm.AppendString(uint32(f), v)
+}
+
+// Get returns string value for f field number from protobuf-encoded message at src.
+//
+// See GetString.
+func (f StringField) Get(src []byte) (string, bool, error) {
+	return GetString(src, uint32(f))
+}
+
+// From returns string value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.String.
+func (f StringField) From(fc *FieldContext) (string, bool) {
+	if fc.FieldNum != uint32(f) {
+		return "", false
+	}
+	return fc.String()
+}
+
+// BytesField is a typed handle for bytes field with the given field number.
+//
+// See Field for details.
+type BytesField uint32
+
+// Num returns the field number for f.
+func (f BytesField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f BytesField) Append(mm *MessageMarshaler, v []byte) {
+	checkFieldNum(uint32(f))
+	mm.AppendBytes(uint32(f), v)
+}
+
+// Get returns bytes value for f field number from protobuf-encoded message at src.
+//
+// See GetBytes.
+func (f BytesField) Get(src []byte) ([]byte, bool, error) {
+	return GetBytes(src, uint32(f))
+}
+
+// From returns bytes value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Bytes.
+func (f BytesField) From(fc *FieldContext) ([]byte, bool) {
+	if fc.FieldNum != uint32(f) {
+		return nil, false
+	}
+	return fc.Bytes()
+}
+
+// Fixed32Field is a typed handle for fixed32 field with the given field number.
+//
+// See Field for details.
+type Fixed32Field uint32
+
+// Num returns the field number for f.
+func (f Fixed32Field) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f Fixed32Field) Append(mm *MessageMarshaler, v uint32) {
+	checkFieldNum(uint32(f))
+	mm.AppendFixed32(uint32(f), v)
+}
+
+// Get returns fixed32 value for f field number from protobuf-encoded message at src.
+//
+// See GetFixed32.
+func (f Fixed32Field) Get(src []byte) (uint32, bool, error) {
+	return GetFixed32(src, uint32(f))
+}
+
+// From returns fixed32 value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Fixed32.
+func (f Fixed32Field) From(fc *FieldContext) (uint32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Fixed32()
+}
+
+// Sfixed32Field is a typed handle for sfixed32 field with the given field number.
+//
+// See Field for details.
+type Sfixed32Field uint32
+
+// Num returns the field number for f.
+func (f Sfixed32Field) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f Sfixed32Field) Append(mm *MessageMarshaler, v int32) {
+	checkFieldNum(uint32(f))
+	mm.AppendSfixed32(uint32(f), v)
+}
+
+// Get returns sfixed32 value for f field number from protobuf-encoded message at src.
+//
+// See GetSfixed32.
+func (f Sfixed32Field) Get(src []byte) (int32, bool, error) {
+	return GetSfixed32(src, uint32(f))
+}
+
+// From returns sfixed32 value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Sfixed32.
+func (f Sfixed32Field) From(fc *FieldContext) (int32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Sfixed32()
+}
+
+// FloatField is a typed handle for float field with the given field number.
+//
+// See Field for details.
+type FloatField uint32
+
+// Num returns the field number for f.
+func (f FloatField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f FloatField) Append(mm *MessageMarshaler, v float32) {
+	checkFieldNum(uint32(f))
+	mm.AppendFloat(uint32(f), v)
+}
+
+// Get returns float value for f field number from protobuf-encoded message at src.
+//
+// See GetFloat.
+func (f FloatField) Get(src []byte) (float32, bool, error) {
+	return GetFloat(src, uint32(f))
+}
+
+// From returns float value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Float.
+func (f FloatField) From(fc *FieldContext) (float32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Float()
+}
+
+// EnumField is a typed handle for enum field with the given field number.
+//
+// See Field for details.
+type EnumField uint32
+
+// Num returns the field number for f.
+func (f EnumField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends v under f field number to mm.
+func (f EnumField) Append(mm *MessageMarshaler, v int32) {
+	checkFieldNum(uint32(f))
+	mm.AppendInt32(uint32(f), v)
+}
+
+// Get returns enum value for f field number from protobuf-encoded message at src.
+//
+// See GetEnum.
+func (f EnumField) Get(src []byte) (int32, bool, error) {
+	return GetEnum(src, uint32(f))
+}
+
+// From returns enum value from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.Enum.
+func (f EnumField) From(fc *FieldContext) (int32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return 0, false
+	}
+	return fc.Enum()
+}
+
+// MessageField is a typed handle for embedded message field with the given field number.
+type MessageField uint32
+
+// Num returns the field number for f.
+func (f MessageField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends embedded message under f field number to mm.
+//
+// The function returns the MessageMarshaler for constructing the appended message.
+func (f MessageField) Append(mm *MessageMarshaler) *MessageMarshaler {
+	checkFieldNum(uint32(f))
+	return mm.AppendMessage(uint32(f))
+}
+
+// AppendBytes appends protobuf-encoded message data under f field number to mm.
+//
+// See MessageMarshaler.AppendMessageBytes.
+func (f MessageField) AppendBytes(mm *MessageMarshaler, data []byte) {
+	checkFieldNum(uint32(f))
+	mm.AppendMessageBytes(uint32(f), data)
+}
+
+// Get returns message data for f field number from protobuf-encoded message at src.
+//
+// See GetMessageData.
+func (f MessageField) Get(src []byte) ([]byte, bool, error) {
+	return GetMessageData(src, uint32(f))
+}
+
+// From returns message data from fc.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.MessageData.
+func (f MessageField) From(fc *FieldContext) ([]byte, bool) {
+	if fc.FieldNum != uint32(f) {
+		return nil, false
+	}
+	return fc.MessageData()
+}
+
+// Int32sField is a typed handle for packed repeated int32 field with the given field number.
+//
+// See RepeatedField for details.
+type Int32sField uint32
+
+// Num returns the field number for f.
+func (f Int32sField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f Int32sField) Append(mm *MessageMarshaler, vs []int32) {
+	checkFieldNum(uint32(f))
+	mm.AppendInt32s(uint32(f), vs)
+}
+
+// Get appends int32 values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackInt32s.
+func (f Int32sField) Get(src []byte, dst []int32) ([]int32, error) {
+	return UnpackInt32s(src, uint32(f), dst)
+}
+
+// From appends int32 values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackInt32s.
+func (f Int32sField) From(fc *FieldContext, dst []int32) ([]int32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackInt32s(dst)
+}
+
+// Int64sField is a typed handle for packed repeated int64 field with the given field number.
+//
+// See RepeatedField for details.
+type Int64sField uint32
+
+// Num returns the field number for f.
+func (f Int64sField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f Int64sField) Append(mm *MessageMarshaler, vs []int64) {
+	checkFieldNum(uint32(f))
+	mm.AppendInt64s(uint32(f), vs)
+}
+
+// Get appends int64 values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackInt64s.
+func (f Int64sField) Get(src []byte, dst []int64) ([]int64, error) {
+	return UnpackInt64s(src, uint32(f), dst)
+}
+
+// From appends int64 values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackInt64s.
+func (f Int64sField) From(fc *FieldContext, dst []int64) ([]int64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackInt64s(dst)
+}
+
+// Uint32sField is a typed handle for packed repeated uint32 field with the given field number.
+//
+// See RepeatedField for details.
+type Uint32sField uint32
+
+// Num returns the field number for f.
+func (f Uint32sField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f Uint32sField) Append(mm *MessageMarshaler, vs []uint32) {
+	checkFieldNum(uint32(f))
+	mm.AppendUint32s(uint32(f), vs)
+}
+
+// Get appends uint32 values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackUint32s.
+func (f Uint32sField) Get(src []byte, dst []uint32) ([]uint32, error) {
+	return UnpackUint32s(src, uint32(f), dst)
+}
+
+// From appends uint32 values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackUint32s.
+func (f Uint32sField) From(fc *FieldContext, dst []uint32) ([]uint32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackUint32s(dst)
+}
+
+// Uint64sField is a typed handle for packed repeated uint64 field with the given field number.
+//
+// See RepeatedField for details.
+type Uint64sField uint32
+
+// Num returns the field number for f.
+func (f Uint64sField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f Uint64sField) Append(mm *MessageMarshaler, vs []uint64) {
+	checkFieldNum(uint32(f))
+	mm.AppendUint64s(uint32(f), vs)
+}
+
+// Get appends uint64 values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackUint64s.
+func (f Uint64sField) Get(src []byte, dst []uint64) ([]uint64, error) {
+	return UnpackUint64s(src, uint32(f), dst)
+}
+
+// From appends uint64 values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackUint64s.
+func (f Uint64sField) From(fc *FieldContext, dst []uint64) ([]uint64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackUint64s(dst)
+}
+
+// Sint32sField is a typed handle for packed repeated sint32 field with the given field number.
+//
+// See RepeatedField for details.
+type Sint32sField uint32
+
+// Num returns the field number for f.
+func (f Sint32sField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f Sint32sField) Append(mm *MessageMarshaler, vs []int32) {
+	checkFieldNum(uint32(f))
+	mm.AppendSint32s(uint32(f), vs)
+}
+
+// Get appends sint32 values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackSint32s.
+func (f Sint32sField) Get(src []byte, dst []int32) ([]int32, error) {
+	return UnpackSint32s(src, uint32(f), dst)
+}
+
+// From appends sint32 values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackSint32s.
+func (f Sint32sField) From(fc *FieldContext, dst []int32) ([]int32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackSint32s(dst)
+}
+
+// Sint64sField is a typed handle for packed repeated sint64 field with the given field number.
+//
+// See RepeatedField for details.
+type Sint64sField uint32
+
+// Num returns the field number for f.
+func (f Sint64sField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f Sint64sField) Append(mm *MessageMarshaler, vs []int64) {
+	checkFieldNum(uint32(f))
+	mm.AppendSint64s(uint32(f), vs)
+}
+
+// Get appends sint64 values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackSint64s.
+func (f Sint64sField) Get(src []byte, dst []int64) ([]int64, error) {
+	return UnpackSint64s(src, uint32(f), dst)
+}
+
+// From appends sint64 values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackSint64s.
+func (f Sint64sField) From(fc *FieldContext, dst []int64) ([]int64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackSint64s(dst)
+}
+
+// BoolsField is a typed handle for packed repeated bool field with the given field number.
+//
+// See RepeatedField for details.
+type BoolsField uint32
+
+// Num returns the field number for f.
+func (f BoolsField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f BoolsField) Append(mm *MessageMarshaler, vs []bool) {
+	checkFieldNum(uint32(f))
+	mm.AppendBools(uint32(f), vs)
+}
+
+// Get appends bool values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackBools.
+func (f BoolsField) Get(src []byte, dst []bool) ([]bool, error) {
+	return UnpackBools(src, uint32(f), dst)
+}
+
+// From appends bool values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackBools.
+func (f BoolsField) From(fc *FieldContext, dst []bool) ([]bool, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackBools(dst)
+}
+
+// Fixed64sField is a typed handle for packed repeated fixed64 field with the given field number.
+//
+// See RepeatedField for details.
+type Fixed64sField uint32
+
+// Num returns the field number for f.
+func (f Fixed64sField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f Fixed64sField) Append(mm *MessageMarshaler, vs []uint64) {
+	checkFieldNum(uint32(f))
+	mm.AppendFixed64s(uint32(f), vs)
+}
+
+// Get appends fixed64 values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackFixed64s.
+func (f Fixed64sField) Get(src []byte, dst []uint64) ([]uint64, error) {
+	return UnpackFixed64s(src, uint32(f), dst)
+}
+
+// From appends fixed64 values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackFixed64s.
+func (f Fixed64sField) From(fc *FieldContext, dst []uint64) ([]uint64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackFixed64s(dst)
+}
+
+// Sfixed64sField is a typed handle for packed repeated sfixed64 field with the given field number.
+//
+// See RepeatedField for details.
+type Sfixed64sField uint32
+
+// Num returns the field number for f.
+func (f Sfixed64sField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f Sfixed64sField) Append(mm *MessageMarshaler, vs []int64) {
+	checkFieldNum(uint32(f))
+	mm.AppendSfixed64s(uint32(f), vs)
+}
+
+// Get appends sfixed64 values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackSfixed64s.
+func (f Sfixed64sField) Get(src []byte, dst []int64) ([]int64, error) {
+	return UnpackSfixed64s(src, uint32(f), dst)
+}
+
+// From appends sfixed64 values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackSfixed64s.
+func (f Sfixed64sField) From(fc *FieldContext, dst []int64) ([]int64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackSfixed64s(dst)
+}
+
+// DoublesField is a typed handle for packed repeated double field with the given field number.
+//
+// See RepeatedField for details.
+type DoublesField uint32
+
+// Num returns the field number for f.
+func (f DoublesField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f DoublesField) Append(mm *MessageMarshaler, vs []float64) {
+	checkFieldNum(uint32(f))
+	mm.AppendDoubles(uint32(f), vs)
+}
+
+// Get appends double values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackDoubles.
+func (f DoublesField) Get(src []byte, dst []float64) ([]float64, error) {
+	return UnpackDoubles(src, uint32(f), dst)
+}
+
+// From appends double values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackDoubles.
+func (f DoublesField) From(fc *FieldContext, dst []float64) ([]float64, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackDoubles(dst)
+}
+
+// Fixed32sField is a typed handle for packed repeated fixed32 field with the given field number.
+//
+// See RepeatedField for details.
+type Fixed32sField uint32
+
+// Num returns the field number for f.
+func (f Fixed32sField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f Fixed32sField) Append(mm *MessageMarshaler, vs []uint32) {
+	checkFieldNum(uint32(f))
+	mm.AppendFixed32s(uint32(f), vs)
+}
+
+// Get appends fixed32 values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackFixed32s.
+func (f Fixed32sField) Get(src []byte, dst []uint32) ([]uint32, error) {
+	return UnpackFixed32s(src, uint32(f), dst)
+}
+
+// From appends fixed32 values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackFixed32s.
+func (f Fixed32sField) From(fc *FieldContext, dst []uint32) ([]uint32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackFixed32s(dst)
+}
+
+// Sfixed32sField is a typed handle for packed repeated sfixed32 field with the given field number.
+//
+// See RepeatedField for details.
+type Sfixed32sField uint32
+
+// Num returns the field number for f.
+func (f Sfixed32sField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f Sfixed32sField) Append(mm *MessageMarshaler, vs []int32) {
+	checkFieldNum(uint32(f))
+	mm.AppendSfixed32s(uint32(f), vs)
+}
+
+// Get appends sfixed32 values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackSfixed32s.
+func (f Sfixed32sField) Get(src []byte, dst []int32) ([]int32, error) {
+	return UnpackSfixed32s(src, uint32(f), dst)
+}
+
+// From appends sfixed32 values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackSfixed32s.
+func (f Sfixed32sField) From(fc *FieldContext, dst []int32) ([]int32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackSfixed32s(dst)
+}
+
+// FloatsField is a typed handle for packed repeated float field with the given field number.
+//
+// See RepeatedField for details.
+type FloatsField uint32
+
+// Num returns the field number for f.
+func (f FloatsField) Num() uint32 {
+	return uint32(f)
+}
+
+// Append appends vs in packed form under f field number to mm.
+func (f FloatsField) Append(mm *MessageMarshaler, vs []float32) {
+	checkFieldNum(uint32(f))
+	mm.AppendFloats(uint32(f), vs)
+}
+
+// Get appends float values for f field number from protobuf-encoded message at src to dst and returns the result.
+//
+// See UnpackFloats.
+func (f FloatsField) Get(src []byte, dst []float32) ([]float32, error) {
+	return UnpackFloats(src, uint32(f), dst)
+}
+
+// From appends float values from fc to dst and returns the result.
+//
+// False is returned if fc contains another field number.
+//
+// See FieldContext.UnpackFloats.
+func (f FloatsField) From(fc *FieldContext, dst []float32) ([]float32, bool) {
+	if fc.FieldNum != uint32(f) {
+		return dst, false
+	}
+	return fc.UnpackFloats(dst)
+}
+
+// checkFieldNum panics if fieldNum is outside the range allowed by protobuf spec.
+//
+// The check is performed only in builds with easyproto_debug build tag.
+func checkFieldNum(fieldNum uint32) {
+	if !isDebugBuild {
+		return
+	}
+	if fieldNum == 0 || fieldNum > maxFieldNum {
+		panic(fmt.Errorf("BUG: invalid field number %d for typed field handle; it must be in the range [1..%d]", fieldNum, maxFieldNum))
+	}
+}
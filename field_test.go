@@ -0,0 +1,291 @@
+package easyproto
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Verify that typed handles implement Field and RepeatedField interfaces.
+var (
+	_ Field[int32]   = Int32Field(0)
+	_ Field[int64]   = Int64Field(0)
+	_ Field[uint32]  = Uint32Field(0)
+	_ Field[uint64]  = Uint64Field(0)
+	_ Field[int32]   = Sint32Field(0)
+	_ Field[int64]   = Sint64Field(0)
+	_ Field[bool]    = BoolField(0)
+	_ Field[int32]   = EnumField(0)
+	_ Field[uint64]  = Fixed64Field(0)
+	_ Field[int64]   = Sfixed64Field(0)
+	_ Field[float64] = DoubleField(0)
+	_ Field[string]  = StringField(0)
+	_ Field[[]byte]  = BytesField(0)
+	_ Field[uint32]  = Fixed32Field(0)
+	_ Field[int32]   = Sfixed32Field(0)
+	_ Field[float32] = FloatField(0)
+
+	_ RepeatedField[int32]   = Int32sField(0)
+	_ RepeatedField[int64]   = Int64sField(0)
+	_ RepeatedField[uint32]  = Uint32sField(0)
+	_ RepeatedField[uint64]  = Uint64sField(0)
+	_ RepeatedField[int32]   = Sint32sField(0)
+	_ RepeatedField[int64]   = Sint64sField(0)
+	_ RepeatedField[bool]    = BoolsField(0)
+	_ RepeatedField[uint64]  = Fixed64sField(0)
+	_ RepeatedField[int64]   = Sfixed64sField(0)
+	_ RepeatedField[float64] = DoublesField(0)
+	_ RepeatedField[uint32]  = Fixed32sField(0)
+	_ RepeatedField[int32]   = Sfixed32sField(0)
+	_ RepeatedField[float32] = FloatsField(0)
+)
+
+func testFieldRoundTrip[T any](t *testing.T, f Field[T], v T) {
+	t.Helper()
+
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(f.Num()+1, "other field")
+	f.Append(mm, v)
+	data := m.Marshal(nil)
+
+	result, ok, err := f.Get(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !ok {
+		t.Fatalf("cannot find fieldNum=%d", f.Num())
+	}
+	if !reflect.DeepEqual(result, v) {
+		t.Fatalf("unexpected value for fieldNum=%d; got %v; want %v", f.Num(), result, v)
+	}
+
+	var fc FieldContext
+	if ok, err := fc.FieldByNum(data, f.Num()); err != nil || !ok {
+		t.Fatalf("cannot find fieldNum=%d; ok=%v, err=%v", f.Num(), ok, err)
+	}
+	result, ok = f.From(&fc)
+	if !ok {
+		t.Fatalf("cannot read fieldNum=%d", f.Num())
+	}
+	if !reflect.DeepEqual(result, v) {
+		t.Fatalf("unexpected value for fieldNum=%d; got %v; want %v", f.Num(), result, v)
+	}
+
+	// Missing field
+	_, ok, err = f.Get(data[:0])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ok {
+		t.Fatalf("unexpected field found in empty message")
+	}
+}
+
+func testRepeatedFieldRoundTrip[T any](t *testing.T, f RepeatedField[T], vs []T) {
+	t.Helper()
+
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	f.Append(mm, vs[:1])
+	mm.AppendString(f.Num()+1, "other field")
+	f.Append(mm, vs[1:])
+	data := m.Marshal(nil)
+
+	result, err := f.Get(data, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(result, vs) {
+		t.Fatalf("unexpected values for fieldNum=%d; got %v; want %v", f.Num(), result, vs)
+	}
+
+	var fc FieldContext
+	if ok, err := fc.FieldByNum(data, f.Num()); err != nil || !ok {
+		t.Fatalf("cannot find fieldNum=%d; ok=%v, err=%v", f.Num(), ok, err)
+	}
+	result, ok := f.From(&fc, nil)
+	if !ok {
+		t.Fatalf("cannot read fieldNum=%d", f.Num())
+	}
+	if !reflect.DeepEqual(result, vs[:1]) {
+		t.Fatalf("unexpected values for fieldNum=%d; got %v; want %v", f.Num(), result, vs[:1])
+	}
+}
+
+func TestFieldRoundTrip(t *testing.T) {
+	testFieldRoundTrip[int32](t, Int32Field(1), -123)
+	testFieldRoundTrip[int64](t, Int64Field(2), -1<<40)
+	testFieldRoundTrip[uint32](t, Uint32Field(3), 1<<31)
+	testFieldRoundTrip[uint64](t, Uint64Field(4), 1<<63)
+	testFieldRoundTrip[int32](t, Sint32Field(5), -1<<31)
+	testFieldRoundTrip[int64](t, Sint64Field(6), -1<<63)
+	testFieldRoundTrip[bool](t, BoolField(7), true)
+	testFieldRoundTrip[int32](t, EnumField(8), 3)
+	testFieldRoundTrip[uint64](t, Fixed64Field(9), 1<<63)
+	testFieldRoundTrip[int64](t, Sfixed64Field(10), -1)
+	testFieldRoundTrip[float64](t, DoubleField(11), 1.5)
+	testFieldRoundTrip[string](t, StringField(12), "foo")
+	testFieldRoundTrip[[]byte](t, BytesField(13), []byte("bar"))
+	testFieldRoundTrip[uint32](t, Fixed32Field(14), 1<<31)
+	testFieldRoundTrip[int32](t, Sfixed32Field(15), -1)
+	testFieldRoundTrip[float32](t, FloatField(1000), -0.25)
+}
+
+func TestRepeatedFieldRoundTrip(t *testing.T) {
+	testRepeatedFieldRoundTrip[int32](t, Int32sField(1), []int32{-1, 0, 1 << 30})
+	testRepeatedFieldRoundTrip[int64](t, Int64sField(2), []int64{-1 << 40, 2})
+	testRepeatedFieldRoundTrip[uint32](t, Uint32sField(3), []uint32{1, 1 << 31})
+	testRepeatedFieldRoundTrip[uint64](t, Uint64sField(4), []uint64{1 << 63, 0})
+	testRepeatedFieldRoundTrip[int32](t, Sint32sField(5), []int32{-1, 1})
+	testRepeatedFieldRoundTrip[int64](t, Sint64sField(6), []int64{-1 << 63, 1 << 62})
+	testRepeatedFieldRoundTrip[bool](t, BoolsField(7), []bool{true, false, true})
+	testRepeatedFieldRoundTrip[uint64](t, Fixed64sField(8), []uint64{1, 2})
+	testRepeatedFieldRoundTrip[int64](t, Sfixed64sField(9), []int64{-1, 2})
+	testRepeatedFieldRoundTrip[float64](t, DoublesField(10), []float64{1.5, -2.5})
+	testRepeatedFieldRoundTrip[uint32](t, Fixed32sField(11), []uint32{1, 2, 3})
+	testRepeatedFieldRoundTrip[int32](t, Sfixed32sField(12), []int32{-1, -2})
+	testRepeatedFieldRoundTrip[float32](t, FloatsField(1000), []float32{0.5, 1})
+}
+
+func TestFieldCompatibility(t *testing.T) {
+	const (
+		timeseriesName    = StringField(1)
+		timeseriesSamples = MessageField(2)
+		sampleValue       = DoubleField(1)
+		sampleTimestamp   = Sint64Field(2)
+	)
+
+	// Typed handles must produce the same result as MessageMarshaler
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	timeseriesName.Append(mm, "foo")
+	mmSample := timeseriesSamples.Append(mm)
+	sampleValue.Append(mmSample, 1.5)
+	sampleTimestamp.Append(mmSample, -10)
+	timeseriesSamples.AppendBytes(mm, nil)
+	data := m.Marshal(nil)
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mmSample = mm.AppendMessage(2)
+	mmSample.AppendDouble(1, 1.5)
+	mmSample.AppendSint64(2, -10)
+	mm.AppendMessage(2)
+	dataExpected := m.Marshal(nil)
+	if !bytes.Equal(data, dataExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", data, dataExpected)
+	}
+
+	// Read the message with typed handles
+	sampleData, ok, err := timeseriesSamples.Get(data)
+	if err != nil || !ok {
+		t.Fatalf("cannot obtain sample; ok=%v, err=%v", ok, err)
+	}
+	timestamp, ok, err := sampleTimestamp.Get(sampleData)
+	if err != nil || !ok {
+		t.Fatalf("cannot obtain timestamp; ok=%v, err=%v", ok, err)
+	}
+	if timestamp != -10 {
+		t.Fatalf("unexpected timestamp; got %d; want %d", timestamp, -10)
+	}
+
+	var fc FieldContext
+	samples := 0
+	for len(data) > 0 {
+		data, err = fc.NextField(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if fc.FieldNum == timeseriesSamples.Num() {
+			if _, ok := timeseriesSamples.From(&fc); !ok {
+				t.Fatalf("cannot read sample data")
+			}
+			samples++
+		}
+	}
+	if samples != 2 {
+		t.Fatalf("unexpected number of samples; got %d; want %d", samples, 2)
+	}
+}
+
+func TestFieldFromOtherFieldNum(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(1, 123)
+	mm.AppendInt64s(2, []int64{1, 2})
+	data := m.Marshal(nil)
+
+	var fc FieldContext
+	if _, err := fc.NextField(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The handle must not read the value for another field number with the same kind
+	if v, ok := Int64Field(2).From(&fc); ok {
+		t.Fatalf("unexpected value read for fieldNum=%d by the handle for fieldNum=2: %d", fc.FieldNum, v)
+	}
+	if vs, ok := Int64sField(2).From(&fc, nil); ok {
+		t.Fatalf("unexpected values read for fieldNum=%d by the handle for fieldNum=2: %v", fc.FieldNum, vs)
+	}
+	if _, ok := MessageField(2).From(&fc); ok {
+		t.Fatalf("unexpected message read for fieldNum=%d by the handle for fieldNum=2", fc.FieldNum)
+	}
+
+	// The handle for the matching field number must read the value
+	if v, ok := Int64Field(1).From(&fc); !ok || v != 123 {
+		t.Fatalf("unexpected value; got %d, ok=%v; want %d, ok=true", v, ok, 123)
+	}
+}
+
+func TestFieldGetFailure(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendUint64(2, 1<<40)
+	data := m.Marshal(nil)
+
+	// Wire type mismatch
+	_, _, err := DoubleField(1).Get(data)
+	var wte *WireTypeError
+	if !errors.As(err, &wte) {
+		t.Fatalf("expecting WireTypeError; got %v", err)
+	}
+
+	// Overflow
+	_, _, err = Uint32Field(2).Get(data)
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expecting ErrOverflow; got %v", err)
+	}
+
+	// Invalid packed values
+	if _, err := FloatsField(2).Get(data, nil); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestFieldZeroAlloc(t *testing.T) {
+	const (
+		sampleValue     = DoubleField(1)
+		sampleTimestamp = Sint64Field(2)
+	)
+
+	var m Marshaler
+	var data []byte
+	n := testing.AllocsPerRun(100, func() {
+		m.Reset()
+		mm := m.MessageMarshaler()
+		sampleValue.Append(mm, 1.5)
+		sampleTimestamp.Append(mm, 123)
+		data = m.Marshal(data[:0])
+
+		if v, ok, err := sampleValue.Get(data); err != nil || !ok || v != 1.5 {
+			panic("unexpected value")
+		}
+	})
+	if n != 0 {
+		t.Fatalf("unexpected number of allocations; got %v; want 0", n)
+	}
+}
@@ -0,0 +1,24 @@
+package easyproto
+
+// rangeFields calls yield for every field at protobuf-encoded src.
+//
+// It stops on the first error or when yield returns false.
+func rangeFields(src []byte, yield func(fc *FieldContext, err error) bool) {
+	fc := getFieldContext()
+	defer putFieldContext(fc)
+
+	srcOrig := src
+	for len(src) > 0 {
+		var err error
+		tail := src
+		src, err = fc.NextField(src)
+		if err != nil {
+			addErrorOffset(err, len(srcOrig)-len(tail))
+			yield(nil, err)
+			return
+		}
+		if !yield(fc, nil) {
+			return
+		}
+	}
+}
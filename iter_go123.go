@@ -0,0 +1,32 @@
+//go:build go1.23
+
+package easyproto
+
+import (
+	"iter"
+)
+
+// Fields returns an iterator over fields at protobuf-encoded src.
+//
+// The iterator yields every field with nil error. It yields nil FieldContext with non-nil error
+// and stops if src contains invalid field.
+//
+// The yielded FieldContext is valid only during the current loop iteration and it is re-used for the next field.
+// It is unsafe modifying src while the iterator is in use.
+//
+// Example:
+//
+//	for fc, err := range easyproto.Fields(src) {
+//		if err != nil {
+//			return fmt.Errorf("cannot read the next field: %w", err)
+//		}
+//		switch fc.FieldNum {
+//		case 1:
+//			...
+//		}
+//	}
+func Fields(src []byte) iter.Seq2[*FieldContext, error] {
+	return func(yield func(*FieldContext, error) bool) {
+		rangeFields(src, yield)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build go1.23
+
+package easyproto
+
+import (
+	"testing"
+)
+
+func TestFieldsRange(t *testing.T) {
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendInt64(2, 123)
+	mm.AppendString(1, "bar")
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	var names []string
+	var n int64
+	for fc, err := range Fields(data) {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			name, ok := fc.String()
+			if !ok {
+				t.Fatalf("cannot read name")
+			}
+			names = append(names, name)
+		case 2:
+			v, ok := fc.Int64()
+			if !ok {
+				t.Fatalf("cannot read int64")
+			}
+			n = v
+		}
+	}
+	if len(names) != 2 || names[0] != "foo" || names[1] != "bar" {
+		t.Fatalf("unexpected names; got %q; want [foo bar]", names)
+	}
+	if n != 123 {
+		t.Fatalf("unexpected int64; got %d; want 123", n)
+	}
+
+	// Verify break inside the loop
+	count := 0
+	for range Fields(data) {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Fatalf("unexpected number of iterations; got %d; want 1", count)
+	}
+}
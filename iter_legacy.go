@@ -0,0 +1,24 @@
+//go:build !go1.23
+
+package easyproto
+
+// Fields returns an iterator over fields at protobuf-encoded src.
+//
+// The iterator yields every field with nil error. It yields nil FieldContext with non-nil error
+// and stops if src contains invalid field.
+//
+// The yielded FieldContext is valid only during the current yield call and it is re-used for the next field.
+// It is unsafe modifying src while the iterator is in use.
+//
+// The returned function has the same signature as iter.Seq2[*FieldContext, error] at Go 1.23+,
+// so it can be called directly at older Go versions:
+//
+//	Fields(src)(func(fc *easyproto.FieldContext, err error) bool {
+//		...
+//		return true
+//	})
+func Fields(src []byte) func(yield func(*FieldContext, error) bool) {
+	return func(yield func(*FieldContext, error) bool) {
+		rangeFields(src, yield)
+	}
+}
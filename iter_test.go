@@ -0,0 +1,68 @@
+package easyproto
+
+import (
+	"testing"
+)
+
+func TestFieldsSuccess(t *testing.T) {
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(1, 123)
+	mm.AppendString(2, "foo")
+	mm.AppendDouble(3, 1.5)
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	var fieldNums []uint32
+	Fields(data)(func(fc *FieldContext, err error) bool {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		fieldNums = append(fieldNums, fc.FieldNum)
+		return true
+	})
+	if len(fieldNums) != 3 || fieldNums[0] != 1 || fieldNums[1] != 2 || fieldNums[2] != 3 {
+		t.Fatalf("unexpected fieldNums; got %d; want [1 2 3]", fieldNums)
+	}
+
+	// Verify early stop
+	calls := 0
+	Fields(data)(func(_ *FieldContext, _ error) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Fatalf("unexpected number of calls; got %d; want 1", calls)
+	}
+
+	// Verify empty message
+	Fields(nil)(func(_ *FieldContext, _ error) bool {
+		t.Fatalf("unexpected call for empty message")
+		return true
+	})
+}
+
+func TestFieldsFailure(t *testing.T) {
+	// The second field is truncated
+	data := []byte{1 << 3, 0x01, 2<<3 | byte(WireTypeI64), 0x01}
+
+	var fieldNums []uint32
+	var errs []error
+	Fields(data)(func(fc *FieldContext, err error) bool {
+		if err != nil {
+			if fc != nil {
+				t.Fatalf("expecting nil FieldContext on error")
+			}
+			errs = append(errs, err)
+			return true
+		}
+		fieldNums = append(fieldNums, fc.FieldNum)
+		return true
+	})
+	if len(fieldNums) != 1 || fieldNums[0] != 1 {
+		t.Fatalf("unexpected fieldNums; got %d; want [1]", fieldNums)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("unexpected number of errors; got %d; want 1", len(errs))
+	}
+}
@@ -0,0 +1,261 @@
+package easyproto
+
+import (
+	"fmt"
+)
+
+// MapEntryKeyFieldNum is the field number for the key in map entry message.
+//
+// Protobuf maps are encoded as repeated entry messages with the key at MapEntryKeyFieldNum and the value at MapEntryValueFieldNum.
+// See https://protobuf.dev/programming-guides/encoding/#maps
+const MapEntryKeyFieldNum = 1
+
+// MapEntryValueFieldNum is the field number for the value in map entry message.
+//
+// See also MapEntryKeyFieldNum.
+const MapEntryValueFieldNum = 2
+
+// MapKey is the constraint for Go types, which can be used as sortable keys in protobuf maps.
+//
+// Protobuf maps with bool keys must be marshaled via AppendMapEntry().
+type MapKey interface {
+	~int32 | ~int64 | ~uint32 | ~uint64 | ~string
+}
+
+// AppendMapEntry appends map entry message under the given fieldNum to mm.
+//
+// The function returns the MessageMarshaler for constructing the appended entry.
+// The key must be appended under MapEntryKeyFieldNum, while the value must be appended under MapEntryValueFieldNum.
+//
+// See also AppendMap().
+func (mm *MessageMarshaler) AppendMapEntry(fieldNum uint32) *MessageMarshaler {
+	return mm.AppendMessage(fieldNum)
+}
+
+// AppendStringStringMap appends map<string, string> under the given fieldNum to mm.
+//
+// Map entries are appended in the ascending order of keys if sortKeys is set or if deterministic mode is enabled via Marshaler.SetDeterministic().
+// Otherwise they are appended in the map iteration order, which is random in Go.
+func (mm *MessageMarshaler) AppendStringStringMap(fieldNum uint32, m map[string]string, sortKeys bool) {
+	AppendMap(mm, fieldNum, m, sortKeys, (*MessageMarshaler).AppendString, (*MessageMarshaler).AppendString)
+}
+
+// AppendMap appends protobuf map with the given fieldNum to mm.
+//
+// appendKey and appendValue must append the key and the value under the passed fieldNum.
+// For example, the following code appends map<string, sint64>:
+//
+//	easyproto.AppendMap(mm, fieldNum, m, true, (*easyproto.MessageMarshaler).AppendString, (*easyproto.MessageMarshaler).AppendSint64)
+//
+// Map entries are appended in the ascending order of keys if sortKeys is set or if deterministic mode is enabled via Marshaler.SetDeterministic().
+// Otherwise they are appended in the map iteration order, which is random in Go.
+func AppendMap[K MapKey, V any](mm *MessageMarshaler, fieldNum uint32, m map[K]V, sortKeys bool,
+	appendKey func(mm *MessageMarshaler, fieldNum uint32, k K), appendValue func(mm *MessageMarshaler, fieldNum uint32, v V)) {
+
+	if !sortKeys && !mm.m.deterministic {
+		for k, v := range m {
+			mmEntry := mm.AppendMapEntry(fieldNum)
+			appendKey(mmEntry, MapEntryKeyFieldNum, k)
+			appendValue(mmEntry, MapEntryValueFieldNum, v)
+		}
+		return
+	}
+
+	// Small maps are sorted in the buffer on the stack, so they do not allocate memory.
+	var keysBuf [64]K
+	keys := keysBuf[:0]
+	if len(m) > len(keysBuf) {
+		keys = make([]K, 0, len(m))
+	}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	heapSortKeys(keys)
+	for _, k := range keys {
+		mmEntry := mm.AppendMapEntry(fieldNum)
+		appendKey(mmEntry, MapEntryKeyFieldNum, k)
+		appendValue(mmEntry, MapEntryValueFieldNum, m[k])
+	}
+}
+
+// heapSortKeys sorts keys in ascending order.
+//
+// It uses heap sort instead of sort.Slice, since the latter allocates memory.
+func heapSortKeys[K MapKey](keys []K) {
+	for i := len(keys)/2 - 1; i >= 0; i-- {
+		siftDownMapKeys(keys, i)
+	}
+	for i := len(keys) - 1; i > 0; i-- {
+		keys[0], keys[i] = keys[i], keys[0]
+		siftDownMapKeys(keys[:i], 0)
+	}
+}
+
+func siftDownMapKeys[K MapKey](keys []K, root int) {
+	for {
+		child := 2*root + 1
+		if child >= len(keys) {
+			return
+		}
+		if child+1 < len(keys) && keys[child] < keys[child+1] {
+			child++
+		}
+		if keys[root] >= keys[child] {
+			return
+		}
+		keys[root], keys[child] = keys[child], keys[root]
+		root = child
+	}
+}
+
+// MapEntry returns the key and the value for map entry message at fc.
+//
+// The FieldNum for the returned key or value is set to 0 if the entry doesn't contain it.
+// The default value for the key or value type must be used in this case according to protobuf spec.
+// Unknown fields in the entry are ignored.
+//
+// The returned key and value refer to the data at fc, so they are valid while the underlying message isn't changed.
+//
+// DecodeError is returned on invalid entry. Its Offset is relative to the entry message data
+// and its Path contains fc.FieldNum if the entry contents are invalid.
+func (fc *FieldContext) MapEntry() (key, value FieldContext, err error) {
+	data, ok := fc.MessageData()
+	if !ok {
+		return key, value, newWireTypeError(0, fc.FieldNum, fc.wireType, WireTypeLen)
+	}
+
+	var fcEntry FieldContext
+	dataOrig := data
+	for len(data) > 0 {
+		tail := data
+		data, err = fcEntry.NextField(data)
+		if err != nil {
+			addErrorOffset(err, len(dataOrig)-len(tail))
+			setErrorPath(err, []uint32{fc.FieldNum})
+			return key, value, fmt.Errorf("cannot read map entry for fieldNum=%d: %w", fc.FieldNum, err)
+		}
+		switch fcEntry.FieldNum {
+		case MapEntryKeyFieldNum:
+			key = fcEntry
+		case MapEntryValueFieldNum:
+			value = fcEntry
+		}
+	}
+	return key, value, nil
+}
+
+// UnmarshalStringStringMap unmarshals map<string, string> with the given fieldNum from protobuf-encoded message at src into dst.
+//
+// New map is allocated if dst is nil. Missing keys and values are treated as empty strings according to protobuf spec.
+//
+// The unmarshaled keys and values refer to src, so they are valid while src isn't changed.
+func UnmarshalStringStringMap(src []byte, fieldNum uint32, dst map[string]string) (map[string]string, error) {
+	return UnmarshalMap(src, fieldNum, dst, (*FieldContext).String, (*FieldContext).String)
+}
+
+// UnmarshalMap unmarshals protobuf map with the given fieldNum from protobuf-encoded message at src into dst.
+//
+// getKey and getValue must return the key and the value from the passed FieldContext.
+// For example, the following code unmarshals map<string, sint64>:
+//
+//	m, err := easyproto.UnmarshalMap(src, fieldNum, m, (*easyproto.FieldContext).String, (*easyproto.FieldContext).Sint64)
+//
+// New map is allocated if dst is nil. Missing keys and values are set to zero values according to protobuf spec.
+// If the same key is encountered multiple times, then the last value wins.
+func UnmarshalMap[K comparable, V any](src []byte, fieldNum uint32, dst map[K]V,
+	getKey func(fc *FieldContext) (K, bool), getValue func(fc *FieldContext) (V, bool)) (map[K]V, error) {
+
+	if dst == nil {
+		dst = make(map[K]V)
+	}
+
+	fc := getFieldContext()
+	defer putFieldContext(fc)
+
+	srcOrig := src
+	for len(src) > 0 {
+		var err error
+		tail := src
+		src, err = fc.NextField(src)
+		if err != nil {
+			addErrorOffset(err, len(srcOrig)-len(tail))
+			return dst, fmt.Errorf("cannot read the next field while searching for fieldNum=%d: %w", fieldNum, err)
+		}
+		if fc.FieldNum != fieldNum {
+			continue
+		}
+		if fc.wireType != WireTypeLen {
+			return dst, newWireTypeError(len(srcOrig)-len(tail), fieldNum, fc.wireType, WireTypeLen)
+		}
+
+		// The entry data ends at src.
+		dataOffset := len(srcOrig) - len(src) - len(fc.data)
+		fcKey, fcValue, err := fc.MapEntry()
+		if err != nil {
+			addErrorOffset(err, dataOffset)
+			return dst, err
+		}
+		var k K
+		if fcKey.FieldNum != 0 {
+			var ok bool
+			k, ok = getKey(&fcKey)
+			if !ok {
+				kind := getValueErrorKind(&fcKey, getKey)
+				offset := dataOffset + lastFieldOffset(fc.data, MapEntryKeyFieldNum)
+				return dst, newDecodeError(kind, offset, MapEntryKeyFieldNum, fcKey.wireType, "cannot read map key for fieldNum=%d", fieldNum).withPath([]uint32{fieldNum})
+			}
+		}
+		var v V
+		if fcValue.FieldNum != 0 {
+			var ok bool
+			v, ok = getValue(&fcValue)
+			if !ok {
+				kind := getValueErrorKind(&fcValue, getValue)
+				offset := dataOffset + lastFieldOffset(fc.data, MapEntryValueFieldNum)
+				return dst, newDecodeError(kind, offset, MapEntryValueFieldNum, fcValue.wireType, "cannot read map value for fieldNum=%d", fieldNum).withPath([]uint32{fieldNum})
+			}
+		}
+		dst[k] = v
+	}
+	return dst, nil
+}
+
+// getValueErrorKind returns DecodeError kind for fc, which cannot be read with getValue.
+func getValueErrorKind[T any](fc *FieldContext, getValue func(fc *FieldContext) (T, bool)) error {
+	if fc.wireType != WireTypeVarint {
+		// Values with other wire types cannot overflow, so getValue expects another wire type.
+		return ErrBadWireType
+	}
+
+	// Zero varint can be read by every getter for varint values, so the value doesn't fit the destination type
+	// if the zero varint can be read.
+	fcZero := FieldContext{
+		FieldNum: fc.FieldNum,
+		wireType: WireTypeVarint,
+	}
+	if _, ok := getValue(&fcZero); ok {
+		return ErrOverflow
+	}
+	return ErrBadWireType
+}
+
+// lastFieldOffset returns the offset of the last field with the given fieldNum at valid protobuf-encoded message at src.
+//
+// It is used for reporting offsets in error messages, so it is called only on error path.
+func lastFieldOffset(src []byte, fieldNum uint32) int {
+	var fc FieldContext
+	srcOrig := src
+	offset := 0
+	for len(src) > 0 {
+		tail := src
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			break
+		}
+		if fc.FieldNum == fieldNum {
+			offset = len(srcOrig) - len(tail)
+		}
+	}
+	return offset
+}
@@ -0,0 +1,251 @@
+package easyproto
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestStringStringMapMarshalUnmarshal(t *testing.T) {
+	f := func(m map[string]string) {
+		t.Helper()
+
+		var mr Marshaler
+		mm := mr.MessageMarshaler()
+		mm.AppendInt32(1, 10)
+		mm.AppendStringStringMap(2, m, false)
+		mm.AppendInt32(3, 20)
+		data := mr.Marshal(nil)
+
+		result, err := UnmarshalStringStringMap(data, 2, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(m) == 0 {
+			if len(result) != 0 {
+				t.Fatalf("unexpected non-empty result: %v", result)
+			}
+			return
+		}
+		if !reflect.DeepEqual(result, m) {
+			t.Fatalf("unexpected result\ngot\n%v\nwant\n%v", result, m)
+		}
+	}
+
+	f(nil)
+	f(map[string]string{
+		"foo": "bar",
+	})
+	f(map[string]string{
+		"foo": "bar",
+		"":    "empty key",
+		"baz": "",
+		"x":   "y",
+	})
+}
+
+func TestAppendMapSortKeys(t *testing.T) {
+	m := map[string]string{
+		"c": "3",
+		"a": "1",
+		"b": "2",
+		"d": "4",
+	}
+
+	var mr Marshaler
+	mm := mr.MessageMarshaler()
+	mm.AppendStringStringMap(1, m, true)
+	result := mr.Marshal(nil)
+
+	mr.Reset()
+	mm = mr.MessageMarshaler()
+	for _, k := range []string{"a", "b", "c", "d"} {
+		mmEntry := mm.AppendMapEntry(1)
+		mmEntry.AppendString(MapEntryKeyFieldNum, k)
+		mmEntry.AppendString(MapEntryValueFieldNum, m[k])
+	}
+	resultExpected := mr.Marshal(nil)
+
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+	}
+}
+
+func TestAppendMapSortKeysZeroAlloc(t *testing.T) {
+	m := map[uint32]string{
+		3: "c",
+		1: "a",
+		2: "b",
+	}
+	var mr Marshaler
+	var data []byte
+	allocs := testing.AllocsPerRun(100, func() {
+		mr.Reset()
+		AppendMap(mr.MessageMarshaler(), 1, m, true, (*MessageMarshaler).AppendUint32, (*MessageMarshaler).AppendString)
+		data = mr.Marshal(data[:0])
+	})
+	if allocs != 0 {
+		t.Fatalf("unexpected number of allocations; got %v; want 0", allocs)
+	}
+}
+
+func TestHeapSortKeys(t *testing.T) {
+	f := func(keys []int64) {
+		t.Helper()
+
+		keysExpected := append([]int64(nil), keys...)
+		sort.Slice(keysExpected, func(i, j int) bool {
+			return keysExpected[i] < keysExpected[j]
+		})
+		heapSortKeys(keys)
+		if !reflect.DeepEqual(keys, keysExpected) {
+			t.Fatalf("unexpected result\ngot\n%v\nwant\n%v", keys, keysExpected)
+		}
+	}
+
+	f(nil)
+	f([]int64{1})
+	f([]int64{2, 1})
+	f([]int64{1, 2, 3})
+	f([]int64{3, 2, 1, 0, -1, -2})
+	f([]int64{5, 1, 5, 1, 5})
+
+	r := rand.New(rand.NewSource(1))
+	keys := make([]int64, 1000)
+	for i := range keys {
+		keys[i] = r.Int63n(500) - 250
+	}
+	f(keys)
+}
+
+func TestAppendMapGeneric(t *testing.T) {
+	m := map[int64]float64{
+		-1:  1.5,
+		0:   0,
+		123: -2.25,
+	}
+
+	var mr Marshaler
+	mm := mr.MessageMarshaler()
+	AppendMap(mm, 5, m, true, (*MessageMarshaler).AppendSint64, (*MessageMarshaler).AppendDouble)
+	data := mr.Marshal(nil)
+
+	result, err := UnmarshalMap(data, 5, nil, (*FieldContext).Sint64, (*FieldContext).Double)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(result, m) {
+		t.Fatalf("unexpected result\ngot\n%v\nwant\n%v", result, m)
+	}
+}
+
+func TestUnmarshalMapMissingKeyValue(t *testing.T) {
+	var mr Marshaler
+	mm := mr.MessageMarshaler()
+
+	// missing value
+	mmEntry := mm.AppendMapEntry(1)
+	mmEntry.AppendString(MapEntryKeyFieldNum, "foo")
+
+	// missing key
+	mmEntry = mm.AppendMapEntry(1)
+	mmEntry.AppendString(MapEntryValueFieldNum, "bar")
+
+	// unknown field and duplicate key
+	mmEntry = mm.AppendMapEntry(1)
+	mmEntry.AppendString(MapEntryKeyFieldNum, "baz")
+	mmEntry.AppendInt32(3, 123)
+	mmEntry.AppendString(MapEntryValueFieldNum, "abc")
+	mmEntry = mm.AppendMapEntry(1)
+	mmEntry.AppendString(MapEntryKeyFieldNum, "baz")
+	mmEntry.AppendString(MapEntryValueFieldNum, "def")
+	data := mr.Marshal(nil)
+
+	result, err := UnmarshalStringStringMap(data, 1, map[string]string{
+		"x": "y",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resultExpected := map[string]string{
+		"x":   "y",
+		"foo": "",
+		"":    "bar",
+		"baz": "def",
+	}
+	if !reflect.DeepEqual(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%v\nwant\n%v", result, resultExpected)
+	}
+}
+
+func TestMapEntry(t *testing.T) {
+	var mr Marshaler
+	mm := mr.MessageMarshaler()
+	mmEntry := mm.AppendMapEntry(1)
+	mmEntry.AppendUint32(MapEntryKeyFieldNum, 42)
+	mmEntry.AppendString(MapEntryValueFieldNum, "foo")
+	mm.AppendInt32(2, 123)
+	data := mr.Marshal(nil)
+
+	var fc FieldContext
+	if _, err := fc.FieldByNum(data, 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	key, value, err := fc.MapEntry()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	k, ok := key.Uint32()
+	if !ok || k != 42 {
+		t.Fatalf("unexpected key; got %d, ok=%v; want 42", k, ok)
+	}
+	v, ok := value.String()
+	if !ok || v != "foo" {
+		t.Fatalf("unexpected value; got %q, ok=%v; want %q", v, ok, "foo")
+	}
+
+	// Non-message field
+	if _, err := fc.FieldByNum(data, 2); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, _, err = fc.MapEntry()
+	var wte *WireTypeError
+	if !errors.As(err, &wte) {
+		t.Fatalf("expecting *WireTypeError; got %v", err)
+	}
+}
+
+func TestUnmarshalMapFailure(t *testing.T) {
+	f := func(data []byte, kindExpected error, offsetExpected int, fieldNumExpected uint32, pathExpected []uint32) {
+		t.Helper()
+
+		_, err := UnmarshalStringStringMap(data, 1, nil)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		checkDecodeError(t, err, kindExpected, offsetExpected, fieldNumExpected, pathExpected)
+	}
+
+	// invalid message
+	f([]byte{0x0a, 0x05, 0x0a}, ErrTruncated, 0, 1, nil)
+
+	// non-message entry
+	f([]byte{0x10, 0x01, 0x08, 0x01}, ErrBadWireType, 2, 1, nil)
+
+	// invalid entry contents
+	f([]byte{0x10, 0x01, 0x0a, 0x02, 0x0a, 0x05}, ErrTruncated, 4, 1, []uint32{1})
+
+	// invalid key type
+	f([]byte{0x0a, 0x04, 0x12, 0x00, 0x08, 0x01}, ErrBadWireType, 4, MapEntryKeyFieldNum, []uint32{1})
+
+	// invalid value type
+	f([]byte{0x0a, 0x02, 0x10, 0x01}, ErrBadWireType, 2, MapEntryValueFieldNum, []uint32{1})
+
+	// too big value
+	data := []byte{0x0a, 0x07, 0x10, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}
+	_, err := UnmarshalMap(data, 1, nil, (*FieldContext).String, (*FieldContext).Uint32)
+	checkDecodeError(t, err, ErrOverflow, 2, MapEntryValueFieldNum, []uint32{1})
+}
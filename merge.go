@@ -0,0 +1,244 @@
+package easyproto
+
+import (
+	"fmt"
+)
+
+// MergeKind defines how the field is merged by MergeWithPolicy.
+type MergeKind int
+
+const (
+	// MergeAppend appends values from all the merged messages in their order.
+	//
+	// This is the correct merge for repeated fields. It is also safe for any other field,
+	// since protobuf parsers take the last value for scalar fields and merge embedded messages,
+	// but the result isn't compacted in this case.
+	MergeAppend MergeKind = iota
+
+	// MergeReplace leaves only the last value among the merged messages.
+	//
+	// This is the merge for singular scalar, string and bytes fields.
+	MergeReplace
+
+	// MergeMessage merges embedded messages or groups recursively.
+	//
+	// This is the merge for singular embedded message fields.
+	MergeMessage
+)
+
+// MergePolicy returns MergeKind for the field at the given path.
+//
+// path contains field numbers from the top-level message down to the merged field,
+// e.g. the last item in the path is the field number of the merged field.
+// path must not be modified or retained after returning from the function.
+//
+// wireType is the wire type of the first occurrence of the field.
+//
+// MergePolicy is needed since the wire format doesn't say whether the field is repeated or contains embedded message.
+type MergePolicy func(path []uint32, wireType WireType) MergeKind
+
+// Merge merges protobuf-encoded messages a and b, appends the result to dst and returns it.
+//
+// Every field is merged with MergeAppend, so the result is equivalent to concatenation of a and b.
+// This is valid protobuf merge, since parsers take the last value for singular scalar fields and merge embedded messages.
+// But the result isn't compacted: singular fields and embedded messages set in both a and b are stored twice,
+// so the result grows with every merge. The wire format doesn't tell whether the field is repeated,
+// so Merge cannot compact the result without the message schema.
+// Use MergeWithPolicy with the policy based on the message schema for compacting the merged message.
+func Merge(dst, a, b []byte) ([]byte, error) {
+	return MergeWithPolicy(dst, a, b, nil)
+}
+
+// MergeWithPolicy merges protobuf-encoded messages a and b according to protobuf merge semantics,
+// appends the result to dst and returns it.
+//
+// policy is called for every field in order to decide how to merge it. Every field is merged with MergeAppend if policy is nil.
+//
+// Fields in the merged message are ordered in the same way as in the concatenation of a and b.
+// Fields merged with MergeReplace and MergeMessage are put at the position of their last occurrence.
+// This preserves the protobuf semantics for oneof fields: if a and b set distinct oneof members,
+// then the member, which is set last, wins when parsing the merged message.
+func MergeWithPolicy(dst, a, b []byte, policy MergePolicy) ([]byte, error) {
+	m := mergeMarshalerPool.Get()
+	defer mergeMarshalerPool.Put(m)
+
+	mg := merger{
+		policy: policy,
+	}
+	if policy != nil {
+		mg.states = make(map[mergeStateKey]int)
+	}
+	srcs := [][]byte{a, b}
+	if err := mg.mergeMessages(m.MessageMarshaler(), srcs); err != nil {
+		return dst, err
+	}
+	dst = m.Marshal(dst)
+	return dst, nil
+}
+
+var mergeMarshalerPool MarshalerPool
+
+type merger struct {
+	// policy is the policy for merging fields.
+	policy MergePolicy
+
+	// path contains field numbers for the currently merged field.
+	path []uint32
+
+	// fields contains fields collected from the merged messages at all the nesting levels, which are currently merged.
+	//
+	// Every nesting level appends its fields to the end of fields and truncates them back when it is done,
+	// so the memory is reused across nesting levels.
+	fields []mergeField
+
+	// fieldStates contains merge states for the fields at all the nesting levels, which are currently merged.
+	//
+	// It is reused across nesting levels in the same way as fields.
+	fieldStates []mergeFieldState
+
+	// states maps fields at the given nesting level to their states at fieldStates.
+	states map[mergeStateKey]int
+}
+
+// mergeStateKey is the key for merger.states.
+type mergeStateKey struct {
+	depth    int
+	fieldNum uint32
+}
+
+// mergeField is a field collected from the merged messages.
+type mergeField struct {
+	fieldNum uint32
+	rawField []byte
+}
+
+// mergeFieldState contains the merge state for all the fields with the same field number.
+type mergeFieldState struct {
+	// kind is the merge kind for the field.
+	kind MergeKind
+
+	// lastIdx is the index of the last occurrence of the field at merger.fields.
+	lastIdx int
+
+	// isGroup is set if the first occurrence of the field is encoded as group.
+	isGroup bool
+
+	// datas contains embedded messages for the field merged with MergeMessage.
+	datas [][]byte
+}
+
+// mergeMessages merges protobuf-encoded messages at srcs into mm.
+func (mg *merger) mergeMessages(mm *MessageMarshaler, srcs [][]byte) error {
+	for i, src := range srcs {
+		if err := checkMessage(src); err != nil {
+			return fmt.Errorf("cannot parse message #%d at path=%s: %w", i, formatPath(mg.path), err)
+		}
+	}
+
+	fc := getFieldContext()
+	defer putFieldContext(fc)
+
+	if mg.policy == nil {
+		// Fast path - all the fields are merged with MergeAppend.
+		for _, src := range srcs {
+			for len(src) > 0 {
+				src, _ = fc.NextField(src)
+				mm.AppendRawField(fc.RawField())
+			}
+		}
+		return nil
+	}
+
+	// Collect fields in the order of concatenation of srcs.
+	depth := len(mg.path)
+	fieldsStart := len(mg.fields)
+	statesStart := len(mg.fieldStates)
+	defer func() {
+		for _, f := range mg.fields[fieldsStart:] {
+			delete(mg.states, mergeStateKey{depth, f.fieldNum})
+		}
+		mg.fields = mg.fields[:fieldsStart]
+		mg.fieldStates = mg.fieldStates[:statesStart]
+	}()
+	for _, src := range srcs {
+		for len(src) > 0 {
+			src, _ = fc.NextField(src)
+			key := mergeStateKey{depth, fc.FieldNum}
+			stateIdx, ok := mg.states[key]
+			if !ok {
+				mg.path = append(mg.path, fc.FieldNum)
+				kind := mg.policy(mg.path, fc.wireType)
+				mg.path = mg.path[:len(mg.path)-1]
+				if kind != MergeAppend && kind != MergeReplace && kind != MergeMessage {
+					return fmt.Errorf("unknown MergeKind=%d returned from MergePolicy for path=%s", kind, formatPath(append(mg.path, fc.FieldNum)))
+				}
+				stateIdx = mg.addFieldState(kind, fc.wireType == WireTypeSGroup)
+				mg.states[key] = stateIdx
+			}
+			st := &mg.fieldStates[stateIdx]
+			if st.kind == MergeMessage {
+				if fc.wireType != WireTypeLen && fc.wireType != WireTypeSGroup {
+					return fmt.Errorf("cannot merge field at path=%s as message; unexpected wireType=%s", formatPath(append(mg.path, fc.FieldNum)), fc.wireType)
+				}
+				st.datas = append(st.datas, fc.data)
+			}
+			st.lastIdx = len(mg.fields)
+			mg.fields = append(mg.fields, mergeField{
+				fieldNum: fc.FieldNum,
+				rawField: fc.RawField(),
+			})
+		}
+	}
+
+	// Nested levels append items to mg.fields and mg.fieldStates, so the items are accessed by indexes
+	// instead of pointers, which may become invalid after re-allocation.
+	fieldsEnd := len(mg.fields)
+	for i := fieldsStart; i < fieldsEnd; i++ {
+		f := mg.fields[i]
+		stateIdx := mg.states[mergeStateKey{depth, f.fieldNum}]
+		st := mg.fieldStates[stateIdx]
+		if st.kind == MergeAppend {
+			mm.AppendRawField(f.rawField)
+			continue
+		}
+		if i != st.lastIdx {
+			continue
+		}
+		if st.kind == MergeReplace {
+			mm.AppendRawField(f.rawField)
+			continue
+		}
+
+		var mmChild *MessageMarshaler
+		if st.isGroup {
+			mmChild = mm.AppendGroup(f.fieldNum)
+		} else {
+			mmChild = mm.AppendMessage(f.fieldNum)
+		}
+		mg.path = append(mg.path, f.fieldNum)
+		err := mg.mergeMessages(mmChild, st.datas)
+		mg.path = mg.path[:len(mg.path)-1]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFieldState adds the state for the field with the given kind to mg.fieldStates and returns its index.
+//
+// The state left from the previously merged nesting level is reused if possible, so its datas buffer is reused.
+func (mg *merger) addFieldState(kind MergeKind, isGroup bool) int {
+	n := len(mg.fieldStates)
+	if n < cap(mg.fieldStates) {
+		mg.fieldStates = mg.fieldStates[:n+1]
+	} else {
+		mg.fieldStates = append(mg.fieldStates, mergeFieldState{})
+	}
+	st := &mg.fieldStates[n]
+	st.kind = kind
+	st.lastIdx = 0
+	st.isGroup = isGroup
+	st.datas = st.datas[:0]
+	return n
+}
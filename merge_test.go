@@ -0,0 +1,297 @@
+package easyproto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendInt32(1, 10)
+	mm.AppendString(2, "foo")
+	a := m.Marshal(nil)
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	mm.AppendString(2, "bar")
+	mm.AppendInt32(1, 20)
+	b := m.Marshal(nil)
+
+	result, err := Merge([]byte("prefix"), a, b)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	resultExpected := append([]byte("prefix"), a...)
+	resultExpected = append(resultExpected, b...)
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+	}
+
+	// The merged message must be equal to the concatenation of a and b
+	equal, err := Equal(result[len("prefix"):], append(a, b...))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !equal {
+		t.Fatalf("the merged message must be equal to concatenation of a and b")
+	}
+}
+
+func TestMergeWithPolicy(t *testing.T) {
+	// message Foo {
+	//   int32 scalar = 1;
+	//   repeated string repeated = 2;
+	//   Bar msg = 3;
+	//   repeated uint64 packed = 4;
+	// }
+	//
+	// message Bar {
+	//   string name = 1;
+	//   repeated int32 values = 2;
+	// }
+	policy := func(path []uint32, wireType WireType) MergeKind {
+		switch {
+		case len(path) == 1 && path[0] == 3:
+			return MergeMessage
+		case len(path) == 1 && path[0] == 1:
+			return MergeReplace
+		case len(path) == 2 && path[0] == 3 && path[1] == 1:
+			if wireType != WireTypeLen {
+				panic("unexpected wire type")
+			}
+			return MergeReplace
+		default:
+			return MergeAppend
+		}
+	}
+
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendInt32(1, 10)
+	mm.AppendString(2, "a")
+	mmBar := mm.AppendMessage(3)
+	mmBar.AppendString(1, "foo")
+	mmBar.AppendInt32(2, 1)
+	mm.AppendUint64s(4, []uint64{1, 2})
+	mm.AppendInt32(100, 1)
+	a := m.Marshal(nil)
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	mmBar = mm.AppendMessage(3)
+	mmBar.AppendInt32(2, 2)
+	mmBar.AppendString(1, "bar")
+	mm.AppendString(2, "b")
+	mm.AppendInt32(1, 20)
+	mm.AppendUint64s(4, []uint64{3})
+	mmBar = mm.AppendMessage(3)
+	mmBar.AppendInt32(2, 3)
+	b := m.Marshal(nil)
+
+	result, err := MergeWithPolicy(nil, a, b, policy)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Fields must be ordered as in the concatenation of a and b,
+	// while replaced and merged fields must be put at the position of their last occurrence.
+	m.Reset()
+	mm = m.MessageMarshaler()
+	mm.AppendString(2, "a")
+	mm.AppendUint64s(4, []uint64{1, 2})
+	mm.AppendInt32(100, 1)
+	mm.AppendString(2, "b")
+	mm.AppendInt32(1, 20)
+	mm.AppendUint64s(4, []uint64{3})
+	mmBar = mm.AppendMessage(3)
+	mmBar.AppendInt32(2, 1)
+	mmBar.AppendInt32(2, 2)
+	mmBar.AppendString(1, "bar")
+	mmBar.AppendInt32(2, 3)
+	resultExpected := m.Marshal(nil)
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+	}
+}
+
+func TestMergeWithPolicyOneof(t *testing.T) {
+	// message Foo {
+	//   oneof value {
+	//     string name = 1;
+	//     int64 id = 2;
+	//   }
+	// }
+	policy := func(path []uint32, wireType WireType) MergeKind {
+		return MergeReplace
+	}
+
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(2, 123)
+	a := m.Marshal(nil)
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	b := m.Marshal(nil)
+
+	result, err := MergeWithPolicy(nil, a, b, policy)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The oneof member set in b must be located after the member set in a, so it wins on parsing.
+	resultExpected := append(append([]byte{}, a...), b...)
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+	}
+}
+
+func TestMergeWithPolicyGroup(t *testing.T) {
+	policy := func(path []uint32, wireType WireType) MergeKind {
+		if wireType == WireTypeSGroup {
+			return MergeMessage
+		}
+		return MergeReplace
+	}
+
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mmGroup := mm.AppendGroup(1)
+	mmGroup.AppendInt32(1, 10)
+	a := m.Marshal(nil)
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	mmGroup = mm.AppendGroup(1)
+	mmGroup.AppendInt32(2, 20)
+	b := m.Marshal(nil)
+
+	result, err := MergeWithPolicy(nil, a, b, policy)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	mmGroup = mm.AppendGroup(1)
+	mmGroup.AppendInt32(1, 10)
+	mmGroup.AppendInt32(2, 20)
+	resultExpected := m.Marshal(nil)
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+	}
+}
+
+func TestMergeFailure(t *testing.T) {
+	messagePolicy := func(path []uint32, wireType WireType) MergeKind {
+		return MergeMessage
+	}
+
+	f := func(a, b []byte, policy MergePolicy) {
+		t.Helper()
+
+		_, err := MergeWithPolicy(nil, a, b, policy)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// invalid messages
+	f([]byte{0x08, 0x80}, nil, nil)
+	f(nil, []byte{0x0a, 0x05}, nil)
+
+	// scalar field merged as message
+	f([]byte{0x08, 0x01}, nil, messagePolicy)
+
+	// string merged as message
+	f([]byte{0x0a, 0x03, 'f', 'o', 'o'}, nil, messagePolicy)
+
+	// unknown merge kind
+	f([]byte{0x08, 0x01}, nil, func(path []uint32, wireType WireType) MergeKind {
+		return MergeKind(100)
+	})
+}
+
+func TestMergeWithPolicyManyFields(t *testing.T) {
+	// Messages with many distinct fields must be merged in linear time.
+	const fieldsCount = 100_000
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	for i := fieldsCount; i > 0; i-- {
+		mm.AppendUint32(uint32(i), uint32(i))
+	}
+	a := m.Marshal(nil)
+
+	policy := func(path []uint32, wireType WireType) MergeKind {
+		return MergeReplace
+	}
+	result, err := MergeWithPolicy(nil, a, a, policy)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(result, a) {
+		t.Fatalf("unexpected result for merging the message with itself")
+	}
+}
+
+func TestMergeWithPolicyNested(t *testing.T) {
+	// message Foo {
+	//   int32 value = 2;
+	//   Foo a = 1;
+	//   Foo b = 3;
+	// }
+	policy := func(path []uint32, _ WireType) MergeKind {
+		if path[len(path)-1] == 2 {
+			return MergeReplace
+		}
+		return MergeMessage
+	}
+	var appendNested func(mm *MessageMarshaler, depth int, value int32)
+	appendNested = func(mm *MessageMarshaler, depth int, value int32) {
+		if depth == 0 {
+			return
+		}
+		mm.AppendInt32(2, value)
+		appendNested(mm.AppendMessage(1), depth-1, value)
+		appendNested(mm.AppendMessage(3), depth-1, value)
+	}
+	marshalNested := func(depth int, value int32) []byte {
+		var m Marshaler
+		appendNested(m.MessageMarshaler(), depth, value)
+		return m.Marshal(nil)
+	}
+
+	const depth = 6
+	a := marshalNested(depth, 1)
+	b := marshalNested(depth, 2)
+	resultExpected := marshalNested(depth, 2)
+
+	result, err := MergeWithPolicy(nil, a, b, policy)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", result, resultExpected)
+	}
+
+	if isRaceEnabled {
+		// The race detector makes sync.Pool to drop items randomly, so allocations cannot be checked.
+		return
+	}
+
+	// Memory for merge states must be reused across nested messages,
+	// so the number of allocations mustn't grow with the number of nested messages.
+	var buf []byte
+	allocs := testing.AllocsPerRun(10, func() {
+		buf, err = MergeWithPolicy(buf[:0], a, b, policy)
+		if err != nil {
+			panic(err)
+		}
+	})
+	if allocs >= 1<<depth {
+		t.Fatalf("too many allocations for merging %d nested messages; got %v; want less than %d", 1<<(depth+1)-2, allocs, 1<<depth)
+	}
+}
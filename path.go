@@ -0,0 +1,470 @@
+package easyproto
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// RangeByPath calls f for every field at the given path of field numbers at protobuf-encoded src.
+//
+// All the fields in the path except of the last one must be embedded messages.
+// Every repeated occurrence of every field in the path is visited. For example, RangeByPath(src, []uint32{1, 3, 2}, f)
+// calls f for every field #2 in every message #3 in every message #1 at src.
+//
+// The iteration stops when f returns false. The FieldContext passed to f is valid only during the f call.
+//
+// It is unsafe modifying src while RangeByPath is in progress.
+func RangeByPath(src []byte, path []uint32, f func(fc *FieldContext) bool) error {
+	return rangeByPathWithOffset(src, path, func(fc *FieldContext, _ int) bool {
+		return f(fc)
+	})
+}
+
+// rangeByPathWithOffset calls f for every field at the given path at src.
+//
+// The offset of the field at src is passed to f.
+func rangeByPathWithOffset(src []byte, path []uint32, f func(fc *FieldContext, offset int) bool) error {
+	if len(path) == 0 {
+		return fmt.Errorf("path cannot be empty")
+	}
+	_, err := rangeByPath(src, cap(src), path, 0, f)
+	return err
+}
+
+// rangeByPath calls f for every field at path[depth:] at src.
+//
+// rootCap is the capacity of the top-level message. It is used for calculating offsets of fields at src,
+// since src is a sub-slice of the top-level message.
+//
+// It returns false if f returned false, e.g. the iteration must be stopped.
+func rangeByPath(src []byte, rootCap int, path []uint32, depth int, f func(fc *FieldContext, offset int) bool) (bool, error) {
+	fc := getFieldContext()
+	defer putFieldContext(fc)
+
+	fieldNum := path[depth]
+	isLast := depth == len(path)-1
+	for len(src) > 0 {
+		var err error
+		offset := rootCap - cap(src)
+		src, err = fc.NextField(src)
+		if err != nil {
+			addErrorOffset(err, offset)
+			setErrorPath(err, path[:depth])
+			return false, fmt.Errorf("cannot read the next field while searching for path=%s: %w", formatPath(path[:depth+1]), err)
+		}
+		if fc.FieldNum != fieldNum {
+			continue
+		}
+		if isLast {
+			if !f(fc, offset) {
+				return false, nil
+			}
+			continue
+		}
+		data, ok := fc.MessageData()
+		if !ok {
+			return false, newWireTypeError(offset, fieldNum, fc.wireType, WireTypeLen).withPath(path[:depth])
+		}
+		ok, err = rangeByPath(data, rootCap, path, depth+1, f)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// formatPath returns string representation of path for error messages.
+//
+// It is used instead of passing path to fmt functions in order to avoid heap allocation for path on the hot path.
+func formatPath(path []uint32) string {
+	b := []byte{'['}
+	for i, fieldNum := range path {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		b = strconv.AppendUint(b, uint64(fieldNum), 10)
+	}
+	b = append(b, ']')
+	return string(b)
+}
+
+// getFieldByPath sets fc to the first field at the given path with the given neededWireType at src.
+//
+// It returns the offset of the found field at src.
+func (fc *FieldContext) getFieldByPath(src []byte, path []uint32, neededWireType WireType) (int, bool, error) {
+	found := false
+	fieldOffset := 0
+	err := rangeByPathWithOffset(src, path, func(fcFound *FieldContext, offset int) bool {
+		*fc = *fcFound
+		found = true
+		fieldOffset = offset
+		return false
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if !found {
+		return 0, false, nil
+	}
+	if fc.wireType != neededWireType {
+		return fieldOffset, false, newWireTypeError(fieldOffset, fc.FieldNum, fc.wireType, neededWireType).withPath(path[:len(path)-1])
+	}
+	return fieldOffset, true, nil
+}
+
+// GetInt32ByPath returns the int32 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetInt32ByPath(src []byte, path ...uint32) (n int32, ok bool, err error) {
+	var fc FieldContext
+	offset, ok, err := fc.getFieldByPath(src, path, WireTypeVarint)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	n, ok = getInt32(fc.intValue)
+	if !ok {
+		return 0, false, newDecodeError(ErrOverflow, offset, fc.FieldNum, fc.wireType, "path=%s contains too big integer %d, which cannot be converted to int32", formatPath(path), fc.intValue).withPath(path[:len(path)-1])
+	}
+	return n, true, nil
+}
+
+// GetInt64ByPath returns the int64 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetInt64ByPath(src []byte, path ...uint32) (n int64, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeVarint)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	return int64(fc.intValue), true, nil
+}
+
+// GetUint32ByPath returns the uint32 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetUint32ByPath(src []byte, path ...uint32) (n uint32, ok bool, err error) {
+	var fc FieldContext
+	offset, ok, err := fc.getFieldByPath(src, path, WireTypeVarint)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	n, ok = getUint32(fc.intValue)
+	if !ok {
+		return 0, false, newDecodeError(ErrOverflow, offset, fc.FieldNum, fc.wireType, "path=%s contains too big integer %d, which cannot be converted to uint32", formatPath(path), fc.intValue).withPath(path[:len(path)-1])
+	}
+	return n, true, nil
+}
+
+// GetUint64ByPath returns the int64 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetUint64ByPath(src []byte, path ...uint32) (n uint64, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeVarint)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	return fc.intValue, true, nil
+}
+
+// GetSint32ByPath returns sint32 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetSint32ByPath(src []byte, path ...uint32) (n int32, ok bool, err error) {
+	var fc FieldContext
+	offset, ok, err := fc.getFieldByPath(src, path, WireTypeVarint)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	u32, ok := getUint32(fc.intValue)
+	if !ok {
+		return 0, false, newDecodeError(ErrOverflow, offset, fc.FieldNum, fc.wireType, "path=%s contains too big integer %d, which cannot be converted to uint32", formatPath(path), fc.intValue).withPath(path[:len(path)-1])
+	}
+	n = decodeZigZagInt32(u32)
+	return n, true, nil
+}
+
+// GetSint64ByPath returns sint64 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetSint64ByPath(src []byte, path ...uint32) (n int64, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeVarint)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	n = decodeZigZagInt64(fc.intValue)
+	return n, true, nil
+}
+
+// GetBoolByPath returns bool value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetBoolByPath(src []byte, path ...uint32) (b bool, ok bool, err error) {
+	var fc FieldContext
+	offset, ok, err := fc.getFieldByPath(src, path, WireTypeVarint)
+	if err != nil {
+		return false, false, err
+	}
+	if !ok {
+		return false, false, nil
+	}
+	b, ok = getBool(fc.intValue)
+	if !ok {
+		return false, false, newDecodeError(ErrOverflow, offset, fc.FieldNum, fc.wireType, "path=%s contains invalid integer %d, which cannot be converted to bool", formatPath(path), fc.intValue).withPath(path[:len(path)-1])
+	}
+	return b, true, nil
+}
+
+// GetEnumByPath returns enum value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetEnumByPath(src []byte, path ...uint32) (n int32, ok bool, err error) {
+	var fc FieldContext
+	offset, ok, err := fc.getFieldByPath(src, path, WireTypeVarint)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	n, ok = getInt32(fc.intValue)
+	if !ok {
+		return 0, false, newDecodeError(ErrOverflow, offset, fc.FieldNum, fc.wireType, "path=%s contains invalid integer %d, which cannot be converted to enum", formatPath(path), fc.intValue).withPath(path[:len(path)-1])
+	}
+	return n, true, nil
+}
+
+// GetFixed64ByPath returns fixed64 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetFixed64ByPath(src []byte, path ...uint32) (n uint64, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeI64)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	return fc.intValue, true, nil
+}
+
+// GetSfixed64ByPath returns sfixed64 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetSfixed64ByPath(src []byte, path ...uint32) (n int64, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeI64)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	return int64(fc.intValue), true, nil
+}
+
+// GetDoubleByPath returns double value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetDoubleByPath(src []byte, path ...uint32) (f float64, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeI64)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	f = math.Float64frombits(fc.intValue)
+	return f, true, nil
+}
+
+// GetStringByPath returns string value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+// The returned string is valid until src is changed.
+//
+// See also RangeByPath.
+func GetStringByPath(src []byte, path ...uint32) (s string, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeLen)
+	if err != nil {
+		return "", false, err
+	}
+	if !ok {
+		return "", false, nil
+	}
+	return unsafeBytesToString(fc.data), true, nil
+}
+
+// GetBytesByPath returns bytes slice for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+// The returned bytes slice is valid until src is changed.
+//
+// See also RangeByPath.
+func GetBytesByPath(src []byte, path ...uint32) (b []byte, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeLen)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	return fc.data, true, nil
+}
+
+// GetMessageDataByPath returns message data for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+// The returned message data is valid until src is changed.
+//
+// See also RangeByPath.
+func GetMessageDataByPath(src []byte, path ...uint32) (data []byte, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeLen)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	return fc.data, true, nil
+}
+
+// GetFixed32ByPath returns fixed32 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetFixed32ByPath(src []byte, path ...uint32) (n uint32, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeI32)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	n = mustGetUint32(fc.intValue)
+	return n, true, nil
+}
+
+// GetSfixed32ByPath returns sfixed32 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetSfixed32ByPath(src []byte, path ...uint32) (n int32, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeI32)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	n = mustGetInt32(fc.intValue)
+	return n, true, nil
+}
+
+// GetFloatByPath returns float32 value for the given path of field numbers from protobuf-encoded message at src.
+//
+// ok=false is returned if src doesn't contain a field at the given path of field numbers.
+// All the fields in the path except of the last one must be embedded messages.
+// The first found field is returned if there are multiple fields at the given path.
+//
+// See also RangeByPath.
+func GetFloatByPath(src []byte, path ...uint32) (f float32, ok bool, err error) {
+	var fc FieldContext
+	_, ok, err = fc.getFieldByPath(src, path, WireTypeI32)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	u32 := mustGetUint32(fc.intValue)
+	f = math.Float32frombits(u32)
+	return f, true, nil
+}
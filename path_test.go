@@ -0,0 +1,183 @@
+package easyproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func marshalPathTestMessage() []byte {
+	// message resource {
+	//   repeated attribute attributes = 3;
+	//   int64 id = 4;
+	// }
+	// message attribute {
+	//   string key = 1;
+	//   string value = 2;
+	// }
+	// message root {
+	//   repeated resource resources = 1;
+	//   string name = 2;
+	// }
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	for i, keys := range [][]string{{"foo", "bar"}, {"baz"}} {
+		r := mm.AppendMessage(1)
+		for _, key := range keys {
+			a := r.AppendMessage(3)
+			a.AppendString(1, key)
+			a.AppendString(2, key+"_value")
+		}
+		r.AppendInt64(4, int64(i+10))
+	}
+	mm.AppendString(2, "root")
+	data := m.Marshal(nil)
+	mp.Put(m)
+	return data
+}
+
+func TestRangeByPath(t *testing.T) {
+	data := marshalPathTestMessage()
+
+	f := func(path []uint32, valuesExpected []string) {
+		t.Helper()
+
+		var values []string
+		err := RangeByPath(data, path, func(fc *FieldContext) bool {
+			s, ok := fc.String()
+			if !ok {
+				t.Fatalf("cannot read string at path=%v", path)
+			}
+			values = append(values, s)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(values, valuesExpected) {
+			t.Fatalf("unexpected values at path=%v; got %q; want %q", path, values, valuesExpected)
+		}
+	}
+
+	f([]uint32{1, 3, 1}, []string{"foo", "bar", "baz"})
+	f([]uint32{1, 3, 2}, []string{"foo_value", "bar_value", "baz_value"})
+	f([]uint32{2}, []string{"root"})
+	f([]uint32{1, 5}, nil)
+	f([]uint32{1, 3, 5, 1}, nil)
+	f([]uint32{10, 3}, nil)
+
+	// Verify early stop
+	calls := 0
+	err := RangeByPath(data, []uint32{1, 3, 1}, func(_ *FieldContext) bool {
+		calls++
+		return calls < 2
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls != 2 {
+		t.Fatalf("unexpected number of calls; got %d; want 2", calls)
+	}
+}
+
+func TestRangeByPathFailure(t *testing.T) {
+	data := marshalPathTestMessage()
+
+	f := func(src []byte, path []uint32) {
+		t.Helper()
+
+		err := RangeByPath(src, path, func(_ *FieldContext) bool {
+			return true
+		})
+		if err == nil {
+			t.Fatalf("expecting non-nil error for path=%v", path)
+		}
+	}
+
+	// empty path
+	f(data, nil)
+
+	// non-message field in the middle of the path
+	f(data, []uint32{1, 4, 1})
+
+	// invalid message
+	f([]byte{0xff}, []uint32{1})
+	f([]byte{1<<3 | byte(WireTypeLen), 0x01, 0xff}, []uint32{1, 2})
+}
+
+func TestGetByPath(t *testing.T) {
+	data := marshalPathTestMessage()
+
+	s, ok, err := GetStringByPath(data, 1, 3, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !ok {
+		t.Fatalf("cannot find string")
+	}
+	if s != "foo_value" {
+		t.Fatalf("unexpected string; got %q; want %q", s, "foo_value")
+	}
+
+	n, ok, err := GetInt64ByPath(data, 1, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !ok {
+		t.Fatalf("cannot find int64")
+	}
+	if n != 10 {
+		t.Fatalf("unexpected int64; got %d; want 10", n)
+	}
+
+	msgData, ok, err := GetMessageDataByPath(data, 1, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !ok {
+		t.Fatalf("cannot find message")
+	}
+	s, ok, err = GetString(msgData, 1)
+	if err != nil || !ok {
+		t.Fatalf("cannot read string from message; ok=%v, err=%v", ok, err)
+	}
+	if s != "foo" {
+		t.Fatalf("unexpected string; got %q; want %q", s, "foo")
+	}
+
+	// missing field
+	_, ok, err = GetStringByPath(data, 1, 3, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ok {
+		t.Fatalf("unexpected field found")
+	}
+
+	// wrong wire type
+	_, _, err = GetStringByPath(data, 1, 4)
+	var wte *WireTypeError
+	if !errors.As(err, &wte) {
+		t.Fatalf("expecting WireTypeError; got %v", err)
+	}
+	if wte.FieldNum != 4 || wte.Got != WireTypeVarint || wte.Want != WireTypeLen {
+		t.Fatalf("unexpected WireTypeError: %s", wte)
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expecting *DecodeError; got %T", err)
+	}
+	if de.Kind != ErrBadWireType || de.FieldNum != 4 {
+		t.Fatalf("unexpected DecodeError: %s", de)
+	}
+	if tag := data[de.Offset]; tag != 4<<3|byte(WireTypeVarint) {
+		t.Fatalf("unexpected field tag at offset %d; got 0x%02X; want 0x%02X", de.Offset, tag, 4<<3|byte(WireTypeVarint))
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		_, _, _ = GetStringByPath(data, 1, 3, 2)
+	})
+	if allocs != 0 {
+		t.Fatalf("unexpected memory allocations in GetStringByPath; got %v; want 0", allocs)
+	}
+}
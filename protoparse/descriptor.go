@@ -0,0 +1,442 @@
+package protoparse
+
+import (
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// File describes parsed .proto file.
+type File struct {
+	// Name is the file path as it was passed to Parser.ParseFiles() or as it is written in import statement.
+	Name string
+
+	// Syntax is the file syntax. It is either "proto2", "proto3" or "editions".
+	//
+	// It is set to "proto2" if syntax isn't specified in the file.
+	Syntax string
+
+	// Edition is the edition for the file with Syntax="editions", e.g. "2023".
+	Edition string
+
+	// Package is the package name for the file.
+	Package string
+
+	// Imports contains import statements for the file.
+	Imports []*Import
+
+	// Options contains file-level options.
+	Options []*Option
+
+	// Messages contains top-level messages in the file.
+	Messages []*Message
+
+	// Enums contains top-level enums in the file.
+	Enums []*Enum
+
+	// Services contains services in the file.
+	Services []*Service
+
+	// Extensions contains top-level extension fields declared in extend blocks.
+	Extensions []*Field
+}
+
+// Import describes import statement.
+type Import struct {
+	// Path is the imported file path.
+	Path string
+
+	// Public is set to true for public import.
+	Public bool
+
+	// Weak is set to true for weak import.
+	Weak bool
+
+	// File is the imported file.
+	File *File
+}
+
+// Option describes an option.
+type Option struct {
+	// Name is the option name, e.g. "java_package" or "(my.custom).field".
+	Name string
+
+	// Value is the option value.
+	//
+	// It contains decoded string for string values, the identifier for enum and bool values,
+	// the number text for numeric values and the text inside braces for aggregate values.
+	Value string
+}
+
+// Message describes protobuf message.
+type Message struct {
+	// Name is the message name.
+	Name string
+
+	// FullName is the fully-qualified message name including the package and parent messages.
+	FullName string
+
+	// Parent is the parent message for nested messages. It is nil for top-level messages.
+	Parent *Message
+
+	// File is the file, which contains the message.
+	File *File
+
+	// Fields contains message fields in the order of their declaration, including fields inside oneofs.
+	Fields []*Field
+
+	// Oneofs contains message oneofs.
+	Oneofs []*Oneof
+
+	// Messages contains nested messages, including synthetic map entry messages and group messages.
+	Messages []*Message
+
+	// Enums contains nested enums.
+	Enums []*Enum
+
+	// Options contains message options.
+	Options []*Option
+
+	// ReservedRanges contains reserved field number ranges.
+	ReservedRanges []Range
+
+	// ReservedNames contains reserved field names.
+	ReservedNames []string
+
+	// ExtensionRanges contains field number ranges for extensions.
+	ExtensionRanges []Range
+
+	// Extensions contains extension fields declared in extend blocks inside the message.
+	Extensions []*Field
+
+	// IsMapEntry is set to true for synthetic map entry messages.
+	IsMapEntry bool
+}
+
+// FieldByNum returns the field with the given num from m.
+//
+// nil is returned if m doesn't contain such a field.
+func (m *Message) FieldByNum(num uint32) *Field {
+	for _, f := range m.Fields {
+		if f.Num == num {
+			return f
+		}
+	}
+	return nil
+}
+
+// FieldByName returns the field with the given name from m.
+//
+// nil is returned if m doesn't contain such a field.
+func (m *Message) FieldByName(name string) *Field {
+	for _, f := range m.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Range is an inclusive range of field numbers.
+type Range struct {
+	// Start is the first number in the range.
+	Start uint32
+
+	// End is the last number in the range.
+	End uint32
+}
+
+// Label is the field label.
+type Label int
+
+// The list of supported field labels.
+const (
+	// LabelNone is the label for fields without explicit label.
+	LabelNone Label = iota
+
+	// LabelOptional is the label for optional fields.
+	LabelOptional
+
+	// LabelRequired is the label for proto2 required fields.
+	LabelRequired
+
+	// LabelRepeated is the label for repeated fields.
+	LabelRepeated
+)
+
+// String returns the label as it is written in .proto file.
+func (l Label) String() string {
+	switch l {
+	case LabelOptional:
+		return "optional"
+	case LabelRequired:
+		return "required"
+	case LabelRepeated:
+		return "repeated"
+	default:
+		return ""
+	}
+}
+
+// Field describes message field.
+type Field struct {
+	// Name is the field name.
+	Name string
+
+	// Num is the field number.
+	Num uint32
+
+	// Label is the field label.
+	Label Label
+
+	// TypeName is the field type as it is written in .proto file, e.g. "int32" or "foo.Bar".
+	//
+	// It is empty for map fields.
+	TypeName string
+
+	// Kind is the resolved field kind.
+	Kind easyproto.Kind
+
+	// Message is the resolved message type for fields with KindMessage and KindGroup kinds.
+	//
+	// It points to the synthetic map entry message for map fields.
+	Message *Message
+
+	// Enum is the resolved enum type for fields with KindEnum kind.
+	Enum *Enum
+
+	// Map contains key and value fields for map fields. It is nil for other fields.
+	Map *MapType
+
+	// Oneof is the oneof, which contains the field. It is nil for fields outside oneofs.
+	Oneof *Oneof
+
+	// Options contains field options.
+	Options []*Option
+
+	// Extendee is the extended message name for extension fields declared in extend blocks.
+	Extendee string
+
+	// Parent is the message, which contains the field. It is nil for top-level extension fields.
+	Parent *Message
+
+	// File is the file, which contains the field.
+	File *File
+}
+
+// IsRepeated returns true if f is repeated field. Map fields are repeated.
+func (f *Field) IsRepeated() bool {
+	return f.Label == LabelRepeated
+}
+
+// IsPacked returns true if f must be marshaled as packed repeated field.
+//
+// Repeated scalar fields are packed by default in proto3 and editions, while they must be packed explicitly in proto2.
+// The default can be overridden with packed option.
+func (f *Field) IsPacked() bool {
+	if !f.IsRepeated() || !f.Kind.IsPackable() {
+		return false
+	}
+	if v, ok := f.Option("packed"); ok {
+		return v == "true"
+	}
+	if v, ok := f.Feature("repeated_field_encoding"); ok {
+		return v == "PACKED"
+	}
+	return f.File == nil || f.File.Syntax != "proto2"
+}
+
+// HasPresence returns true if f tracks the presence of its value, e.g. if the unset field can be distinguished from the field with the default value.
+//
+// Singular fields have presence in proto2, while only optional and message fields have presence in proto3.
+// Fields have presence in editions unless field_presence feature is set to IMPLICIT.
+// Repeated fields have no presence, while fields in oneofs always have presence.
+func (f *Field) HasPresence() bool {
+	if f.IsRepeated() {
+		return false
+	}
+	if f.Oneof != nil || f.Kind == easyproto.KindMessage || f.Kind == easyproto.KindGroup {
+		return true
+	}
+	if f.File == nil {
+		return f.Label == LabelOptional
+	}
+	switch f.File.Syntax {
+	case "proto2":
+		return true
+	case "proto3":
+		return f.Label == LabelOptional
+	default:
+		v, ok := f.Feature("field_presence")
+		return !ok || v != "IMPLICIT"
+	}
+}
+
+// Feature returns the value for editions feature with the given name at f, e.g. Feature("field_presence").
+//
+// The feature is inherited from the enclosing oneof, messages and the file if it isn't set at f.
+// False is returned if the feature isn't set at any of these levels.
+func (f *Field) Feature(name string) (string, bool) {
+	name = "features." + name
+	if v, ok := f.Option(name); ok {
+		return v, true
+	}
+	if f.Oneof != nil {
+		if v, ok := findOption(f.Oneof.Options, name); ok {
+			return v, true
+		}
+	}
+	for m := f.Parent; m != nil; m = m.Parent {
+		if v, ok := findOption(m.Options, name); ok {
+			return v, true
+		}
+	}
+	if f.File != nil {
+		return findOption(f.File.Options, name)
+	}
+	return "", false
+}
+
+// Option returns the value for the option with the given name at f.
+//
+// False is returned if f has no such option.
+func (f *Field) Option(name string) (string, bool) {
+	return findOption(f.Options, name)
+}
+
+// DefaultValue returns the default value for proto2 field f as it is written in default option.
+//
+// False is returned if f has no explicit default value.
+func (f *Field) DefaultValue() (string, bool) {
+	return f.Option("default")
+}
+
+func findOption(options []*Option, name string) (string, bool) {
+	for _, o := range options {
+		if o.Name == name {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
+// MapType describes key and value for map fields.
+type MapType struct {
+	// Key is the key field for the map entry.
+	Key *Field
+
+	// Value is the value field for the map entry.
+	Value *Field
+}
+
+// Oneof describes oneof.
+type Oneof struct {
+	// Name is the oneof name.
+	Name string
+
+	// Fields contains oneof fields.
+	Fields []*Field
+
+	// Options contains oneof options.
+	Options []*Option
+}
+
+// Enum describes protobuf enum.
+type Enum struct {
+	// Name is the enum name.
+	Name string
+
+	// FullName is the fully-qualified enum name including the package and parent messages.
+	FullName string
+
+	// Parent is the parent message for nested enums. It is nil for top-level enums.
+	Parent *Message
+
+	// File is the file, which contains the enum.
+	File *File
+
+	// Values contains enum values in the order of their declaration.
+	Values []*EnumValue
+
+	// Options contains enum options.
+	Options []*Option
+
+	// ReservedRanges contains reserved value ranges.
+	ReservedRanges []EnumRange
+
+	// ReservedNames contains reserved value names.
+	ReservedNames []string
+}
+
+// ValueByNumber returns the first enum value with the given number.
+//
+// nil is returned if e doesn't contain such a value.
+func (e *Enum) ValueByNumber(n int32) *EnumValue {
+	for _, v := range e.Values {
+		if v.Number == n {
+			return v
+		}
+	}
+	return nil
+}
+
+// EnumRange is an inclusive range of enum values.
+type EnumRange struct {
+	// Start is the first value in the range.
+	Start int32
+
+	// End is the last value in the range.
+	End int32
+}
+
+// EnumValue describes enum value.
+type EnumValue struct {
+	// Name is the enum value name.
+	Name string
+
+	// Number is the enum value number.
+	Number int32
+
+	// Options contains enum value options.
+	Options []*Option
+}
+
+// Service describes gRPC service.
+type Service struct {
+	// Name is the service name.
+	Name string
+
+	// FullName is the fully-qualified service name including the package.
+	FullName string
+
+	// Methods contains service methods.
+	Methods []*Method
+
+	// Options contains service options.
+	Options []*Option
+}
+
+// Method describes gRPC service method.
+type Method struct {
+	// Name is the method name.
+	Name string
+
+	// InputTypeName is the input type name as it is written in .proto file.
+	InputTypeName string
+
+	// InputType is the resolved input type.
+	InputType *Message
+
+	// ClientStreaming is set to true for client streaming methods.
+	ClientStreaming bool
+
+	// OutputTypeName is the output type name as it is written in .proto file.
+	OutputTypeName string
+
+	// OutputType is the resolved output type.
+	OutputType *Message
+
+	// ServerStreaming is set to true for server streaming methods.
+	ServerStreaming bool
+
+	// Options contains method options.
+	Options []*Option
+}
@@ -0,0 +1,316 @@
+package protoparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenSymbol
+)
+
+func (tk tokenKind) String() string {
+	switch tk {
+	case tokenEOF:
+		return "end of file"
+	case tokenIdent:
+		return "identifier"
+	case tokenInt:
+		return "integer"
+	case tokenFloat:
+		return "float"
+	case tokenString:
+		return "string"
+	case tokenSymbol:
+		return "symbol"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(tk))
+	}
+}
+
+// token is a single lexical token in .proto file.
+type token struct {
+	// kind is the token kind.
+	kind tokenKind
+
+	// text is the token text as it is written in .proto file.
+	text string
+
+	// value is the decoded value for tokenString.
+	value string
+
+	// line is the line number for the token start. It starts from 1.
+	line int
+
+	// col is the column number for the token start. It starts from 1.
+	col int
+}
+
+func (t *token) String() string {
+	if t.kind == tokenEOF {
+		return t.kind.String()
+	}
+	return fmt.Sprintf("%s %q", t.kind, t.text)
+}
+
+// lexer splits .proto file contents into tokens.
+type lexer struct {
+	// src is the remaining .proto file contents.
+	src string
+
+	// line is the current line number.
+	line int
+
+	// col is the current column number.
+	col int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{
+		src:  src,
+		line: 1,
+		col:  1,
+	}
+}
+
+// advance skips n bytes at lx.src and updates the current position.
+func (lx *lexer) advance(n int) {
+	for _, c := range lx.src[:n] {
+		if c == '\n' {
+			lx.line++
+			lx.col = 1
+		} else {
+			lx.col++
+		}
+	}
+	lx.src = lx.src[n:]
+}
+
+// skipSpaceAndComments skips whitespace and comments at lx.src.
+func (lx *lexer) skipSpaceAndComments() error {
+	for len(lx.src) > 0 {
+		c := lx.src[0]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+			lx.advance(1)
+		case strings.HasPrefix(lx.src, "//"):
+			n := strings.IndexByte(lx.src, '\n')
+			if n < 0 {
+				n = len(lx.src)
+			}
+			lx.advance(n)
+		case strings.HasPrefix(lx.src, "/*"):
+			n := strings.Index(lx.src[2:], "*/")
+			if n < 0 {
+				return lx.errorf("missing */ for the comment")
+			}
+			lx.advance(n + 4)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (lx *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%d:%d: %s", lx.line, lx.col, fmt.Sprintf(format, args...))
+}
+
+// next returns the next token from lx.
+func (lx *lexer) next() (token, error) {
+	if err := lx.skipSpaceAndComments(); err != nil {
+		return token{}, err
+	}
+	t := token{
+		line: lx.line,
+		col:  lx.col,
+	}
+	if len(lx.src) == 0 {
+		t.kind = tokenEOF
+		return t, nil
+	}
+
+	c := lx.src[0]
+	switch {
+	case isIdentStart(c):
+		n := 1
+		for n < len(lx.src) && isIdentChar(lx.src[n]) {
+			n++
+		}
+		t.kind = tokenIdent
+		t.text = lx.src[:n]
+	case isDigit(c) || (c == '.' && len(lx.src) > 1 && isDigit(lx.src[1])):
+		n, isFloat := scanNumber(lx.src)
+		t.kind = tokenInt
+		if isFloat {
+			t.kind = tokenFloat
+		}
+		t.text = lx.src[:n]
+		if n < len(lx.src) && isIdentStart(lx.src[n]) {
+			return t, lx.errorf("invalid number %q", lx.src[:n+1])
+		}
+	case c == '"' || c == '\'':
+		n, value, err := scanString(lx.src)
+		if err != nil {
+			return t, lx.errorf("%s", err)
+		}
+		t.kind = tokenString
+		t.text = lx.src[:n]
+		t.value = value
+	default:
+		t.kind = tokenSymbol
+		t.text = lx.src[:1]
+	}
+	lx.advance(len(t.text))
+	return t, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// scanNumber returns the length of the number at the start of s and whether it is floating-point number.
+func scanNumber(s string) (int, bool) {
+	if len(s) > 1 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		n := 2
+		for n < len(s) && isHexDigit(s[n]) {
+			n++
+		}
+		return n, false
+	}
+	n := 0
+	isFloat := false
+	for n < len(s) {
+		c := s[n]
+		switch {
+		case isDigit(c):
+			n++
+		case c == '.':
+			isFloat = true
+			n++
+		case c == 'e' || c == 'E':
+			isFloat = true
+			n++
+			if n < len(s) && (s[n] == '+' || s[n] == '-') {
+				n++
+			}
+		default:
+			return n, isFloat
+		}
+	}
+	return n, isFloat
+}
+
+// scanString returns the length of the quoted string at the start of s together with the decoded string value.
+func scanString(s string) (int, string, error) {
+	quote := s[0]
+	var sb strings.Builder
+	n := 1
+	for {
+		if n >= len(s) {
+			return 0, "", fmt.Errorf("missing closing quote for the string")
+		}
+		c := s[n]
+		switch c {
+		case quote:
+			return n + 1, sb.String(), nil
+		case '\n':
+			return 0, "", fmt.Errorf("unexpected newline in the string")
+		case '\\':
+			size, err := decodeEscape(&sb, s[n:])
+			if err != nil {
+				return 0, "", err
+			}
+			n += size
+		default:
+			sb.WriteByte(c)
+			n++
+		}
+	}
+}
+
+// decodeEscape decodes the escape sequence at the start of s into sb and returns its length.
+func decodeEscape(sb *strings.Builder, s string) (int, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("incomplete escape sequence")
+	}
+	switch c := s[1]; c {
+	case 'a':
+		sb.WriteByte('\a')
+	case 'b':
+		sb.WriteByte('\b')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'r':
+		sb.WriteByte('\r')
+	case 't':
+		sb.WriteByte('\t')
+	case 'v':
+		sb.WriteByte('\v')
+	case '\\', '\'', '"', '?':
+		sb.WriteByte(c)
+	case 'x', 'X':
+		n := 2
+		for n < len(s) && n < 4 && isHexDigit(s[n]) {
+			n++
+		}
+		if n == 2 {
+			return 0, fmt.Errorf("invalid hex escape sequence %q", s[:n])
+		}
+		v, _ := strconv.ParseUint(s[2:n], 16, 8)
+		sb.WriteByte(byte(v))
+		return n, nil
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if len(s) < 2+size {
+			return 0, fmt.Errorf("incomplete unicode escape sequence %q", s)
+		}
+		v, err := strconv.ParseUint(s[2:2+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(v)) {
+			return 0, fmt.Errorf("invalid unicode escape sequence %q", s[:2+size])
+		}
+		sb.WriteRune(rune(v))
+		return 2 + size, nil
+	default:
+		if c < '0' || c > '7' {
+			return 0, fmt.Errorf("invalid escape sequence %q", s[:2])
+		}
+		n := 1
+		for n < len(s) && n < 4 && s[n] >= '0' && s[n] <= '7' {
+			n++
+		}
+		v, err := strconv.ParseUint(s[1:n], 8, 8)
+		if err != nil {
+			return 0, fmt.Errorf("invalid octal escape sequence %q", s[:n])
+		}
+		sb.WriteByte(byte(v))
+		return n, nil
+	}
+	return 2, nil
+}
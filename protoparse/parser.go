@@ -0,0 +1,1233 @@
+package protoparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// maxFieldNum is the maximum field number allowed by protobuf spec.
+const maxFieldNum = 1<<29 - 1
+
+// The range of field numbers reserved for protobuf implementation.
+const (
+	reservedFieldNumStart = 19000
+	reservedFieldNumEnd   = 19999
+)
+
+// parser parses a single .proto file.
+type parser struct {
+	// name is the name of the parsed file. It is used in error messages.
+	name string
+
+	// lx is the lexer for the parsed file.
+	lx *lexer
+
+	// tok is the current token.
+	tok token
+
+	// file is the parsed file.
+	file *File
+}
+
+// parseFile parses .proto file contents at src.
+//
+// The returned file has unresolved types. They must be resolved with resolveFiles().
+func parseFile(name, src string) (*File, error) {
+	p := &parser{
+		name: name,
+		lx:   newLexer(src),
+		file: &File{
+			Name:   name,
+			Syntax: "proto2",
+		},
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.parseFile(); err != nil {
+		return nil, err
+	}
+	return p.file, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d:%d: %s", p.name, p.tok.line, p.tok.col, fmt.Sprintf(format, args...))
+}
+
+// next advances p to the next token.
+func (p *parser) next() error {
+	tok, err := p.lx.next()
+	if err != nil {
+		return fmt.Errorf("%s:%w", p.name, err)
+	}
+	p.tok = tok
+	return nil
+}
+
+// isSymbol returns true if the current token is the given symbol.
+func (p *parser) isSymbol(s string) bool {
+	return p.tok.kind == tokenSymbol && p.tok.text == s
+}
+
+// isIdent returns true if the current token is the given identifier.
+func (p *parser) isIdent(s string) bool {
+	return p.tok.kind == tokenIdent && p.tok.text == s
+}
+
+// expectSymbol verifies that the current token is the given symbol and advances to the next token.
+func (p *parser) expectSymbol(s string) error {
+	if !p.isSymbol(s) {
+		return p.errorf("expecting %q; got %s", s, &p.tok)
+	}
+	return p.next()
+}
+
+// expectIdent verifies that the current token is the given identifier and advances to the next token.
+func (p *parser) expectIdent(s string) error {
+	if !p.isIdent(s) {
+		return p.errorf("expecting %q; got %s", s, &p.tok)
+	}
+	return p.next()
+}
+
+// parseIdent returns the current identifier and advances to the next token.
+func (p *parser) parseIdent() (string, error) {
+	if p.tok.kind != tokenIdent {
+		return "", p.errorf("expecting identifier; got %s", &p.tok)
+	}
+	s := p.tok.text
+	return s, p.next()
+}
+
+// parseFullIdent parses dot-separated identifier.
+func (p *parser) parseFullIdent() (string, error) {
+	s, err := p.parseIdent()
+	if err != nil {
+		return "", err
+	}
+	for p.isSymbol(".") {
+		if err := p.next(); err != nil {
+			return "", err
+		}
+		ident, err := p.parseIdent()
+		if err != nil {
+			return "", err
+		}
+		s += "." + ident
+	}
+	return s, nil
+}
+
+// parseTypeName parses type name, which may start with dot for fully-qualified names.
+func (p *parser) parseTypeName() (string, error) {
+	prefix := ""
+	if p.isSymbol(".") {
+		prefix = "."
+		if err := p.next(); err != nil {
+			return "", err
+		}
+	}
+	s, err := p.parseFullIdent()
+	if err != nil {
+		return "", err
+	}
+	return prefix + s, nil
+}
+
+// parseString parses string literal, which may consist of multiple adjacent string literals.
+func (p *parser) parseString() (string, error) {
+	if p.tok.kind != tokenString {
+		return "", p.errorf("expecting string; got %s", &p.tok)
+	}
+	var sb strings.Builder
+	for p.tok.kind == tokenString {
+		sb.WriteString(p.tok.value)
+		if err := p.next(); err != nil {
+			return "", err
+		}
+	}
+	return sb.String(), nil
+}
+
+// parseInt parses optionally signed integer in the range [minValue..maxValue].
+func (p *parser) parseInt(minValue, maxValue int64) (int64, error) {
+	sign := ""
+	if p.isSymbol("-") {
+		sign = "-"
+		if err := p.next(); err != nil {
+			return 0, err
+		}
+	}
+	if p.tok.kind != tokenInt {
+		return 0, p.errorf("expecting integer; got %s", &p.tok)
+	}
+	n, err := strconv.ParseInt(sign+p.tok.text, 0, 64)
+	if err != nil {
+		return 0, p.errorf("cannot parse integer %s%s: %s", sign, p.tok.text, err)
+	}
+	if n < minValue || n > maxValue {
+		return 0, p.errorf("integer %d is out of range [%d..%d]", n, minValue, maxValue)
+	}
+	return n, p.next()
+}
+
+// parseFieldNum parses field number.
+func (p *parser) parseFieldNum() (uint32, error) {
+	n, err := p.parseInt(1, maxFieldNum)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(n), nil
+}
+
+// parseEnd parses the end of a statement.
+func (p *parser) parseEnd() error {
+	return p.expectSymbol(";")
+}
+
+func (p *parser) parseFile() error {
+	if p.isIdent("syntax") || p.isIdent("edition") {
+		if err := p.parseSyntax(); err != nil {
+			return err
+		}
+	}
+	for p.tok.kind != tokenEOF {
+		if err := p.parseTopLevelStatement(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseSyntax() error {
+	isEdition := p.isIdent("edition")
+	if err := p.next(); err != nil {
+		return err
+	}
+	if err := p.expectSymbol("="); err != nil {
+		return err
+	}
+	s, err := p.parseString()
+	if err != nil {
+		return err
+	}
+	if isEdition {
+		p.file.Syntax = "editions"
+		p.file.Edition = s
+	} else {
+		if s != "proto2" && s != "proto3" {
+			return p.errorf("unsupported syntax %q; supported values: proto2, proto3", s)
+		}
+		p.file.Syntax = s
+	}
+	return p.parseEnd()
+}
+
+func (p *parser) parseTopLevelStatement() error {
+	if p.isSymbol(";") {
+		return p.next()
+	}
+	if p.tok.kind != tokenIdent {
+		return p.errorf("unexpected %s", &p.tok)
+	}
+	f := p.file
+	switch p.tok.text {
+	case "import":
+		imp, err := p.parseImport()
+		if err != nil {
+			return err
+		}
+		f.Imports = append(f.Imports, imp)
+		return nil
+	case "package":
+		if f.Package != "" {
+			return p.errorf("duplicate package statement")
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+		pkg, err := p.parseFullIdent()
+		if err != nil {
+			return err
+		}
+		f.Package = pkg
+		return p.parseEnd()
+	case "option":
+		o, err := p.parseOptionStatement()
+		if err != nil {
+			return err
+		}
+		f.Options = append(f.Options, o)
+		return nil
+	case "message":
+		m, err := p.parseMessage(nil)
+		if err != nil {
+			return err
+		}
+		f.Messages = append(f.Messages, m)
+		return nil
+	case "enum":
+		e, err := p.parseEnum(nil)
+		if err != nil {
+			return err
+		}
+		f.Enums = append(f.Enums, e)
+		return nil
+	case "service":
+		s, err := p.parseService()
+		if err != nil {
+			return err
+		}
+		f.Services = append(f.Services, s)
+		return nil
+	case "extend":
+		fields, msgs, err := p.parseExtend(nil)
+		if err != nil {
+			return err
+		}
+		f.Extensions = append(f.Extensions, fields...)
+		f.Messages = append(f.Messages, msgs...)
+		return nil
+	default:
+		return p.errorf("unexpected %s", &p.tok)
+	}
+}
+
+func (p *parser) parseImport() (*Import, error) {
+	if err := p.expectIdent("import"); err != nil {
+		return nil, err
+	}
+	imp := &Import{}
+	if p.isIdent("public") {
+		imp.Public = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	} else if p.isIdent("weak") {
+		imp.Weak = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	path, err := p.parseString()
+	if err != nil {
+		return nil, err
+	}
+	imp.Path = path
+	return imp, p.parseEnd()
+}
+
+// parseOptionStatement parses `option name = value;` statement.
+func (p *parser) parseOptionStatement() (*Option, error) {
+	if err := p.expectIdent("option"); err != nil {
+		return nil, err
+	}
+	o, err := p.parseOption()
+	if err != nil {
+		return nil, err
+	}
+	return o, p.parseEnd()
+}
+
+// parseOption parses `name = value`.
+func (p *parser) parseOption() (*Option, error) {
+	name, err := p.parseOptionName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("="); err != nil {
+		return nil, err
+	}
+	value, err := p.parseConstant()
+	if err != nil {
+		return nil, err
+	}
+	return &Option{
+		Name:  name,
+		Value: value,
+	}, nil
+}
+
+func (p *parser) parseOptionName() (string, error) {
+	var sb strings.Builder
+	for {
+		if p.isSymbol("(") {
+			if err := p.next(); err != nil {
+				return "", err
+			}
+			name, err := p.parseTypeName()
+			if err != nil {
+				return "", err
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return "", err
+			}
+			sb.WriteString("(" + name + ")")
+		} else {
+			name, err := p.parseIdent()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(name)
+		}
+		if !p.isSymbol(".") {
+			return sb.String(), nil
+		}
+		sb.WriteString(".")
+		if err := p.next(); err != nil {
+			return "", err
+		}
+	}
+}
+
+// parseOptions parses optional `[name = value, ...]` list.
+func (p *parser) parseOptions() ([]*Option, error) {
+	if !p.isSymbol("[") {
+		return nil, nil
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	var options []*Option
+	for {
+		o, err := p.parseOption()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, o)
+		if p.isSymbol("]") {
+			return options, p.next()
+		}
+		if err := p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseConstant parses option value.
+func (p *parser) parseConstant() (string, error) {
+	switch p.tok.kind {
+	case tokenString:
+		return p.parseString()
+	case tokenInt, tokenFloat:
+		s := p.tok.text
+		return s, p.next()
+	case tokenIdent:
+		return p.parseFullIdent()
+	}
+	if p.isSymbol("-") || p.isSymbol("+") {
+		sign := p.tok.text
+		if err := p.next(); err != nil {
+			return "", err
+		}
+		if p.tok.kind != tokenInt && p.tok.kind != tokenFloat && !p.isIdent("inf") && !p.isIdent("nan") {
+			return "", p.errorf("expecting number after %q; got %s", sign, &p.tok)
+		}
+		s := p.tok.text
+		if sign == "-" {
+			s = sign + s
+		}
+		return s, p.next()
+	}
+	if p.isSymbol("{") {
+		return p.parseAggregate()
+	}
+	return "", p.errorf("expecting constant; got %s", &p.tok)
+}
+
+// parseAggregate parses aggregate value in text format and returns it without the outer braces.
+func (p *parser) parseAggregate() (string, error) {
+	if err := p.expectSymbol("{"); err != nil {
+		return "", err
+	}
+	var parts []string
+	depth := 0
+	for {
+		switch {
+		case p.tok.kind == tokenEOF:
+			return "", p.errorf("missing closing brace for aggregate value")
+		case p.isSymbol("{"):
+			depth++
+		case p.isSymbol("}"):
+			if depth == 0 {
+				return strings.Join(parts, " "), p.next()
+			}
+			depth--
+		}
+		parts = append(parts, p.tok.text)
+		if err := p.next(); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (p *parser) parseMessage(parent *Message) (*Message, error) {
+	if err := p.expectIdent("message"); err != nil {
+		return nil, err
+	}
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{
+		Name:   name,
+		Parent: parent,
+		File:   p.file,
+	}
+	if err := p.parseMessageBody(m); err != nil {
+		return nil, err
+	}
+	if err := p.checkMessage(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (p *parser) parseMessageBody(m *Message) error {
+	if err := p.expectSymbol("{"); err != nil {
+		return err
+	}
+	for !p.isSymbol("}") {
+		if err := p.parseMessageStatement(m); err != nil {
+			return err
+		}
+	}
+	return p.next()
+}
+
+func (p *parser) parseMessageStatement(m *Message) error {
+	if p.isSymbol(";") {
+		return p.next()
+	}
+	if p.tok.kind != tokenIdent && !p.isSymbol(".") {
+		return p.errorf("unexpected %s in message %s", &p.tok, m.Name)
+	}
+	switch p.tok.text {
+	case "message":
+		nested, err := p.parseMessage(m)
+		if err != nil {
+			return err
+		}
+		m.Messages = append(m.Messages, nested)
+		return nil
+	case "enum":
+		e, err := p.parseEnum(m)
+		if err != nil {
+			return err
+		}
+		m.Enums = append(m.Enums, e)
+		return nil
+	case "option":
+		o, err := p.parseOptionStatement()
+		if err != nil {
+			return err
+		}
+		m.Options = append(m.Options, o)
+		return nil
+	case "oneof":
+		return p.parseOneof(m)
+	case "reserved":
+		return p.parseReserved(&m.ReservedRanges, nil, &m.ReservedNames)
+	case "extensions":
+		return p.parseExtensions(m)
+	case "extend":
+		fields, msgs, err := p.parseExtend(m)
+		if err != nil {
+			return err
+		}
+		m.Extensions = append(m.Extensions, fields...)
+		m.Messages = append(m.Messages, msgs...)
+		return nil
+	case "map":
+		f, entry, err := p.parseMapField(m)
+		if err != nil {
+			return err
+		}
+		m.Fields = append(m.Fields, f)
+		m.Messages = append(m.Messages, entry)
+		return nil
+	default:
+		f, group, err := p.parseField(m, nil, "")
+		if err != nil {
+			return err
+		}
+		m.Fields = append(m.Fields, f)
+		if group != nil {
+			m.Messages = append(m.Messages, group)
+		}
+		return nil
+	}
+}
+
+// parseField parses a single field declaration inside the message m.
+//
+// oneof is the oneof containing the field. extendee is the extended message for extension fields.
+// The message for group fields is returned in the second result.
+func (p *parser) parseField(m *Message, oneof *Oneof, extendee string) (*Field, *Message, error) {
+	f := &Field{
+		Oneof:    oneof,
+		Extendee: extendee,
+		Parent:   m,
+		File:     p.file,
+	}
+	if oneof == nil {
+		switch {
+		case p.isIdent("optional"):
+			f.Label = LabelOptional
+		case p.isIdent("required"):
+			f.Label = LabelRequired
+		case p.isIdent("repeated"):
+			f.Label = LabelRepeated
+		}
+		if f.Label != LabelNone {
+			if err := p.next(); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if err := p.checkLabel(f); err != nil {
+		return nil, nil, err
+	}
+
+	if p.isIdent("group") {
+		return p.parseGroup(f)
+	}
+
+	typeName, err := p.parseTypeName()
+	if err != nil {
+		return nil, nil, err
+	}
+	f.TypeName = typeName
+	if err := p.parseFieldRest(f); err != nil {
+		return nil, nil, err
+	}
+	return f, nil, p.parseEnd()
+}
+
+// parseFieldRest parses `name = num [options]` part of the field declaration.
+func (p *parser) parseFieldRest(f *Field) error {
+	name, err := p.parseIdent()
+	if err != nil {
+		return err
+	}
+	f.Name = name
+	if err := p.expectSymbol("="); err != nil {
+		return err
+	}
+	num, err := p.parseFieldNum()
+	if err != nil {
+		return err
+	}
+	if num >= reservedFieldNumStart && num <= reservedFieldNumEnd {
+		return p.errorf("field number %d for the field %s is reserved for protobuf implementation", num, f.Name)
+	}
+	f.Num = num
+	options, err := p.parseOptions()
+	if err != nil {
+		return err
+	}
+	f.Options = options
+	if _, ok := f.DefaultValue(); ok && p.file.Syntax == "proto3" {
+		return p.errorf("explicit default values aren't allowed in proto3 for the field %s", f.Name)
+	}
+	return nil
+}
+
+func (p *parser) checkLabel(f *Field) error {
+	switch p.file.Syntax {
+	case "proto2":
+		if f.Label == LabelNone && f.Oneof == nil && !p.isIdent("map") {
+			return p.errorf("expecting field label: optional, required or repeated; got %s", &p.tok)
+		}
+	case "proto3":
+		if f.Label == LabelRequired {
+			return p.errorf("required fields aren't allowed in proto3")
+		}
+	case "editions":
+		if f.Label == LabelRequired || f.Label == LabelOptional {
+			return p.errorf("%s label isn't allowed in editions; use features.field_presence option instead", f.Label)
+		}
+	}
+	return nil
+}
+
+// parseGroup parses proto2 group field.
+func (p *parser) parseGroup(f *Field) (*Field, *Message, error) {
+	if p.file.Syntax != "proto2" {
+		return nil, nil, p.errorf("groups are allowed only in proto2")
+	}
+	if err := p.expectIdent("group"); err != nil {
+		return nil, nil, err
+	}
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !unicode.IsUpper(rune(name[0])) {
+		return nil, nil, p.errorf("group name %s must start with capital letter", name)
+	}
+	f.TypeName = name
+	f.Kind = easyproto.KindGroup
+	if err := p.expectSymbol("="); err != nil {
+		return nil, nil, err
+	}
+	num, err := p.parseFieldNum()
+	if err != nil {
+		return nil, nil, err
+	}
+	f.Num = num
+	f.Name = strings.ToLower(name)
+	options, err := p.parseOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	f.Options = options
+
+	group := &Message{
+		Name:   name,
+		Parent: f.Parent,
+		File:   p.file,
+	}
+	if err := p.parseMessageBody(group); err != nil {
+		return nil, nil, err
+	}
+	if err := p.checkMessage(group); err != nil {
+		return nil, nil, err
+	}
+	f.Message = group
+	return f, group, nil
+}
+
+// parseMapField parses map field and returns it together with the synthetic map entry message.
+func (p *parser) parseMapField(m *Message) (*Field, *Message, error) {
+	if err := p.expectIdent("map"); err != nil {
+		return nil, nil, err
+	}
+	if err := p.expectSymbol("<"); err != nil {
+		return nil, nil, err
+	}
+	keyType, err := p.parseTypeName()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isValidMapKeyType(keyType) {
+		return nil, nil, p.errorf("invalid map key type %s; it must be integer, bool or string", keyType)
+	}
+	if err := p.expectSymbol(","); err != nil {
+		return nil, nil, err
+	}
+	valueType, err := p.parseTypeName()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.expectSymbol(">"); err != nil {
+		return nil, nil, err
+	}
+
+	f := &Field{
+		Label:  LabelRepeated,
+		Parent: m,
+		File:   p.file,
+	}
+	if err := p.parseFieldRest(f); err != nil {
+		return nil, nil, err
+	}
+
+	entry := &Message{
+		Name:       mapEntryName(f.Name),
+		Parent:     m,
+		File:       p.file,
+		IsMapEntry: true,
+	}
+	key := &Field{
+		Name:     "key",
+		Num:      1,
+		Label:    LabelOptional,
+		TypeName: keyType,
+		Parent:   entry,
+		File:     p.file,
+	}
+	value := &Field{
+		Name:     "value",
+		Num:      2,
+		Label:    LabelOptional,
+		TypeName: valueType,
+		Parent:   entry,
+		File:     p.file,
+	}
+	entry.Fields = []*Field{key, value}
+	f.Message = entry
+	f.Map = &MapType{
+		Key:   key,
+		Value: value,
+	}
+	return f, entry, p.parseEnd()
+}
+
+func isValidMapKeyType(typeName string) bool {
+	switch typeName {
+	case "int32", "int64", "uint32", "uint64", "sint32", "sint64", "fixed32", "fixed64", "sfixed32", "sfixed64", "bool", "string":
+		return true
+	default:
+		return false
+	}
+}
+
+// mapEntryName returns the name for the synthetic map entry message for the map field with the given name.
+//
+// For example, it returns "FooBarEntry" for "foo_bar".
+func mapEntryName(fieldName string) string {
+	var sb strings.Builder
+	upper := true
+	for _, c := range fieldName {
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		sb.WriteRune(c)
+	}
+	sb.WriteString("Entry")
+	return sb.String()
+}
+
+func (p *parser) parseOneof(m *Message) error {
+	if err := p.expectIdent("oneof"); err != nil {
+		return err
+	}
+	name, err := p.parseIdent()
+	if err != nil {
+		return err
+	}
+	oneof := &Oneof{
+		Name: name,
+	}
+	if err := p.expectSymbol("{"); err != nil {
+		return err
+	}
+	for !p.isSymbol("}") {
+		switch {
+		case p.isSymbol(";"):
+			if err := p.next(); err != nil {
+				return err
+			}
+		case p.isIdent("option"):
+			o, err := p.parseOptionStatement()
+			if err != nil {
+				return err
+			}
+			oneof.Options = append(oneof.Options, o)
+		default:
+			f, group, err := p.parseField(m, oneof, "")
+			if err != nil {
+				return err
+			}
+			if f.IsRepeated() {
+				return p.errorf("oneof field %s cannot be repeated", f.Name)
+			}
+			oneof.Fields = append(oneof.Fields, f)
+			m.Fields = append(m.Fields, f)
+			if group != nil {
+				m.Messages = append(m.Messages, group)
+			}
+		}
+	}
+	if len(oneof.Fields) == 0 {
+		return p.errorf("oneof %s must contain at least a single field", name)
+	}
+	m.Oneofs = append(m.Oneofs, oneof)
+	return p.next()
+}
+
+// parseReserved parses reserved statement.
+//
+// Reserved numbers are stored into ranges for messages and into enumRanges for enums.
+func (p *parser) parseReserved(ranges *[]Range, enumRanges *[]EnumRange, names *[]string) error {
+	isMessage := ranges != nil
+	if err := p.expectIdent("reserved"); err != nil {
+		return err
+	}
+	if p.tok.kind == tokenString || p.tok.kind == tokenIdent {
+		for {
+			var name string
+			var err error
+			if p.tok.kind == tokenString {
+				name, err = p.parseString()
+			} else {
+				name, err = p.parseIdent()
+			}
+			if err != nil {
+				return err
+			}
+			*names = append(*names, name)
+			if p.isSymbol(";") {
+				return p.next()
+			}
+			if err := p.expectSymbol(","); err != nil {
+				return err
+			}
+		}
+	}
+
+	minValue, maxValue := int64(1), int64(maxFieldNum)
+	if !isMessage {
+		minValue, maxValue = -1<<31, 1<<31-1
+	}
+	for {
+		start, end, err := p.parseRange(minValue, maxValue)
+		if err != nil {
+			return err
+		}
+		if isMessage {
+			*ranges = append(*ranges, Range{
+				Start: uint32(start),
+				End:   uint32(end),
+			})
+		} else {
+			*enumRanges = append(*enumRanges, EnumRange{
+				Start: int32(start),
+				End:   int32(end),
+			})
+		}
+		if p.isSymbol(";") {
+			return p.next()
+		}
+		if err := p.expectSymbol(","); err != nil {
+			return err
+		}
+	}
+}
+
+// parseRange parses `start [to (end|max)]`.
+func (p *parser) parseRange(minValue, maxValue int64) (int64, int64, error) {
+	start, err := p.parseInt(minValue, maxValue)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !p.isIdent("to") {
+		return start, start, nil
+	}
+	if err := p.next(); err != nil {
+		return 0, 0, err
+	}
+	if p.isIdent("max") {
+		return start, maxValue, p.next()
+	}
+	end, err := p.parseInt(minValue, maxValue)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, p.errorf("the range end %d cannot be smaller than the range start %d", end, start)
+	}
+	return start, end, nil
+}
+
+func (p *parser) parseExtensions(m *Message) error {
+	if err := p.expectIdent("extensions"); err != nil {
+		return err
+	}
+	for {
+		start, end, err := p.parseRange(1, maxFieldNum)
+		if err != nil {
+			return err
+		}
+		m.ExtensionRanges = append(m.ExtensionRanges, Range{
+			Start: uint32(start),
+			End:   uint32(end),
+		})
+		if !p.isSymbol(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	if _, err := p.parseOptions(); err != nil {
+		return err
+	}
+	return p.parseEnd()
+}
+
+// parseExtend parses extend block and returns the declared extension fields together with group messages.
+func (p *parser) parseExtend(m *Message) ([]*Field, []*Message, error) {
+	if err := p.expectIdent("extend"); err != nil {
+		return nil, nil, err
+	}
+	extendee, err := p.parseTypeName()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.expectSymbol("{"); err != nil {
+		return nil, nil, err
+	}
+	var fields []*Field
+	var groups []*Message
+	for !p.isSymbol("}") {
+		if p.isSymbol(";") {
+			if err := p.next(); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		f, group, err := p.parseField(m, nil, extendee)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields = append(fields, f)
+		if group != nil {
+			groups = append(groups, group)
+		}
+	}
+	return fields, groups, p.next()
+}
+
+func (p *parser) parseEnum(parent *Message) (*Enum, error) {
+	if err := p.expectIdent("enum"); err != nil {
+		return nil, err
+	}
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	e := &Enum{
+		Name:   name,
+		Parent: parent,
+		File:   p.file,
+	}
+	if err := p.expectSymbol("{"); err != nil {
+		return nil, err
+	}
+	for !p.isSymbol("}") {
+		switch {
+		case p.isSymbol(";"):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case p.isIdent("option"):
+			o, err := p.parseOptionStatement()
+			if err != nil {
+				return nil, err
+			}
+			e.Options = append(e.Options, o)
+		case p.isIdent("reserved"):
+			if err := p.parseReserved(nil, &e.ReservedRanges, &e.ReservedNames); err != nil {
+				return nil, err
+			}
+		default:
+			v, err := p.parseEnumValue()
+			if err != nil {
+				return nil, err
+			}
+			e.Values = append(e.Values, v)
+		}
+	}
+	if len(e.Values) == 0 {
+		return nil, p.errorf("enum %s must contain at least a single value", name)
+	}
+	if p.file.Syntax == "proto3" && e.Values[0].Number != 0 {
+		return nil, p.errorf("the first value for enum %s must be zero in proto3", name)
+	}
+	if err := p.checkEnum(e); err != nil {
+		return nil, err
+	}
+	return e, p.next()
+}
+
+func (p *parser) parseEnumValue() (*EnumValue, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("="); err != nil {
+		return nil, err
+	}
+	n, err := p.parseInt(-1<<31, 1<<31-1)
+	if err != nil {
+		return nil, err
+	}
+	options, err := p.parseOptions()
+	if err != nil {
+		return nil, err
+	}
+	v := &EnumValue{
+		Name:    name,
+		Number:  int32(n),
+		Options: options,
+	}
+	return v, p.parseEnd()
+}
+
+func (p *parser) parseService() (*Service, error) {
+	if err := p.expectIdent("service"); err != nil {
+		return nil, err
+	}
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		Name: name,
+	}
+	if err := p.expectSymbol("{"); err != nil {
+		return nil, err
+	}
+	for !p.isSymbol("}") {
+		switch {
+		case p.isSymbol(";"):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case p.isIdent("option"):
+			o, err := p.parseOptionStatement()
+			if err != nil {
+				return nil, err
+			}
+			s.Options = append(s.Options, o)
+		case p.isIdent("rpc"):
+			method, err := p.parseMethod()
+			if err != nil {
+				return nil, err
+			}
+			s.Methods = append(s.Methods, method)
+		default:
+			return nil, p.errorf("unexpected %s in service %s", &p.tok, name)
+		}
+	}
+	return s, p.next()
+}
+
+func (p *parser) parseMethod() (*Method, error) {
+	if err := p.expectIdent("rpc"); err != nil {
+		return nil, err
+	}
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	method := &Method{
+		Name: name,
+	}
+	method.InputTypeName, method.ClientStreaming, err = p.parseMethodType()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectIdent("returns"); err != nil {
+		return nil, err
+	}
+	method.OutputTypeName, method.ServerStreaming, err = p.parseMethodType()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isSymbol("{") {
+		return method, p.parseEnd()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	for !p.isSymbol("}") {
+		if p.isSymbol(";") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		o, err := p.parseOptionStatement()
+		if err != nil {
+			return nil, err
+		}
+		method.Options = append(method.Options, o)
+	}
+	return method, p.next()
+}
+
+// parseMethodType parses `([stream] TypeName)`.
+func (p *parser) parseMethodType() (string, bool, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return "", false, err
+	}
+	isStream := false
+	if p.isIdent("stream") {
+		isStream = true
+		if err := p.next(); err != nil {
+			return "", false, err
+		}
+		if p.isSymbol(")") {
+			// The message type is named "stream"
+			isStream = false
+			return "stream", false, p.next()
+		}
+	}
+	typeName, err := p.parseTypeName()
+	if err != nil {
+		return "", false, err
+	}
+	return typeName, isStream, p.expectSymbol(")")
+}
+
+// checkMessage verifies that m has no conflicting fields.
+func (p *parser) checkMessage(m *Message) error {
+	nums := make(map[uint32]string, len(m.Fields))
+	names := make(map[string]struct{}, len(m.Fields))
+	for _, f := range m.Fields {
+		if name, ok := nums[f.Num]; ok {
+			return p.errorf("field %s.%s uses the same number %d as the field %s", m.Name, f.Name, f.Num, name)
+		}
+		nums[f.Num] = f.Name
+		if _, ok := names[f.Name]; ok {
+			return p.errorf("duplicate field name %s.%s", m.Name, f.Name)
+		}
+		names[f.Name] = struct{}{}
+		for _, r := range m.ReservedRanges {
+			if f.Num >= r.Start && f.Num <= r.End {
+				return p.errorf("field %s.%s uses reserved number %d", m.Name, f.Name, f.Num)
+			}
+		}
+		for _, r := range m.ExtensionRanges {
+			if f.Num >= r.Start && f.Num <= r.End {
+				return p.errorf("field %s.%s uses number %d from extension range", m.Name, f.Name, f.Num)
+			}
+		}
+		for _, name := range m.ReservedNames {
+			if f.Name == name {
+				return p.errorf("field %s.%s uses reserved name", m.Name, f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkEnum verifies that e has no conflicting values.
+func (p *parser) checkEnum(e *Enum) error {
+	_, allowAlias := findOption(e.Options, "allow_alias")
+	numbers := make(map[int32]string, len(e.Values))
+	names := make(map[string]struct{}, len(e.Values))
+	for _, v := range e.Values {
+		if name, ok := numbers[v.Number]; ok && !allowAlias {
+			return p.errorf("enum value %s.%s uses the same number %d as the value %s; set allow_alias option if this is expected", e.Name, v.Name, v.Number, name)
+		}
+		numbers[v.Number] = v.Name
+		if _, ok := names[v.Name]; ok {
+			return p.errorf("duplicate enum value name %s.%s", e.Name, v.Name)
+		}
+		names[v.Name] = struct{}{}
+		for _, r := range e.ReservedRanges {
+			if v.Number >= r.Start && v.Number <= r.End {
+				return p.errorf("enum value %s.%s uses reserved number %d", e.Name, v.Name, v.Number)
+			}
+		}
+		for _, name := range e.ReservedNames {
+			if v.Name == name {
+				return p.errorf("enum value %s.%s uses reserved name", e.Name, v.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,501 @@
+package protoparse
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+const testProto3 = `
+// Comment
+syntax = "proto3";
+
+package foo.bar;
+
+option go_package = "example.com/foo/bar";
+
+/* Block
+   comment */
+message Timeseries {
+	string name = 1;
+	repeated Sample samples = 2 [json_name = "s"];
+	map<string, Label> labels = 3;
+	repeated uint32 ids = 4 [packed = false];
+	repeated sint64 deltas = 5;
+	Status status = 6;
+	oneof value {
+		double dv = 7;
+		bytes bv = 8;
+	}
+	optional bool flag = 9;
+	reserved 10, 12 to 14, 100 to max;
+	reserved "old_name";
+
+	message Sample {
+		double value = 1;
+		int64 timestamp = 2;
+	}
+}
+
+message Label {
+	string value = 1;
+	.foo.bar.Label next = 2;
+}
+
+enum Status {
+	option allow_alias = true;
+	UNKNOWN = 0;
+	OK = 1;
+	FINE = 1;
+	ERROR = -1 [deprecated = true];
+	reserved 5 to 10;
+}
+
+service Storage {
+	rpc Write(stream Timeseries) returns (Label);
+	rpc Read(Label) returns (stream Timeseries) {
+		option deadline = 1.5;
+	}
+}
+`
+
+func TestParseProto3(t *testing.T) {
+	file, err := Parse("foo.proto", []byte(testProto3))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if file.Syntax != "proto3" {
+		t.Fatalf("unexpected syntax; got %q; want %q", file.Syntax, "proto3")
+	}
+	if file.Package != "foo.bar" {
+		t.Fatalf("unexpected package; got %q; want %q", file.Package, "foo.bar")
+	}
+	if v, _ := findOption(file.Options, "go_package"); v != "example.com/foo/bar" {
+		t.Fatalf("unexpected go_package option; got %q", v)
+	}
+
+	if len(file.Messages) != 2 {
+		t.Fatalf("unexpected number of messages; got %d; want 2", len(file.Messages))
+	}
+	ts := file.Messages[0]
+	if ts.FullName != "foo.bar.Timeseries" {
+		t.Fatalf("unexpected full name; got %q; want %q", ts.FullName, "foo.bar.Timeseries")
+	}
+
+	f := func(name string, numExpected uint32, kindExpected easyproto.Kind, isRepeatedExpected, isPackedExpected bool) {
+		t.Helper()
+
+		field := ts.FieldByName(name)
+		if field == nil {
+			t.Fatalf("cannot find field %q", name)
+		}
+		if field.Num != numExpected {
+			t.Fatalf("unexpected field number; got %d; want %d", field.Num, numExpected)
+		}
+		if field.Kind != kindExpected {
+			t.Fatalf("unexpected kind; got %s; want %s", field.Kind, kindExpected)
+		}
+		if field.IsRepeated() != isRepeatedExpected {
+			t.Fatalf("unexpected IsRepeated; got %v; want %v", field.IsRepeated(), isRepeatedExpected)
+		}
+		if field.IsPacked() != isPackedExpected {
+			t.Fatalf("unexpected IsPacked; got %v; want %v", field.IsPacked(), isPackedExpected)
+		}
+		if ts.FieldByNum(numExpected) != field {
+			t.Fatalf("unexpected field returned by FieldByNum(%d)", numExpected)
+		}
+	}
+
+	f("name", 1, easyproto.KindString, false, false)
+	f("samples", 2, easyproto.KindMessage, true, false)
+	f("labels", 3, easyproto.KindMessage, true, false)
+	f("ids", 4, easyproto.KindUint32, true, false)
+	f("deltas", 5, easyproto.KindSint64, true, true)
+	f("status", 6, easyproto.KindEnum, false, false)
+	f("dv", 7, easyproto.KindDouble, false, false)
+	f("bv", 8, easyproto.KindBytes, false, false)
+	f("flag", 9, easyproto.KindBool, false, false)
+
+	samples := ts.FieldByName("samples")
+	if samples.Message.FullName != "foo.bar.Timeseries.Sample" {
+		t.Fatalf("unexpected message for samples; got %q", samples.Message.FullName)
+	}
+	if v, _ := samples.Option("json_name"); v != "s" {
+		t.Fatalf("unexpected json_name option; got %q; want %q", v, "s")
+	}
+
+	labels := ts.FieldByName("labels")
+	if labels.Map == nil || !labels.Message.IsMapEntry || labels.Message.Name != "LabelsEntry" {
+		t.Fatalf("unexpected map field: %+v", labels)
+	}
+	if labels.Map.Key.Kind != easyproto.KindString {
+		t.Fatalf("unexpected map key kind; got %s; want %s", labels.Map.Key.Kind, easyproto.KindString)
+	}
+	if labels.Map.Value.Kind != easyproto.KindMessage || labels.Map.Value.Message != file.Messages[1] {
+		t.Fatalf("unexpected map value: %+v", labels.Map.Value)
+	}
+
+	if status := ts.FieldByName("status"); status.Enum != file.Enums[0] {
+		t.Fatalf("unexpected enum for status field")
+	}
+	if len(ts.Oneofs) != 1 || ts.Oneofs[0].Name != "value" || len(ts.Oneofs[0].Fields) != 2 {
+		t.Fatalf("unexpected oneofs: %+v", ts.Oneofs)
+	}
+	rangesExpected := []Range{{10, 10}, {12, 14}, {100, maxFieldNum}}
+	if !reflect.DeepEqual(ts.ReservedRanges, rangesExpected) {
+		t.Fatalf("unexpected reserved ranges; got %v; want %v", ts.ReservedRanges, rangesExpected)
+	}
+	if !reflect.DeepEqual(ts.ReservedNames, []string{"old_name"}) {
+		t.Fatalf("unexpected reserved names; got %q", ts.ReservedNames)
+	}
+
+	if next := file.Messages[1].FieldByName("next"); next.Message != file.Messages[1] {
+		t.Fatalf("unexpected message for the fully-qualified type")
+	}
+
+	e := file.Enums[0]
+	if e.FullName != "foo.bar.Status" || len(e.Values) != 4 {
+		t.Fatalf("unexpected enum: %+v", e)
+	}
+	if v := e.ValueByNumber(1); v == nil || v.Name != "OK" {
+		t.Fatalf("unexpected enum value for 1: %+v", v)
+	}
+	if v := e.ValueByNumber(-1); v == nil || v.Name != "ERROR" {
+		t.Fatalf("unexpected enum value for -1: %+v", v)
+	}
+	if !reflect.DeepEqual(e.ReservedRanges, []EnumRange{{5, 10}}) {
+		t.Fatalf("unexpected enum reserved ranges: %v", e.ReservedRanges)
+	}
+
+	s := file.Services[0]
+	if s.FullName != "foo.bar.Storage" || len(s.Methods) != 2 {
+		t.Fatalf("unexpected service: %+v", s)
+	}
+	w := s.Methods[0]
+	if !w.ClientStreaming || w.ServerStreaming || w.InputType != ts || w.OutputType != file.Messages[1] {
+		t.Fatalf("unexpected Write method: %+v", w)
+	}
+	r := s.Methods[1]
+	if r.ClientStreaming || !r.ServerStreaming || r.InputType != file.Messages[1] || r.OutputType != ts {
+		t.Fatalf("unexpected Read method: %+v", r)
+	}
+	if v, _ := findOption(r.Options, "deadline"); v != "1.5" {
+		t.Fatalf("unexpected deadline option; got %q; want %q", v, "1.5")
+	}
+}
+
+func TestParseProto2(t *testing.T) {
+	src := `
+syntax = "proto2";
+
+message Foo {
+	required int32 a = 1 [default = -5];
+	optional string b = 2 [default = "x" 'y'];
+	repeated fixed64 c = 3;
+	repeated float d = 4 [packed = true];
+	optional group Result = 5 {
+		optional string url = 1;
+	}
+	extensions 100 to 199;
+	optional Foo self = 6 [(my.opt).x = {a: 1 b: {c: "d"}}];
+}
+
+extend Foo {
+	optional int32 ext = 100;
+}
+`
+	file, err := Parse("foo.proto", []byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	m := file.Messages[0]
+
+	a := m.FieldByName("a")
+	if a.Label != LabelRequired || a.Kind != easyproto.KindInt32 {
+		t.Fatalf("unexpected field a: %+v", a)
+	}
+	if v, ok := a.DefaultValue(); !ok || v != "-5" {
+		t.Fatalf("unexpected default value for a; got %q; want %q", v, "-5")
+	}
+	if v, _ := m.FieldByName("b").DefaultValue(); v != "xy" {
+		t.Fatalf("unexpected default value for b; got %q; want %q", v, "xy")
+	}
+	if c := m.FieldByName("c"); c.Kind != easyproto.KindFixed64 || c.IsPacked() {
+		t.Fatalf("unexpected field c: %+v", c)
+	}
+	if d := m.FieldByName("d"); !d.IsPacked() {
+		t.Fatalf("field d must be packed")
+	}
+
+	result := m.FieldByName("result")
+	if result == nil || result.Num != 5 || result.Kind != easyproto.KindGroup {
+		t.Fatalf("unexpected group field: %+v", result)
+	}
+	if result.Message.FullName != "Foo.Result" || result.Message.FieldByName("url") == nil {
+		t.Fatalf("unexpected group message: %+v", result.Message)
+	}
+
+	if self := m.FieldByName("self"); self.Message != m {
+		t.Fatalf("unexpected message for recursive field")
+	}
+	if v, _ := m.FieldByName("self").Option("(my.opt).x"); v != `a : 1 b : { c : "d" }` {
+		t.Fatalf("unexpected aggregate option value; got %q", v)
+	}
+
+	if !reflect.DeepEqual(m.ExtensionRanges, []Range{{100, 199}}) {
+		t.Fatalf("unexpected extension ranges: %v", m.ExtensionRanges)
+	}
+	if len(file.Extensions) != 1 || file.Extensions[0].Extendee != "Foo" || file.Extensions[0].Kind != easyproto.KindInt32 {
+		t.Fatalf("unexpected extensions: %+v", file.Extensions)
+	}
+}
+
+func TestParseEditions(t *testing.T) {
+	src := `
+edition = "2023";
+
+message Foo {
+	repeated int32 a = 1;
+	repeated int32 b = 2 [features.repeated_field_encoding = EXPANDED];
+	Foo c = 3 [features.message_encoding = DELIMITED];
+	reserved foo, bar;
+}
+`
+	file, err := Parse("foo.proto", []byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if file.Syntax != "editions" || file.Edition != "2023" {
+		t.Fatalf("unexpected syntax; got %q, %q", file.Syntax, file.Edition)
+	}
+	m := file.Messages[0]
+	if !m.FieldByName("a").IsPacked() {
+		t.Fatalf("field a must be packed")
+	}
+	if m.FieldByName("b").IsPacked() {
+		t.Fatalf("field b mustn't be packed")
+	}
+	if c := m.FieldByName("c"); c.Kind != easyproto.KindGroup {
+		t.Fatalf("unexpected kind for delimited field; got %s; want %s", c.Kind, easyproto.KindGroup)
+	}
+	if !reflect.DeepEqual(m.ReservedNames, []string{"foo", "bar"}) {
+		t.Fatalf("unexpected reserved names: %q", m.ReservedNames)
+	}
+}
+
+func TestParseEditionsFileFeatures(t *testing.T) {
+	src := `
+edition = "2023";
+
+option features.field_presence = IMPLICIT;
+option features.message_encoding = DELIMITED;
+option features.repeated_field_encoding = EXPANDED;
+
+message Foo {
+	int32 a = 1;
+	int32 b = 2 [features.field_presence = EXPLICIT];
+	repeated int32 c = 3;
+	repeated int32 d = 4 [features.repeated_field_encoding = PACKED];
+	Foo e = 5;
+	map<string, Foo> f = 6;
+	Bar g = 7;
+	message Bar {
+		option features.message_encoding = LENGTH_PREFIXED;
+		Foo h = 1;
+	}
+}
+`
+	file, err := Parse("foo.proto", []byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	m := file.Messages[0]
+	if m.FieldByName("a").HasPresence() {
+		t.Fatalf("field a mustn't have presence")
+	}
+	if !m.FieldByName("b").HasPresence() {
+		t.Fatalf("field b must have presence")
+	}
+	if m.FieldByName("c").IsPacked() {
+		t.Fatalf("field c mustn't be packed")
+	}
+	if !m.FieldByName("d").IsPacked() {
+		t.Fatalf("field d must be packed")
+	}
+
+	f := func(m *Message, name string, kindExpected easyproto.Kind) {
+		t.Helper()
+
+		fs, ok := m.Schema().FieldByName(name)
+		if !ok {
+			t.Fatalf("cannot find field %q in schema", name)
+		}
+		if fs.Kind != kindExpected {
+			t.Fatalf("unexpected kind for the field %q; got %s; want %s", name, fs.Kind, kindExpected)
+		}
+	}
+	f(m, "e", easyproto.KindGroup)
+	f(m, "g", easyproto.KindGroup)
+	f(m.FieldByName("g").Message, "h", easyproto.KindMessage)
+
+	// Map entries are always length-prefixed.
+	f(m, "f", easyproto.KindMessage)
+	f(m.FieldByName("f").Message, "value", easyproto.KindMessage)
+}
+
+func TestParseFailure(t *testing.T) {
+	f := func(src, errExpected string) {
+		t.Helper()
+
+		_, err := Parse("foo.proto", []byte(src))
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("unexpected error; got %q; want it to contain %q", err, errExpected)
+		}
+	}
+
+	// lexer errors
+	f(`syntax = "proto3`, "foo.proto:1:10: missing closing quote")
+	f(`/* comment`, "foo.proto:1:1: missing */")
+	f(`syntax = "proto3"; message Foo { int32 a = 12x; }`, "invalid number")
+
+	// unsupported syntax
+	f(`syntax = "proto4";`, "unsupported syntax")
+
+	// unexpected tokens
+	f(`syntax = "proto3"; foo`, `foo.proto:1:20: unexpected identifier "foo"`)
+	f(`syntax = "proto3"; message Foo { int32 a = 1 }`, `expecting ";"`)
+	f(`syntax = "proto3"; message Foo { int32 a = 1;`, "end of file")
+
+	// invalid field numbers
+	f(`syntax = "proto3"; message Foo { int32 a = 0; }`, "out of range")
+	f(`syntax = "proto3"; message Foo { int32 a = 536870912; }`, "out of range")
+	f(`syntax = "proto3"; message Foo { int32 a = 19500; }`, "reserved for protobuf implementation")
+
+	// conflicting fields
+	f(`syntax = "proto3"; message Foo { int32 a = 1; int32 b = 1; }`, "uses the same number 1")
+	f(`syntax = "proto3"; message Foo { int32 a = 1; int32 a = 2; }`, "duplicate field name Foo.a")
+	f(`syntax = "proto3"; message Foo { int32 a = 1; reserved 1; }`, "uses reserved number 1")
+	f(`syntax = "proto3"; message Foo { int32 a = 1; reserved "a"; }`, "uses reserved name")
+
+	// syntax-specific checks
+	f(`syntax = "proto3"; message Foo { required int32 a = 1; }`, "required fields aren't allowed in proto3")
+	f(`syntax = "proto3"; message Foo { int32 a = 1 [default = 5]; }`, "default values aren't allowed in proto3")
+	f(`syntax = "proto2"; message Foo { int32 a = 1; }`, "expecting field label")
+	f(`syntax = "proto3"; message Foo { optional group Bar = 1 {} }`, "groups are allowed only in proto2")
+	f(`edition = "2023"; message Foo { optional int32 a = 1; }`, "optional label isn't allowed in editions")
+
+	// invalid maps
+	f(`syntax = "proto3"; message Foo { map<double, string> m = 1; }`, "invalid map key type double")
+
+	// invalid enums
+	f(`syntax = "proto3"; enum Foo { A = 1; }`, "the first value for enum Foo must be zero")
+	f(`syntax = "proto3"; enum Foo { A = 0; B = 0; }`, "set allow_alias option")
+	f(`syntax = "proto3"; enum Foo {}`, "must contain at least a single value")
+
+	// unresolved types
+	f(`syntax = "proto3"; message Foo { Bar a = 1; }`, "cannot resolve type Bar")
+	f(`syntax = "proto3"; message Foo { Svc a = 1; } service Svc {}`, "Svc isn't a message or enum type")
+	f(`syntax = "proto3"; service Svc { rpc A(Foo) returns (Foo); }`, "cannot find message type Foo")
+	f(`syntax = "proto3"; message Foo {} message Foo {}`, "duplicate symbol Foo")
+
+	// missing import
+	f(`syntax = "proto3"; import "bar.proto";`, `cannot load "bar.proto"`)
+}
+
+func TestParserImports(t *testing.T) {
+	files := map[string]string{
+		"dir/a.proto": `
+syntax = "proto3";
+package a;
+import public "b.proto";
+message A {
+	b.B b = 1;
+	Nested.Inner inner = 2;
+	message Nested {
+		message Inner {
+			B2 x = 1;
+		}
+	}
+}
+message B2 {}
+`,
+		"dir/b.proto": `
+syntax = "proto3";
+package b;
+import "c.proto";
+message B {
+	c.C c = 1;
+}
+`,
+		"dir/c.proto": `
+syntax = "proto3";
+package c;
+message C {}
+`,
+	}
+	p := &Parser{
+		ImportPaths: []string{"other", "dir"},
+		Accessor: func(path string) ([]byte, error) {
+			src, ok := files[path]
+			if !ok {
+				return nil, fmt.Errorf("cannot open %q: %w", path, os.ErrNotExist)
+			}
+			return []byte(src), nil
+		},
+	}
+	result, err := p.ParseFiles("a.proto")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	a := result[0]
+	if len(a.Imports) != 1 || !a.Imports[0].Public || a.Imports[0].File == nil {
+		t.Fatalf("unexpected imports: %+v", a.Imports)
+	}
+	b := a.Imports[0].File
+	if fieldB := a.Messages[0].FieldByName("b"); fieldB.Message != b.Messages[0] {
+		t.Fatalf("unexpected message for the imported type")
+	}
+	c := b.Imports[0].File
+	if fieldC := b.Messages[0].FieldByName("c"); fieldC.Message != c.Messages[0] {
+		t.Fatalf("unexpected message for the transitively imported type")
+	}
+	inner := a.Messages[0].FieldByName("inner").Message
+	if inner.FullName != "a.A.Nested.Inner" {
+		t.Fatalf("unexpected full name; got %q; want %q", inner.FullName, "a.A.Nested.Inner")
+	}
+	if x := inner.FieldByName("x"); x.Message != a.Messages[1] {
+		t.Fatalf("unexpected message for the type from the outer scope")
+	}
+
+	// Types from publicly imported files are visible to importers, while types from other transitive imports aren't.
+	files["dir/d.proto"] = `syntax = "proto3"; import "a.proto"; message D { b.B b = 1; }`
+	if _, err := p.ParseFiles("d.proto"); err != nil {
+		t.Fatalf("unexpected error for the type from publicly imported file: %s", err)
+	}
+	files["dir/d.proto"] = `syntax = "proto3"; import "a.proto"; message D { c.C c = 1; }`
+	if _, err := p.ParseFiles("d.proto"); err == nil || !strings.Contains(err.Error(), "c.C is declared in c.proto, which isn't imported by d.proto") {
+		t.Fatalf("expecting error for the type from not imported file; got %v", err)
+	}
+	files["dir/d.proto"] = `syntax = "proto3"; message D { c.C c = 1; }`
+	if _, err := p.ParseFiles("c.proto", "d.proto"); err == nil || !strings.Contains(err.Error(), "isn't imported by d.proto") {
+		t.Fatalf("expecting error for the type from not imported file; got %v", err)
+	}
+
+	// import cycle
+	files["dir/c.proto"] = `syntax = "proto3"; import "a.proto";`
+	if _, err := p.ParseFiles("a.proto"); err == nil || !strings.Contains(err.Error(), "import cycle") {
+		t.Fatalf("expecting import cycle error; got %v", err)
+	}
+
+	// missing file
+	if _, err := p.ParseFiles("missing.proto"); err == nil || !strings.Contains(err.Error(), "cannot find") {
+		t.Fatalf("expecting missing file error; got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package protoparse parses .proto files into descriptors, which can be used for building easyproto.MessageSchema.
+//
+// The package supports proto2, proto3 and editions syntax. It doesn't depend on protoc or google.golang.org/protobuf.
+// Well-known types such as google/protobuf/timestamp.proto and google/protobuf/descriptor.proto are embedded into the package,
+// so they can be imported without having them on disk.
+package protoparse
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Parser parses .proto files together with their imports.
+type Parser struct {
+	// ImportPaths contains directories to search for .proto files and their imports.
+	//
+	// The file names are used as is if ImportPaths is empty.
+	ImportPaths []string
+
+	// Accessor is an optional function for reading .proto files.
+	//
+	// os.ReadFile is used if Accessor is nil.
+	Accessor func(path string) ([]byte, error)
+}
+
+// ParseFiles parses .proto files with the given names and all their imports.
+//
+// The returned files are in the order of the passed names. Imported files are available via Import.File.
+//
+// Type references are resolved according to protobuf import rules: every file may refer to types declared in the file itself,
+// in the files imported by it and in the files publicly imported by these files.
+func (p *Parser) ParseFiles(names ...string) ([]*File, error) {
+	ld := &loader{
+		p:       p,
+		files:   make(map[string]*File),
+		loading: make(map[string]bool),
+	}
+	result := make([]*File, 0, len(names))
+	for _, name := range names {
+		f, err := ld.load(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	if err := resolveFiles(ld.ordered); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Parse parses .proto file contents from src.
+//
+// name is used in error messages. The file must not import other files except of well-known types; use Parser.ParseFiles for parsing files with imports.
+func Parse(name string, src []byte) (*File, error) {
+	p := &Parser{
+		Accessor: func(path string) ([]byte, error) {
+			if path != name {
+				return nil, fmt.Errorf("cannot import %q: %w", path, os.ErrNotExist)
+			}
+			return src, nil
+		},
+	}
+	files, err := p.ParseFiles(name)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+// loader loads .proto files and their imports for Parser.
+type loader struct {
+	p *Parser
+
+	// files contains the loaded files keyed by their names.
+	files map[string]*File
+
+	// ordered contains the loaded files in the load order.
+	ordered []*File
+
+	// loading contains files, which are currently being loaded. It is used for detecting import cycles.
+	loading map[string]bool
+}
+
+func (ld *loader) load(name string) (*File, error) {
+	if f, ok := ld.files[name]; ok {
+		return f, nil
+	}
+	if ld.loading[name] {
+		return nil, fmt.Errorf("import cycle detected for %q", name)
+	}
+	ld.loading[name] = true
+	defer delete(ld.loading, name)
+
+	src, err := ld.read(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := parseFile(name, string(src))
+	if err != nil {
+		return nil, err
+	}
+	for _, imp := range f.Imports {
+		impFile, err := ld.load(imp.Path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load %q imported from %q: %w", imp.Path, name, err)
+		}
+		imp.File = impFile
+	}
+	ld.files[name] = f
+	ld.ordered = append(ld.ordered, f)
+	return f, nil
+}
+
+// read returns the contents for the file with the given name.
+//
+// Embedded well-known files are used if the file cannot be found via Parser.Accessor and Parser.ImportPaths.
+func (ld *loader) read(name string) ([]byte, error) {
+	data, err := ld.readFile(name)
+	if err != nil && errors.Is(err, os.ErrNotExist) {
+		if wkData, ok := readWellKnownFile(name); ok {
+			return wkData, nil
+		}
+	}
+	return data, err
+}
+
+func (ld *loader) readFile(name string) ([]byte, error) {
+	readFile := ld.p.Accessor
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+	if len(ld.p.ImportPaths) == 0 {
+		return readFile(name)
+	}
+	for _, dir := range ld.p.ImportPaths {
+		data, err := readFile(filepath.Join(dir, name))
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("cannot find %q in import paths [%s]: %w", name, strings.Join(ld.p.ImportPaths, ", "), os.ErrNotExist)
+}
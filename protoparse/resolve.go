@@ -0,0 +1,301 @@
+package protoparse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// scalarKinds maps scalar type names to the corresponding kinds.
+var scalarKinds = map[string]easyproto.Kind{
+	"int32":    easyproto.KindInt32,
+	"int64":    easyproto.KindInt64,
+	"uint32":   easyproto.KindUint32,
+	"uint64":   easyproto.KindUint64,
+	"sint32":   easyproto.KindSint32,
+	"sint64":   easyproto.KindSint64,
+	"bool":     easyproto.KindBool,
+	"fixed64":  easyproto.KindFixed64,
+	"sfixed64": easyproto.KindSfixed64,
+	"double":   easyproto.KindDouble,
+	"string":   easyproto.KindString,
+	"bytes":    easyproto.KindBytes,
+	"fixed32":  easyproto.KindFixed32,
+	"sfixed32": easyproto.KindSfixed32,
+	"float":    easyproto.KindFloat,
+}
+
+// resolver resolves type names across the parsed files.
+type resolver struct {
+	// symbols maps fully-qualified names to symbols.
+	symbols map[string]symbol
+
+	// visibleFiles contains files, which are visible from the given file. See visibleFilesFor for details.
+	visibleFiles map[*File]map[*File]bool
+}
+
+// symbol is a named type declared in a file.
+type symbol struct {
+	// v is *Message, *Enum or *Service.
+	v interface{}
+
+	// file is the file, which declares v.
+	file *File
+}
+
+// resolveFiles sets full names and resolves type references for all the fields and methods in files.
+func resolveFiles(files []*File) error {
+	r := &resolver{
+		symbols:      make(map[string]symbol),
+		visibleFiles: make(map[*File]map[*File]bool),
+	}
+	for _, f := range files {
+		if err := r.registerFile(f); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		if err := r.resolveFile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *resolver) register(f *File, fullName string, v interface{}) error {
+	if _, ok := r.symbols[fullName]; ok {
+		return fmt.Errorf("%s: duplicate symbol %s", f.Name, fullName)
+	}
+	r.symbols[fullName] = symbol{
+		v:    v,
+		file: f,
+	}
+	return nil
+}
+
+func (r *resolver) registerFile(f *File) error {
+	for _, m := range f.Messages {
+		if err := r.registerMessage(f, f.Package, m); err != nil {
+			return err
+		}
+	}
+	for _, e := range f.Enums {
+		e.FullName = joinName(f.Package, e.Name)
+		if err := r.register(f, e.FullName, e); err != nil {
+			return err
+		}
+	}
+	for _, s := range f.Services {
+		s.FullName = joinName(f.Package, s.Name)
+		if err := r.register(f, s.FullName, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *resolver) registerMessage(f *File, scope string, m *Message) error {
+	m.FullName = joinName(scope, m.Name)
+	if err := r.register(f, m.FullName, m); err != nil {
+		return err
+	}
+	for _, nested := range m.Messages {
+		if err := r.registerMessage(f, m.FullName, nested); err != nil {
+			return err
+		}
+	}
+	for _, e := range m.Enums {
+		e.FullName = joinName(m.FullName, e.Name)
+		if err := r.register(f, e.FullName, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *resolver) resolveFile(f *File) error {
+	for _, m := range f.Messages {
+		if err := r.resolveMessage(m); err != nil {
+			return err
+		}
+	}
+	for _, field := range f.Extensions {
+		if err := r.resolveField(f.Package, field); err != nil {
+			return err
+		}
+	}
+	for _, s := range f.Services {
+		for _, method := range s.Methods {
+			var err error
+			method.InputType, err = r.resolveMessageType(f, f.Package, method.InputTypeName)
+			if err != nil {
+				return fmt.Errorf("cannot resolve input type for %s.%s: %w", s.FullName, method.Name, err)
+			}
+			method.OutputType, err = r.resolveMessageType(f, f.Package, method.OutputTypeName)
+			if err != nil {
+				return fmt.Errorf("cannot resolve output type for %s.%s: %w", s.FullName, method.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *resolver) resolveMessage(m *Message) error {
+	for _, field := range m.Fields {
+		if err := r.resolveField(m.FullName, field); err != nil {
+			return err
+		}
+	}
+	for _, field := range m.Extensions {
+		if err := r.resolveField(m.FullName, field); err != nil {
+			return err
+		}
+	}
+	for _, nested := range m.Messages {
+		if err := r.resolveMessage(nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *resolver) resolveField(scope string, field *Field) error {
+	if field.Map != nil {
+		// Map entry fields are resolved together with the synthetic map entry message.
+		field.Kind = easyproto.KindMessage
+		return nil
+	}
+	if field.Kind == easyproto.KindGroup {
+		// Group fields are resolved by the parser.
+		return nil
+	}
+	if k, ok := scalarKinds[field.TypeName]; ok {
+		field.Kind = k
+		return nil
+	}
+	v, fullName, err := r.lookup(field.File, scope, field.TypeName)
+	if err != nil {
+		return fmt.Errorf("%s: cannot resolve type %s for the field %s: %w", field.File.Name, field.TypeName, field.Name, err)
+	}
+	switch t := v.(type) {
+	case *Message:
+		field.Kind = easyproto.KindMessage
+		field.Message = t
+		if v, ok := field.Feature("message_encoding"); ok && v == "DELIMITED" && (field.Parent == nil || !field.Parent.IsMapEntry) {
+			// Map entry values are always length-prefixed.
+			field.Kind = easyproto.KindGroup
+		}
+		return nil
+	case *Enum:
+		field.Kind = easyproto.KindEnum
+		field.Enum = t
+		return nil
+	case nil:
+		return fmt.Errorf("%s: cannot resolve type %s for the field %s", field.File.Name, field.TypeName, field.Name)
+	default:
+		return fmt.Errorf("%s: %s isn't a message or enum type for the field %s", field.File.Name, fullName, field.Name)
+	}
+}
+
+func (r *resolver) resolveMessageType(f *File, scope, typeName string) (*Message, error) {
+	v, fullName, err := r.lookup(f, scope, typeName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot find message type %s: %w", f.Name, typeName, err)
+	}
+	switch t := v.(type) {
+	case *Message:
+		return t, nil
+	case nil:
+		return nil, fmt.Errorf("%s: cannot find message type %s", f.Name, typeName)
+	default:
+		return nil, fmt.Errorf("%s: %s isn't a message type", f.Name, fullName)
+	}
+}
+
+// lookup searches for the type with the given name starting from the given scope and going outwards.
+//
+// Only the types from files visible from f are returned. nil is returned if the type cannot be found.
+// An error is returned if the type is declared only in files, which aren't visible from f.
+func (r *resolver) lookup(f *File, scope, name string) (interface{}, string, error) {
+	visible := r.visibleFilesFor(f)
+	var hidden *symbol
+	var hiddenName string
+	check := func(fullName string) (interface{}, bool) {
+		sym, ok := r.symbols[fullName]
+		if !ok {
+			return nil, false
+		}
+		if !visible[sym.file] {
+			if hidden == nil {
+				hidden = &sym
+				hiddenName = fullName
+			}
+			return nil, false
+		}
+		return sym.v, true
+	}
+
+	if strings.HasPrefix(name, ".") {
+		fullName := name[1:]
+		if v, ok := check(fullName); ok {
+			return v, fullName, nil
+		}
+	} else {
+		for {
+			fullName := joinName(scope, name)
+			if v, ok := check(fullName); ok {
+				return v, fullName, nil
+			}
+			if scope == "" {
+				break
+			}
+			n := strings.LastIndexByte(scope, '.')
+			if n < 0 {
+				scope = ""
+			} else {
+				scope = scope[:n]
+			}
+		}
+	}
+	if hidden != nil {
+		return nil, "", fmt.Errorf("%s is declared in %s, which isn't imported by %s", hiddenName, hidden.file.Name, f.Name)
+	}
+	return nil, "", nil
+}
+
+// visibleFilesFor returns files, which are visible from f.
+//
+// These are f itself, the files imported by f and the files publicly imported by the visible imported files.
+func (r *resolver) visibleFilesFor(f *File) map[*File]bool {
+	if m, ok := r.visibleFiles[f]; ok {
+		return m
+	}
+	m := map[*File]bool{
+		f: true,
+	}
+	var addPublicImports func(imported *File)
+	addPublicImports = func(imported *File) {
+		if imported == nil || m[imported] {
+			return
+		}
+		m[imported] = true
+		for _, imp := range imported.Imports {
+			if imp.Public {
+				addPublicImports(imp.File)
+			}
+		}
+	}
+	for _, imp := range f.Imports {
+		addPublicImports(imp.File)
+	}
+	r.visibleFiles[f] = m
+	return m
+}
+
+func joinName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
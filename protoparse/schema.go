@@ -0,0 +1,42 @@
+package protoparse
+
+import (
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// Schema returns easyproto.MessageSchema for m.
+//
+// Map fields are converted into repeated message fields with the map entry message schema.
+// The returned schema can be used for validating messages with easyproto.MessageSchema.Validate
+// or for checking marshaled messages with easyproto.Marshaler.SetSchema.
+func (m *Message) Schema() *easyproto.MessageSchema {
+	sb := &schemaBuilder{
+		schemas: make(map[*Message]*easyproto.MessageSchema),
+	}
+	return sb.build(m)
+}
+
+// schemaBuilder builds easyproto.MessageSchema for messages.
+type schemaBuilder struct {
+	// schemas contains already built schemas. It is needed for recursive messages.
+	schemas map[*Message]*easyproto.MessageSchema
+}
+
+func (sb *schemaBuilder) build(m *Message) *easyproto.MessageSchema {
+	if ms, ok := sb.schemas[m]; ok {
+		return ms
+	}
+	ms := easyproto.NewMessageSchema(m.FullName)
+	sb.schemas[m] = ms
+	for _, f := range m.Fields {
+		switch f.Kind {
+		case easyproto.KindMessage:
+			ms.MessageField(f.Num, f.Name, sb.build(f.Message), f.IsRepeated())
+		case easyproto.KindGroup:
+			ms.GroupField(f.Num, f.Name, sb.build(f.Message), f.IsRepeated())
+		default:
+			ms.Field(f.Num, f.Name, f.Kind, f.IsRepeated())
+		}
+	}
+	return ms
+}
@@ -0,0 +1,77 @@
+package protoparse
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+func TestMessageSchema(t *testing.T) {
+	file, err := Parse("foo.proto", []byte(testProto3))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ms := file.Messages[0].Schema()
+	if name := ms.Name(); name != "foo.bar.Timeseries" {
+		t.Fatalf("unexpected schema name; got %q; want %q", name, "foo.bar.Timeseries")
+	}
+
+	f := func(name string, kindExpected easyproto.Kind, repeatedExpected bool) {
+		t.Helper()
+
+		fs, ok := ms.FieldByName(name)
+		if !ok {
+			t.Fatalf("cannot find field %q in schema", name)
+		}
+		if fs.Kind != kindExpected {
+			t.Fatalf("unexpected kind; got %s; want %s", fs.Kind, kindExpected)
+		}
+		if fs.Repeated != repeatedExpected {
+			t.Fatalf("unexpected repeated; got %v; want %v", fs.Repeated, repeatedExpected)
+		}
+	}
+
+	f("name", easyproto.KindString, false)
+	f("samples", easyproto.KindMessage, true)
+	f("labels", easyproto.KindMessage, true)
+	f("ids", easyproto.KindUint32, true)
+	f("status", easyproto.KindEnum, false)
+	f("dv", easyproto.KindDouble, false)
+
+	// Recursive message must refer to itself.
+	labelsField, _ := ms.FieldByName("labels")
+	valueField, ok := labelsField.Message.FieldByName("value")
+	if !ok || valueField.Message == nil {
+		t.Fatalf("unexpected map value field: %+v", valueField)
+	}
+	nextField, ok := valueField.Message.FieldByName("next")
+	if !ok || nextField.Message != valueField.Message {
+		t.Fatalf("unexpected recursive field: %+v", nextField)
+	}
+
+	// Validate messages with the schema.
+	var m easyproto.Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mmSample := mm.AppendMessage(2)
+	mmSample.AppendDouble(1, 1.5)
+	mmSample.AppendInt64(2, 123)
+	mmEntry := mm.AppendMessage(3)
+	mmEntry.AppendString(1, "key")
+	mmLabel := mmEntry.AppendMessage(2)
+	mmLabel.AppendString(1, "value")
+	mm.AppendSint64s(5, []int64{-1, 2})
+	data := m.Marshal(nil)
+	if err := ms.Validate(data); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+
+	m.Reset()
+	mm = m.MessageMarshaler()
+	mmSample = mm.AppendMessage(2)
+	mmSample.AppendString(1, "invalid")
+	data = m.Marshal(nil)
+	if err := ms.Validate(data); err == nil {
+		t.Fatalf("expecting non-nil validation error")
+	}
+}
@@ -0,0 +1,25 @@
+package protoparse
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// wellKnownFiles contains .proto files for well-known types such as google/protobuf/timestamp.proto.
+//
+//go:embed wellknown
+var wellKnownFiles embed.FS
+
+// readWellKnownFile returns the contents of the well-known .proto file with the given import path, e.g. "google/protobuf/timestamp.proto".
+//
+// False is returned if there is no well-known file with the given path.
+func readWellKnownFile(path string) ([]byte, bool) {
+	if !fs.ValidPath(path) {
+		return nil, false
+	}
+	data, err := fs.ReadFile(wellKnownFiles, "wellknown/"+path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,110 @@
+package protoparse
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+func TestParseWellKnownFiles(t *testing.T) {
+	var names []string
+	err := fs.WalkDir(wellKnownFiles, "wellknown", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			names = append(names, strings.TrimPrefix(path, "wellknown/"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(names) == 0 {
+		t.Fatalf("missing well-known files")
+	}
+
+	// Well-known files must be parsed without Accessor and ImportPaths.
+	var p Parser
+	files, err := p.ParseFiles(names...)
+	if err != nil {
+		t.Fatalf("cannot parse well-known files: %s", err)
+	}
+	for _, f := range files {
+		if f.Package != "google.protobuf" {
+			t.Fatalf("unexpected package for %s; got %q; want %q", f.Name, f.Package, "google.protobuf")
+		}
+	}
+}
+
+func TestParseWellKnownImports(t *testing.T) {
+	src := `
+syntax = "proto3";
+package foo;
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/struct.proto";
+import "google/protobuf/descriptor.proto";
+
+extend google.protobuf.FieldOptions {
+	string custom = 50000;
+}
+
+message Event {
+	google.protobuf.Timestamp time = 1 [(custom) = "bar"];
+	google.protobuf.Struct attrs = 2;
+	repeated google.protobuf.Value values = 3;
+}
+`
+	file, err := Parse("foo.proto", []byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	m := file.Messages[0]
+	if tm := m.FieldByName("time").Message; tm == nil || tm.FullName != "google.protobuf.Timestamp" {
+		t.Fatalf("unexpected message for the time field: %+v", tm)
+	}
+
+	ms := m.Schema()
+	attrs, ok := ms.FieldByName("attrs")
+	if !ok || attrs.Kind != easyproto.KindMessage {
+		t.Fatalf("unexpected schema for the attrs field: %+v", attrs)
+	}
+	fields, ok := attrs.Message.FieldByName("fields")
+	if !ok || !fields.Repeated || fields.Kind != easyproto.KindMessage {
+		t.Fatalf("unexpected schema for Struct.fields: %+v", fields)
+	}
+	seconds, ok := m.FieldByName("time").Message.Schema().FieldByName("seconds")
+	if !ok || seconds.Kind != easyproto.KindInt64 {
+		t.Fatalf("unexpected schema for Timestamp.seconds: %+v", seconds)
+	}
+}
+
+func TestParseWellKnownOverride(t *testing.T) {
+	// Files at import paths take precedence over the embedded well-known files.
+	files := map[string]string{
+		"dir/google/protobuf/timestamp.proto": `syntax = "proto3"; package google.protobuf; message Timestamp { string custom = 1; }`,
+		"dir/foo.proto":                       `syntax = "proto3"; import "google/protobuf/timestamp.proto"; message Foo { google.protobuf.Timestamp t = 1; }`,
+	}
+	p := &Parser{
+		ImportPaths: []string{"dir"},
+		Accessor: func(path string) ([]byte, error) {
+			src, ok := files[path]
+			if !ok {
+				return nil, fmt.Errorf("cannot open %q: %w", path, os.ErrNotExist)
+			}
+			return []byte(src), nil
+		},
+	}
+	result, err := p.ParseFiles("foo.proto")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tm := result[0].Messages[0].FieldByName("t").Message
+	if tm.FieldByName("custom") == nil {
+		t.Fatalf("the Timestamp message must be read from import paths")
+	}
+}
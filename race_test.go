@@ -0,0 +1,8 @@
+//go:build race
+
+package easyproto
+
+// isRaceEnabled is set to true when tests are run with -race flag.
+//
+// Allocation tests must be skipped in this case, since the race detector makes sync.Pool to drop items randomly.
+const isRaceEnabled = true
@@ -2,6 +2,7 @@ package easyproto
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"sync"
@@ -14,13 +15,16 @@ type FieldContext struct {
 	FieldNum uint32
 
 	// wireType is the wire type for the given field
-	wireType wireType
+	wireType WireType
 
-	// data is probobuf-encoded field data for wireType=wireTypeLen
+	// data is probobuf-encoded field data for wireType=WireTypeLen and wireType=WireTypeSGroup
 	data []byte
 
-	// intValue contains int value for wireType!=wireTypeLen
+	// intValue contains int value for wireType=WireTypeVarint, wireType=WireTypeI64 and wireType=WireTypeI32
 	intValue uint64
+
+	// rawField contains the protobuf-encoded field including its tag
+	rawField []byte
 }
 
 func (fc *FieldContext) reset() {
@@ -31,6 +35,9 @@ func (fc *FieldContext) reset() {
 	fc.data = nil
 
 	fc.intValue = 0
+
+	// remove reference to external byte slice, so GC could release it.
+	fc.rawField = nil
 }
 
 // FieldByNum sets fc to the field with the given fieldNum at protobuf-encoded src.
@@ -41,18 +48,27 @@ func (fc *FieldContext) reset() {
 //
 // See also NextField().
 func (fc *FieldContext) FieldByNum(src []byte, fieldNum uint32) (bool, error) {
+	_, ok, err := fc.fieldByNum(src, fieldNum)
+	return ok, err
+}
+
+// fieldByNum sets fc to the field with the given fieldNum at src and returns the offset of this field at src.
+func (fc *FieldContext) fieldByNum(src []byte, fieldNum uint32) (int, bool, error) {
+	srcOrig := src
 	for len(src) > 0 {
 		var err error
+		tail := src
 		src, err = fc.NextField(src)
 		if err != nil {
-			return false, fmt.Errorf("cannot read the next field while searching for fieldNum=%d: %w", fieldNum, err)
+			addErrorOffset(err, len(srcOrig)-len(tail))
+			return 0, false, fmt.Errorf("cannot read the next field while searching for fieldNum=%d: %w", fieldNum, err)
 		}
 		if fc.FieldNum != fieldNum {
 			continue
 		}
-		return true, nil
+		return len(srcOrig) - len(tail), true, nil
 	}
-	return false, nil
+	return 0, false, nil
 }
 
 // NextField reads the next field from protobuf-encoded src.
@@ -61,28 +77,32 @@ func (fc *FieldContext) FieldByNum(src []byte, fieldNum uint32) (bool, error) {
 //
 // It is unsafe modifying src while FieldContext is in use.
 //
+// *DecodeError is returned if src contains invalid field.
+//
 // See also FieldByNum().
 func (fc *FieldContext) NextField(src []byte) ([]byte, error) {
+	srcOrig := src
 	if len(src) >= 2 {
 		n := uint16(src[0])<<8 | uint16(src[1])
-		if (n&0x8080 == 0) && (n&0x0700 == (uint16(wireTypeLen) << 8)) {
+		if (n&0x8080 == 0) && (n&0x0700 == (uint16(WireTypeLen) << 8)) {
 			// Fast path - read message with the length smaller than 0x80 bytes.
 			msgLen := int(n & 0xff)
 			src = src[2:]
 			if len(src) < msgLen {
-				return src, fmt.Errorf("cannot read field from %d bytes; need at least %d bytes", len(src), msgLen)
+				return src, newDecodeError(ErrTruncated, 0, uint32(n>>(8+3)), WireTypeLen, "cannot read field from %d bytes; need at least %d bytes", len(src), msgLen)
 			}
 			fc.FieldNum = uint32(n >> (8 + 3))
-			fc.wireType = wireTypeLen
+			fc.wireType = WireTypeLen
 			fc.data = src[:msgLen]
 			src = src[msgLen:]
+			fc.rawField = srcOrig[:len(srcOrig)-len(src)]
 			return src, nil
 		}
 	}
 
 	// Read field tag. See https://protobuf.dev/programming-guides/encoding/#structure
 	if len(src) == 0 {
-		return src, fmt.Errorf("cannot unmarshal field from empty message")
+		return src, newDecodeError(ErrTruncated, 0, 0, 0, "cannot unmarshal field from empty message")
 	}
 
 	var fieldNum uint64
@@ -94,62 +114,211 @@ func (fc *FieldContext) NextField(src []byte) ([]byte, error) {
 		var offset int
 		tag, offset = binary.Uvarint(src)
 		if offset <= 0 {
-			return src, fmt.Errorf("cannot unmarshal field tag from uvarint")
+			return src, newDecodeError(varintErrorKind(offset), 0, 0, 0, "cannot unmarshal field tag from uvarint")
 		}
 		src = src[offset:]
 		fieldNum = tag >> 3
 		if fieldNum > math.MaxUint32 {
-			return src, fmt.Errorf("fieldNum=%d is bigger than uint32max=%d", fieldNum, uint64(math.MaxUint32))
+			return src, newDecodeError(ErrOverflow, 0, 0, WireType(tag&0x07), "fieldNum=%d is bigger than uint32max=%d", fieldNum, uint64(math.MaxUint32))
 		}
 	}
 
-	wt := wireType(tag & 0x07)
+	wt := WireType(tag & 0x07)
 
 	fc.FieldNum = uint32(fieldNum)
 	fc.wireType = wt
 
 	// Read the remaining data
-	if wt == wireTypeLen {
+	if wt == WireTypeLen {
 		u64, offset := binary.Uvarint(src)
 		if offset <= 0 {
-			return src, fmt.Errorf("cannot read message length for field #%d", fieldNum)
+			return src, newDecodeError(varintErrorKind(offset), 0, fc.FieldNum, wt, "cannot read message length for field #%d", fieldNum)
 		}
 		src = src[offset:]
 		if uint64(len(src)) < u64 {
-			return src, fmt.Errorf("cannot read data for field #%d from %d bytes; need at least %d bytes", fieldNum, len(src), u64)
+			return src, newDecodeError(ErrTruncated, 0, fc.FieldNum, wt, "cannot read data for field #%d from %d bytes; need at least %d bytes", fieldNum, len(src), u64)
 		}
 		fc.data = src[:u64]
 		src = src[u64:]
+		fc.rawField = srcOrig[:len(srcOrig)-len(src)]
 		return src, nil
 	}
-	if wt == wireTypeVarint {
+	if wt == WireTypeVarint {
 		u64, offset := binary.Uvarint(src)
 		if offset <= 0 {
-			return src, fmt.Errorf("cannot read varint after field tag for field #%d", fieldNum)
+			return src, newDecodeError(varintErrorKind(offset), 0, fc.FieldNum, wt, "cannot read varint after field tag for field #%d", fieldNum)
 		}
 		src = src[offset:]
 		fc.intValue = u64
+		fc.rawField = srcOrig[:len(srcOrig)-len(src)]
 		return src, nil
 	}
-	if wt == wireTypeI64 {
+	if wt == WireTypeI64 {
 		if len(src) < 8 {
-			return src, fmt.Errorf("cannot read i64 for field #%d", fieldNum)
+			return src, newDecodeError(ErrTruncated, 0, fc.FieldNum, wt, "cannot read i64 for field #%d", fieldNum)
 		}
 		u64 := binary.LittleEndian.Uint64(src)
 		src = src[8:]
 		fc.intValue = u64
+		fc.rawField = srcOrig[:len(srcOrig)-len(src)]
 		return src, nil
 	}
-	if wt == wireTypeI32 {
+	if wt == WireTypeI32 {
 		if len(src) < 4 {
-			return src, fmt.Errorf("cannot read i32 for field #%d", fieldNum)
+			return src, newDecodeError(ErrTruncated, 0, fc.FieldNum, wt, "cannot read i32 for field #%d", fieldNum)
 		}
 		u32 := binary.LittleEndian.Uint32(src)
 		src = src[4:]
 		fc.intValue = uint64(u32)
+		fc.rawField = srcOrig[:len(srcOrig)-len(src)]
 		return src, nil
 	}
-	return src, fmt.Errorf("unknown wireType=%d", wt)
+	if wt == WireTypeSGroup {
+		data, tail, err := readGroup(src, fieldNum, 1)
+		if err != nil {
+			// Adjust the error offset to the start of the group field.
+			err.Offset += len(srcOrig) - len(src)
+			return src, err
+		}
+		fc.data = data
+		fc.rawField = srcOrig[:len(srcOrig)-len(tail)]
+		return tail, nil
+	}
+	if wt == WireTypeEGroup {
+		return src, newDecodeError(ErrBadGroup, 0, fc.FieldNum, wt, "unexpected end group tag for field #%d without the corresponding start group tag", fieldNum)
+	}
+	return src, newDecodeError(ErrBadWireType, 0, fc.FieldNum, wt, "unknown wireType=%d", wt)
+}
+
+// maxGroupDepth is the maximum nesting depth for proto2 groups.
+//
+// It protects from stack overflow when reading specially crafted messages.
+const maxGroupDepth = 100
+
+// readGroup reads the body of the group with the given fieldNum from src.
+//
+// src must point to the data after the start group tag.
+// It returns the group body without the end group tag and the tail left after the end group tag.
+//
+// The offset in the returned error is relative to the start of src.
+func readGroup(src []byte, fieldNum uint64, depth int) ([]byte, []byte, *DecodeError) {
+	body, tail, se := scanFields(src, fieldNum, depth, false)
+	if se.kind != nil {
+		return nil, tail, se.decodeError()
+	}
+	return body, tail, nil
+}
+
+// errZeroFieldNum is the kind of scanError for fields with zero field number.
+var errZeroFieldNum = errors.New("zero field number")
+
+// scanError describes an error found by scanFields.
+//
+// It is returned by value, so scanFields doesn't allocate memory on errors.
+type scanError struct {
+	// kind is the error kind. It is nil if there is no error.
+	kind error
+
+	// offset is the offset of the invalid field relative to the start of the scanned data.
+	offset int
+
+	// fieldNum is the number of the invalid field.
+	fieldNum uint32
+
+	// wt is the wire type of the invalid field.
+	wt WireType
+
+	// detail is a static description of the error.
+	detail string
+}
+
+func (se *scanError) decodeError() *DecodeError {
+	return newDecodeError(se.kind, se.offset, se.fieldNum, se.wt, "%s", se.detail)
+}
+
+// scanFields skips protobuf-encoded fields at src.
+//
+// If depth is zero, then all the fields at src are skipped and the whole src is returned as body.
+// Otherwise src must point to the body of the group with the given groupFieldNum after the start group tag,
+// and the group body without the end group tag is returned together with the tail left after the end group tag.
+// depth is the nesting depth of the group in this case.
+//
+// Fields with zero field numbers are rejected if rejectZeroFieldNum is set.
+//
+// In contrast to FieldContext.NextField(), scanFields doesn't allocate memory on errors,
+// so it is suitable for checking whether arbitrary data can be parsed as protobuf message.
+func scanFields(src []byte, groupFieldNum uint64, depth int, rejectZeroFieldNum bool) ([]byte, []byte, scanError) {
+	if depth > maxGroupDepth {
+		return nil, src, scanError{ErrBadGroup, 0, uint32(groupFieldNum), WireTypeSGroup, "too deep nesting of groups"}
+	}
+	body := src
+	for {
+		bodyLen := len(body) - len(src)
+		if len(src) == 0 {
+			if depth == 0 {
+				return body, src, scanError{}
+			}
+			return nil, src, scanError{ErrTruncated, bodyLen, uint32(groupFieldNum), WireTypeSGroup, "missing end group tag"}
+		}
+		tag, offset := binary.Uvarint(src)
+		if offset <= 0 {
+			return nil, src, scanError{varintErrorKind(offset), bodyLen, 0, 0, "cannot unmarshal field tag from uvarint"}
+		}
+		src = src[offset:]
+		wt := WireType(tag & 0x07)
+		if tag>>3 > math.MaxUint32 {
+			return nil, src, scanError{ErrOverflow, bodyLen, 0, wt, "fieldNum is bigger than uint32max"}
+		}
+		fieldNum := uint32(tag >> 3)
+		if fieldNum == 0 && rejectZeroFieldNum {
+			return nil, src, scanError{errZeroFieldNum, bodyLen, 0, wt, "fieldNum must be positive"}
+		}
+		switch wt {
+		case WireTypeVarint:
+			_, offset := binary.Uvarint(src)
+			if offset <= 0 {
+				return nil, src, scanError{varintErrorKind(offset), bodyLen, fieldNum, wt, "cannot read varint after field tag"}
+			}
+			src = src[offset:]
+		case WireTypeI64:
+			if len(src) < 8 {
+				return nil, src, scanError{ErrTruncated, bodyLen, fieldNum, wt, "cannot read i64"}
+			}
+			src = src[8:]
+		case WireTypeLen:
+			u64, offset := binary.Uvarint(src)
+			if offset <= 0 {
+				return nil, src, scanError{varintErrorKind(offset), bodyLen, fieldNum, wt, "cannot read message length"}
+			}
+			src = src[offset:]
+			if uint64(len(src)) < u64 {
+				return nil, src, scanError{ErrTruncated, bodyLen, fieldNum, wt, "cannot read length-delimited data"}
+			}
+			src = src[u64:]
+		case WireTypeI32:
+			if len(src) < 4 {
+				return nil, src, scanError{ErrTruncated, bodyLen, fieldNum, wt, "cannot read i32"}
+			}
+			src = src[4:]
+		case WireTypeSGroup:
+			var se scanError
+			_, src, se = scanFields(src, uint64(fieldNum), depth+1, rejectZeroFieldNum)
+			if se.kind != nil {
+				se.offset += bodyLen + offset
+				return nil, src, se
+			}
+		case WireTypeEGroup:
+			if depth == 0 {
+				return nil, src, scanError{ErrBadGroup, bodyLen, fieldNum, wt, "unexpected end group tag without the corresponding start group tag"}
+			}
+			if uint64(fieldNum) != groupFieldNum {
+				return nil, src, scanError{ErrBadGroup, bodyLen, fieldNum, wt, "unexpected end group tag for another field"}
+			}
+			return body[:bodyLen], src, scanError{}
+		default:
+			return nil, src, scanError{ErrBadWireType, bodyLen, fieldNum, wt, "unknown wireType"}
+		}
+	}
 }
 
 // UnmarshalMessageLen unmarshals protobuf message length from src.
@@ -171,45 +340,88 @@ func UnmarshalMessageLen(src []byte) (int, []byte, bool) {
 	return int(u64), src, true
 }
 
-// wireType is the type of of protobuf-encoded field
+// WireType is the type of protobuf-encoded field.
 //
 // See https://protobuf.dev/programming-guides/encoding/#structure
-type wireType byte
+type WireType byte
 
 const (
-	// VARINT type - one of int32, int64, uint32, uint64, sint32, sint64, bool, enum
-	wireTypeVarint = wireType(0)
+	// WireTypeVarint is VARINT type - one of int32, int64, uint32, uint64, sint32, sint64, bool, enum
+	WireTypeVarint = WireType(0)
 
-	// I64 type
-	wireTypeI64 = wireType(1)
+	// WireTypeI64 is I64 type - one of fixed64, sfixed64, double
+	WireTypeI64 = WireType(1)
 
-	// Len type
-	wireTypeLen = wireType(2)
+	// WireTypeLen is LEN type - one of string, bytes, embedded messages, packed repeated fields
+	WireTypeLen = WireType(2)
 
-	// I32 type
-	wireTypeI32 = wireType(5)
+	// WireTypeSGroup is SGROUP type - the start of proto2 group
+	WireTypeSGroup = WireType(3)
+
+	// WireTypeEGroup is EGROUP type - the end of proto2 group
+	WireTypeEGroup = WireType(4)
+
+	// WireTypeI32 is I32 type - one of fixed32, sfixed32, float
+	WireTypeI32 = WireType(5)
 )
 
-func (wt wireType) String() string {
+// String returns human-readable representation of wt.
+func (wt WireType) String() string {
 	switch wt {
-	case wireTypeVarint:
+	case WireTypeVarint:
 		return "varint"
-	case wireTypeI64:
+	case WireTypeI64:
 		return "i64"
-	case wireTypeLen:
+	case WireTypeLen:
 		return "len"
-	case wireTypeI32:
+	case WireTypeSGroup:
+		return "sgroup"
+	case WireTypeEGroup:
+		return "egroup"
+	case WireTypeI32:
 		return "i32"
 	default:
 		return fmt.Sprintf("unknown (%d)", int(wt))
 	}
 }
 
+// WireTypeError is returned when the field contains unexpected wire type.
+//
+// Get*, Get*ByPath functions and Extractor return it wrapped into DecodeError with ErrBadWireType kind,
+// which contains the offset of the field. Use errors.As(err, &wireTypeErr) for obtaining it.
+type WireTypeError struct {
+	// FieldNum is the number of the field with unexpected wire type.
+	FieldNum uint32
+
+	// Got is the wire type of the field.
+	Got WireType
+
+	// Want is the expected wire type for the field.
+	Want WireType
+}
+
+// Error implements error interface.
+func (e *WireTypeError) Error() string {
+	return fmt.Sprintf("fieldNum=%d contains unexpected wireType; got %s; want %s", e.FieldNum, e.Got, e.Want)
+}
+
+// Unwrap returns ErrBadWireType, so errors.Is(err, ErrBadWireType) works for WireTypeError.
+func (e *WireTypeError) Unwrap() error {
+	return ErrBadWireType
+}
+
+// WireType returns the wire type for fc.
+//
+// It can be used for distinguishing packed repeated fields (WireTypeLen) from scalar fields with the same fieldNum.
+func (fc *FieldContext) WireType() WireType {
+	return fc.wireType
+}
+
 // Int32 returns int32 value for fc.
 //
 // False is returned if fc doesn't contain int32 value.
 func (fc *FieldContext) Int32() (int32, bool) {
-	if fc.wireType != wireTypeVarint {
+	if fc.wireType != WireTypeVarint {
 		return 0, false
 	}
 	return getInt32(fc.intValue)
@@ -219,7 +431,7 @@ func (fc *FieldContext) Int32() (int32, bool) {
 //
 // False is returned if fc doesn't contain int64 value.
 func (fc *FieldContext) Int64() (int64, bool) {
-	if fc.wireType != wireTypeVarint {
+	if fc.wireType != WireTypeVarint {
 		return 0, false
 	}
 	return int64(fc.intValue), true
@@ -229,7 +441,7 @@ func (fc *FieldContext) Int64() (int64, bool) {
 //
 // False is returned if fc doesn't contain uint32 value.
 func (fc *FieldContext) Uint32() (uint32, bool) {
-	if fc.wireType != wireTypeVarint {
+	if fc.wireType != WireTypeVarint {
 		return 0, false
 	}
 	return getUint32(fc.intValue)
@@ -239,7 +451,7 @@ func (fc *FieldContext) Uint32() (uint32, bool) {
 //
 // False is returned if fc doesn't contain uint64 value.
 func (fc *FieldContext) Uint64() (uint64, bool) {
-	if fc.wireType != wireTypeVarint {
+	if fc.wireType != WireTypeVarint {
 		return 0, false
 	}
 	return fc.intValue, true
@@ -249,7 +461,7 @@ func (fc *FieldContext) Uint64() (uint64, bool) {
 //
 // False is returned if fc doesn't contain sint32 value.
 func (fc *FieldContext) Sint32() (int32, bool) {
-	if fc.wireType != wireTypeVarint {
+	if fc.wireType != WireTypeVarint {
 		return 0, false
 	}
 	u32, ok := getUint32(fc.intValue)
@@ -264,7 +476,7 @@ func (fc *FieldContext) Sint32() (int32, bool) {
 //
 // False is returned if fc doesn't contain sint64 value.
 func (fc *FieldContext) Sint64() (int64, bool) {
-	if fc.wireType != wireTypeVarint {
+	if fc.wireType != WireTypeVarint {
 		return 0, false
 	}
 	i64 := decodeZigZagInt64(fc.intValue)
@@ -275,7 +487,7 @@ func (fc *FieldContext) Sint64() (int64, bool) {
 //
 // False is returned in the second result if fc doesn't contain bool value.
 func (fc *FieldContext) Bool() (bool, bool) {
-	if fc.wireType != wireTypeVarint {
+	if fc.wireType != WireTypeVarint {
 		return false, false
 	}
 	return getBool(fc.intValue)
@@ -285,7 +497,7 @@ func (fc *FieldContext) Bool() (bool, bool) {
 //
 // False is returned if fc doesn't contain enum value.
 func (fc *FieldContext) Enum() (int32, bool) {
-	if fc.wireType != wireTypeVarint {
+	if fc.wireType != WireTypeVarint {
 		return 0, false
 	}
 	return getInt32(fc.intValue)
@@ -295,7 +507,7 @@ func (fc *FieldContext) Enum() (int32, bool) {
 //
 // False is returned if fc doesn't contain fixed64 value.
 func (fc *FieldContext) Fixed64() (uint64, bool) {
-	if fc.wireType != wireTypeI64 {
+	if fc.wireType != WireTypeI64 {
 		return 0, false
 	}
 	return fc.intValue, true
@@ -305,7 +517,7 @@ func (fc *FieldContext) Fixed64() (uint64, bool) {
 //
 // False is returned if fc doesn't contain sfixed64 value.
 func (fc *FieldContext) Sfixed64() (int64, bool) {
-	if fc.wireType != wireTypeI64 {
+	if fc.wireType != WireTypeI64 {
 		return 0, false
 	}
 	return int64(fc.intValue), true
@@ -315,7 +527,7 @@ func (fc *FieldContext) Sfixed64() (int64, bool) {
 //
 // False is returned if fc doesn't contain double value.
 func (fc *FieldContext) Double() (float64, bool) {
-	if fc.wireType != wireTypeI64 {
+	if fc.wireType != WireTypeI64 {
 		return 0, false
 	}
 	v := math.Float64frombits(fc.intValue)
@@ -328,7 +540,7 @@ func (fc *FieldContext) Double() (float64, bool) {
 //
 // False is returned if fc doesn't contain string value.
 func (fc *FieldContext) String() (string, bool) {
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return "", false
 	}
 	s := unsafeBytesToString(fc.data)
@@ -341,7 +553,7 @@ func (fc *FieldContext) String() (string, bool) {
 //
 // False is returned if fc doesn't contain bytes value.
 func (fc *FieldContext) Bytes() ([]byte, bool) {
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return nil, false
 	}
 	return fc.data, true
@@ -351,17 +563,40 @@ func (fc *FieldContext) Bytes() ([]byte, bool) {
 //
 // False is returned if fc doesn't contain message data.
 func (fc *FieldContext) MessageData() ([]byte, bool) {
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return nil, false
 	}
 	return fc.data, true
 }
 
+// GroupData returns proto2 group body for fc.
+//
+// The returned group body doesn't contain start and end group tags,
+// so it can be parsed with NextField() in the same way as MessageData().
+//
+// False is returned if fc doesn't contain group.
+func (fc *FieldContext) GroupData() ([]byte, bool) {
+	if fc.wireType != WireTypeSGroup {
+		return nil, false
+	}
+	return fc.data, true
+}
+
+// RawField returns protobuf-encoded field for fc including its tag.
+//
+// The returned field can be written verbatim to the output message via MessageMarshaler.AppendRawField().
+// This allows preserving unknown fields when re-encoding the message.
+//
+// The returned field refers to the src passed to NextField() or FieldByNum(), so it is valid while src isn't changed.
+func (fc *FieldContext) RawField() []byte {
+	return fc.rawField
+}
+
 // Fixed32 returns fixed32 value for fc.
 //
 // False is returned if fc doesn't contain fixed32 value.
 func (fc *FieldContext) Fixed32() (uint32, bool) {
-	if fc.wireType != wireTypeI32 {
+	if fc.wireType != WireTypeI32 {
 		return 0, false
 	}
 	u32 := mustGetUint32(fc.intValue)
@@ -372,7 +607,7 @@ func (fc *FieldContext) Fixed32() (uint32, bool) {
 //
 // False is returned if fc doesn't contain sfixed value.
 func (fc *FieldContext) Sfixed32() (int32, bool) {
-	if fc.wireType != wireTypeI32 {
+	if fc.wireType != WireTypeI32 {
 		return 0, false
 	}
 	i32 := mustGetInt32(fc.intValue)
@@ -383,7 +618,7 @@ func (fc *FieldContext) Sfixed32() (int32, bool) {
 //
 // False is returned if fc doesn't contain float value.
 func (fc *FieldContext) Float() (float32, bool) {
-	if fc.wireType != wireTypeI32 {
+	if fc.wireType != WireTypeI32 {
 		return 0, false
 	}
 	u32 := mustGetUint32(fc.intValue)
@@ -391,129 +626,314 @@ func (fc *FieldContext) Float() (float32, bool) {
 	return v, true
 }
 
+// PackedVarintCount returns the number of varint values at fc.
+//
+// It can be used for exact pre-allocation of the destination slice before unpacking
+// int32, int64, uint32, uint64, sint32, sint64, bool and enum values from fc.
+//
+// False is returned if fc doesn't contain varint values.
+func (fc *FieldContext) PackedVarintCount() (int, bool) {
+	if fc.wireType == WireTypeVarint {
+		return 1, true
+	}
+	if fc.wireType != WireTypeLen {
+		return 0, false
+	}
+	return packedVarintCount(fc.data), true
+}
+
+// packedVarintCount returns the number of varint values at src.
+//
+// Unpack* functions call it only when dst has no free capacity, since counting the values isn't free.
+func packedVarintCount(src []byte) int {
+	// Every varint ends with a byte without the continuation bit.
+	n := 0
+	for _, b := range src {
+		if b < 0x80 {
+			n++
+		}
+	}
+	return n
+}
+
+// PackedFixed64Count returns the number of 8-byte values at fc.
+//
+// It can be used for exact pre-allocation of the destination slice before unpacking
+// fixed64, sfixed64 and double values from fc.
+//
+// False is returned if fc doesn't contain 8-byte values.
+func (fc *FieldContext) PackedFixed64Count() (int, bool) {
+	if fc.wireType == WireTypeI64 {
+		return 1, true
+	}
+	if fc.wireType != WireTypeLen || len(fc.data)%8 != 0 {
+		return 0, false
+	}
+	return len(fc.data) / 8, true
+}
+
+// PackedFixed32Count returns the number of 4-byte values at fc.
+//
+// It can be used for exact pre-allocation of the destination slice before unpacking
+// fixed32, sfixed32 and float values from fc.
+//
+// False is returned if fc doesn't contain 4-byte values.
+func (fc *FieldContext) PackedFixed32Count() (int, bool) {
+	if fc.wireType == WireTypeI32 {
+		return 1, true
+	}
+	if fc.wireType != WireTypeLen || len(fc.data)%4 != 0 {
+		return 0, false
+	}
+	return len(fc.data) / 4, true
+}
+
 // UnpackInt32s unpacks int32 values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackInt32s(src []byte, fieldNum uint32, dst []int32) ([]int32, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackInt32s)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackInt32s, "int32", WireTypeVarint)
 }
 
 // UnpackInt32s unpacks int32 values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain int32 values.
+//
+// See also RangeInt32s.
 func (fc *FieldContext) UnpackInt32s(dst []int32) ([]int32, bool) {
-	if fc.wireType == wireTypeVarint {
-		i32, ok := getInt32(fc.intValue)
+	if fc.wireType == WireTypeVarint {
+		v, ok := getInt32(fc.intValue)
 		if !ok {
 			return dst, false
 		}
-		dst = append(dst, i32)
-		return dst, true
+		return append(dst, v), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
 	for len(src) > 0 {
-		u64, offset := binary.Uvarint(src)
+		if len(dst) == cap(dst) {
+			dst = growSlice(dst, packedVarintCount(src))
+		}
+		u64, offset := nextPackedVarint(src)
 		if offset <= 0 {
 			return dstOrig, false
 		}
 		src = src[offset:]
-		i32, ok := getInt32(u64)
+		v, ok := getInt32(u64)
 		if !ok {
 			return dstOrig, false
 		}
-		dst = append(dst, i32)
+		dst = append(dst, v)
 	}
 	return dst, true
 }
 
+// RangeInt32s calls f for every int32 value at fc.
+//
+// The iteration stops when f returns false. This allows processing int32 values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain int32 values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackInt32s.
+func (fc *FieldContext) RangeInt32s(f func(v int32) bool) bool {
+	if fc.wireType == WireTypeVarint {
+		v, ok := getInt32(fc.intValue)
+		if !ok {
+			return false
+		}
+		f(v)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u64, offset := nextPackedVarint(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		v, ok := getInt32(u64)
+		if !ok {
+			return false
+		}
+		if !f(v) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackInt64s unpacks int64 values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackInt64s(src []byte, fieldNum uint32, dst []int64) ([]int64, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackInt64s)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackInt64s, "int64", WireTypeVarint)
 }
 
 // UnpackInt64s unpacks int64 values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain int64 values.
+//
+// See also RangeInt64s.
 func (fc *FieldContext) UnpackInt64s(dst []int64) ([]int64, bool) {
-	if fc.wireType == wireTypeVarint {
-		dst = append(dst, int64(fc.intValue))
-		return dst, true
+	if fc.wireType == WireTypeVarint {
+		v := int64(fc.intValue)
+		return append(dst, v), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
 	for len(src) > 0 {
-		u64, offset := binary.Uvarint(src)
+		if len(dst) == cap(dst) {
+			dst = growSlice(dst, packedVarintCount(src))
+		}
+		u64, offset := nextPackedVarint(src)
 		if offset <= 0 {
 			return dstOrig, false
 		}
 		src = src[offset:]
-		dst = append(dst, int64(u64))
+		v := int64(u64)
+		dst = append(dst, v)
 	}
 	return dst, true
 }
 
+// RangeInt64s calls f for every int64 value at fc.
+//
+// The iteration stops when f returns false. This allows processing int64 values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain int64 values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackInt64s.
+func (fc *FieldContext) RangeInt64s(f func(v int64) bool) bool {
+	if fc.wireType == WireTypeVarint {
+		v := int64(fc.intValue)
+		f(v)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u64, offset := nextPackedVarint(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		v := int64(u64)
+		if !f(v) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackUint32s unpacks uint32 values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackUint32s(src []byte, fieldNum uint32, dst []uint32) ([]uint32, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackUint32s)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackUint32s, "uint32", WireTypeVarint)
 }
 
 // UnpackUint32s unpacks uint32 values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain uint32 values.
+//
+// See also RangeUint32s.
 func (fc *FieldContext) UnpackUint32s(dst []uint32) ([]uint32, bool) {
-	if fc.wireType == wireTypeVarint {
-		u32, ok := getUint32(fc.intValue)
+	if fc.wireType == WireTypeVarint {
+		v, ok := getUint32(fc.intValue)
 		if !ok {
 			return dst, false
 		}
-		dst = append(dst, u32)
-		return dst, true
+		return append(dst, v), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
 	for len(src) > 0 {
-		u64, offset := binary.Uvarint(src)
+		if len(dst) == cap(dst) {
+			dst = growSlice(dst, packedVarintCount(src))
+		}
+		u64, offset := nextPackedVarint(src)
 		if offset <= 0 {
 			return dstOrig, false
 		}
 		src = src[offset:]
-		u32, ok := getUint32(u64)
+		v, ok := getUint32(u64)
 		if !ok {
 			return dstOrig, false
 		}
-		dst = append(dst, u32)
+		dst = append(dst, v)
 	}
 	return dst, true
 }
 
+// RangeUint32s calls f for every uint32 value at fc.
+//
+// The iteration stops when f returns false. This allows processing uint32 values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain uint32 values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackUint32s.
+func (fc *FieldContext) RangeUint32s(f func(v uint32) bool) bool {
+	if fc.wireType == WireTypeVarint {
+		v, ok := getUint32(fc.intValue)
+		if !ok {
+			return false
+		}
+		f(v)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u64, offset := nextPackedVarint(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		v, ok := getUint32(u64)
+		if !ok {
+			return false
+		}
+		if !f(v) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackUint64s unpacks uint64 values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackUint64s(src []byte, fieldNum uint32, dst []uint64) ([]uint64, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackUint64s)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackUint64s, "uint64", WireTypeVarint)
 }
 
 // UnpackUint64s unpacks uint64 values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain uint64 values.
+//
+// See also RangeUint64s.
 func (fc *FieldContext) UnpackUint64s(dst []uint64) ([]uint64, bool) {
-	if fc.wireType == wireTypeVarint {
-		dst = append(dst, fc.intValue)
-		return dst, true
+	if fc.wireType == WireTypeVarint {
+		return append(dst, fc.intValue), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
 	for len(src) > 0 {
-		u64, offset := binary.Uvarint(src)
+		if len(dst) == cap(dst) {
+			dst = growSlice(dst, packedVarintCount(src))
+		}
+		u64, offset := nextPackedVarint(src)
 		if offset <= 0 {
 			return dstOrig, false
 		}
@@ -523,318 +943,708 @@ func (fc *FieldContext) UnpackUint64s(dst []uint64) ([]uint64, bool) {
 	return dst, true
 }
 
+// RangeUint64s calls f for every uint64 value at fc.
+//
+// The iteration stops when f returns false. This allows processing uint64 values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain uint64 values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackUint64s.
+func (fc *FieldContext) RangeUint64s(f func(v uint64) bool) bool {
+	if fc.wireType == WireTypeVarint {
+		f(fc.intValue)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u64, offset := nextPackedVarint(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		if !f(u64) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackSint32s unpacks sint32 values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackSint32s(src []byte, fieldNum uint32, dst []int32) ([]int32, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackSint32s)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackSint32s, "sint32", WireTypeVarint)
 }
 
 // UnpackSint32s unpacks sint32 values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain sint32 values.
+//
+// See also RangeSint32s.
 func (fc *FieldContext) UnpackSint32s(dst []int32) ([]int32, bool) {
-	if fc.wireType == wireTypeVarint {
-		u32, ok := getUint32(fc.intValue)
+	if fc.wireType == WireTypeVarint {
+		v, ok := getSint32(fc.intValue)
 		if !ok {
 			return dst, false
 		}
-		i32 := decodeZigZagInt32(u32)
-		dst = append(dst, i32)
-		return dst, true
+		return append(dst, v), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
 	for len(src) > 0 {
-		u64, offset := binary.Uvarint(src)
+		if len(dst) == cap(dst) {
+			dst = growSlice(dst, packedVarintCount(src))
+		}
+		u64, offset := nextPackedVarint(src)
 		if offset <= 0 {
 			return dstOrig, false
 		}
 		src = src[offset:]
-		u32, ok := getUint32(u64)
+		v, ok := getSint32(u64)
 		if !ok {
 			return dstOrig, false
 		}
-		i32 := decodeZigZagInt32(u32)
-		dst = append(dst, i32)
+		dst = append(dst, v)
 	}
 	return dst, true
 }
 
+// RangeSint32s calls f for every sint32 value at fc.
+//
+// The iteration stops when f returns false. This allows processing sint32 values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain sint32 values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackSint32s.
+func (fc *FieldContext) RangeSint32s(f func(v int32) bool) bool {
+	if fc.wireType == WireTypeVarint {
+		v, ok := getSint32(fc.intValue)
+		if !ok {
+			return false
+		}
+		f(v)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u64, offset := nextPackedVarint(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		v, ok := getSint32(u64)
+		if !ok {
+			return false
+		}
+		if !f(v) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackSint64s unpacks sint64 values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackSint64s(src []byte, fieldNum uint32, dst []int64) ([]int64, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackSint64s)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackSint64s, "sint64", WireTypeVarint)
 }
 
 // UnpackSint64s unpacks sint64 values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain sint64 values.
+//
+// See also RangeSint64s.
 func (fc *FieldContext) UnpackSint64s(dst []int64) ([]int64, bool) {
-	if fc.wireType == wireTypeVarint {
-		i64 := decodeZigZagInt64(fc.intValue)
-		dst = append(dst, i64)
-		return dst, true
+	if fc.wireType == WireTypeVarint {
+		v := decodeZigZagInt64(fc.intValue)
+		return append(dst, v), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
 	for len(src) > 0 {
-		u64, offset := binary.Uvarint(src)
+		if len(dst) == cap(dst) {
+			dst = growSlice(dst, packedVarintCount(src))
+		}
+		u64, offset := nextPackedVarint(src)
 		if offset <= 0 {
 			return dstOrig, false
 		}
 		src = src[offset:]
-		i64 := decodeZigZagInt64(u64)
-		dst = append(dst, i64)
+		v := decodeZigZagInt64(u64)
+		dst = append(dst, v)
 	}
 	return dst, true
 }
 
+// RangeSint64s calls f for every sint64 value at fc.
+//
+// The iteration stops when f returns false. This allows processing sint64 values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain sint64 values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackSint64s.
+func (fc *FieldContext) RangeSint64s(f func(v int64) bool) bool {
+	if fc.wireType == WireTypeVarint {
+		v := decodeZigZagInt64(fc.intValue)
+		f(v)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u64, offset := nextPackedVarint(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		v := decodeZigZagInt64(u64)
+		if !f(v) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackBools unpacks bool values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackBools(src []byte, fieldNum uint32, dst []bool) ([]bool, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackBools)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackBools, "bool", WireTypeVarint)
 }
 
 // UnpackBools unpacks bool values from fc, appends them to dst and returns the result.
 //
 // False is returned in the second result if fc doesn't contain bool values.
+//
+// See also RangeBools.
 func (fc *FieldContext) UnpackBools(dst []bool) ([]bool, bool) {
-	if fc.wireType == wireTypeVarint {
+	if fc.wireType == WireTypeVarint {
 		v, ok := getBool(fc.intValue)
 		if !ok {
 			return dst, false
 		}
-		dst = append(dst, v)
-		return dst, true
+		return append(dst, v), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
 	for len(src) > 0 {
-		u64, offset := binary.Uvarint(src)
+		if len(dst) == cap(dst) {
+			dst = growSlice(dst, packedVarintCount(src))
+		}
+		u64, offset := nextPackedVarint(src)
 		if offset <= 0 {
 			return dstOrig, false
 		}
 		src = src[offset:]
 		v, ok := getBool(u64)
 		if !ok {
-			return dst, false
+			return dstOrig, false
 		}
 		dst = append(dst, v)
 	}
 	return dst, true
 }
 
+// RangeBools calls f for every bool value at fc.
+//
+// The iteration stops when f returns false. This allows processing bool values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain bool values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackBools.
+func (fc *FieldContext) RangeBools(f func(v bool) bool) bool {
+	if fc.wireType == WireTypeVarint {
+		v, ok := getBool(fc.intValue)
+		if !ok {
+			return false
+		}
+		f(v)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u64, offset := nextPackedVarint(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		v, ok := getBool(u64)
+		if !ok {
+			return false
+		}
+		if !f(v) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackFixed64s unpacks fixed64 values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackFixed64s(src []byte, fieldNum uint32, dst []uint64) ([]uint64, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackFixed64s)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackFixed64s, "fixed64", WireTypeI64)
 }
 
 // UnpackFixed64s unpacks fixed64 values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain fixed64 values.
+//
+// See also RangeFixed64s.
 func (fc *FieldContext) UnpackFixed64s(dst []uint64) ([]uint64, bool) {
-	if fc.wireType == wireTypeI64 {
-		u64 := fc.intValue
-		dst = append(dst, u64)
-		return dst, true
+	if fc.wireType == WireTypeI64 {
+		return append(dst, fc.intValue), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
+	dst = growSlice(dst, len(src)/8)
 	for len(src) > 0 {
-		if len(src) < 8 {
+		u64, offset := nextPackedFixed64(src)
+		if offset <= 0 {
 			return dstOrig, false
 		}
-		u64 := binary.LittleEndian.Uint64(src)
-		src = src[8:]
+		src = src[offset:]
 		dst = append(dst, u64)
 	}
 	return dst, true
 }
 
+// RangeFixed64s calls f for every fixed64 value at fc.
+//
+// The iteration stops when f returns false. This allows processing fixed64 values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain fixed64 values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackFixed64s.
+func (fc *FieldContext) RangeFixed64s(f func(v uint64) bool) bool {
+	if fc.wireType == WireTypeI64 {
+		f(fc.intValue)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u64, offset := nextPackedFixed64(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		if !f(u64) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackSfixed64s unpacks sfixed64 values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackSfixed64s(src []byte, fieldNum uint32, dst []int64) ([]int64, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackSfixed64s)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackSfixed64s, "sfixed64", WireTypeI64)
 }
 
 // UnpackSfixed64s unpacks sfixed64 values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain sfixed64 values.
+//
+// See also RangeSfixed64s.
 func (fc *FieldContext) UnpackSfixed64s(dst []int64) ([]int64, bool) {
-	if fc.wireType == wireTypeI64 {
-		u64 := fc.intValue
-		dst = append(dst, int64(u64))
-		return dst, true
+	if fc.wireType == WireTypeI64 {
+		v := int64(fc.intValue)
+		return append(dst, v), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
+	dst = growSlice(dst, len(src)/8)
 	for len(src) > 0 {
-		if len(src) < 8 {
+		u64, offset := nextPackedFixed64(src)
+		if offset <= 0 {
 			return dstOrig, false
 		}
-		u64 := binary.LittleEndian.Uint64(src)
-		src = src[8:]
-		dst = append(dst, int64(u64))
+		src = src[offset:]
+		v := int64(u64)
+		dst = append(dst, v)
 	}
 	return dst, true
 }
 
+// RangeSfixed64s calls f for every sfixed64 value at fc.
+//
+// The iteration stops when f returns false. This allows processing sfixed64 values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain sfixed64 values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackSfixed64s.
+func (fc *FieldContext) RangeSfixed64s(f func(v int64) bool) bool {
+	if fc.wireType == WireTypeI64 {
+		v := int64(fc.intValue)
+		f(v)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u64, offset := nextPackedFixed64(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		v := int64(u64)
+		if !f(v) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackDoubles unpacks double values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackDoubles(src []byte, fieldNum uint32, dst []float64) ([]float64, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackDoubles)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackDoubles, "double", WireTypeI64)
 }
 
 // UnpackDoubles unpacks double values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain double values.
+//
+// See also RangeDoubles.
 func (fc *FieldContext) UnpackDoubles(dst []float64) ([]float64, bool) {
-	if fc.wireType == wireTypeI64 {
+	if fc.wireType == WireTypeI64 {
 		v := math.Float64frombits(fc.intValue)
-		dst = append(dst, v)
-		return dst, true
+		return append(dst, v), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
+	dst = growSlice(dst, len(src)/8)
 	for len(src) > 0 {
-		if len(src) < 8 {
+		u64, offset := nextPackedFixed64(src)
+		if offset <= 0 {
 			return dstOrig, false
 		}
-		u64 := binary.LittleEndian.Uint64(src)
-		src = src[8:]
+		src = src[offset:]
 		v := math.Float64frombits(u64)
 		dst = append(dst, v)
 	}
 	return dst, true
 }
 
+// RangeDoubles calls f for every double value at fc.
+//
+// The iteration stops when f returns false. This allows processing double values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain double values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackDoubles.
+func (fc *FieldContext) RangeDoubles(f func(v float64) bool) bool {
+	if fc.wireType == WireTypeI64 {
+		v := math.Float64frombits(fc.intValue)
+		f(v)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u64, offset := nextPackedFixed64(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		v := math.Float64frombits(u64)
+		if !f(v) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackFixed32s unpacks fixed32 values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackFixed32s(src []byte, fieldNum uint32, dst []uint32) ([]uint32, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackFixed32s)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackFixed32s, "fixed32", WireTypeI32)
 }
 
 // UnpackFixed32s unpacks fixed32 values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain fixed32 values.
+//
+// See also RangeFixed32s.
 func (fc *FieldContext) UnpackFixed32s(dst []uint32) ([]uint32, bool) {
-	if fc.wireType == wireTypeI32 {
-		u32 := mustGetUint32(fc.intValue)
-		dst = append(dst, u32)
-		return dst, true
+	if fc.wireType == WireTypeI32 {
+		return append(dst, mustGetUint32(fc.intValue)), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
+	dst = growSlice(dst, len(src)/4)
 	for len(src) > 0 {
-		if len(src) < 4 {
+		u32, offset := nextPackedFixed32(src)
+		if offset <= 0 {
 			return dstOrig, false
 		}
-		u32 := binary.LittleEndian.Uint32(src)
-		src = src[4:]
+		src = src[offset:]
 		dst = append(dst, u32)
 	}
 	return dst, true
 }
 
+// RangeFixed32s calls f for every fixed32 value at fc.
+//
+// The iteration stops when f returns false. This allows processing fixed32 values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain fixed32 values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackFixed32s.
+func (fc *FieldContext) RangeFixed32s(f func(v uint32) bool) bool {
+	if fc.wireType == WireTypeI32 {
+		f(mustGetUint32(fc.intValue))
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u32, offset := nextPackedFixed32(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		if !f(u32) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackSfixed32s unpacks sfixed32 values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackSfixed32s(src []byte, fieldNum uint32, dst []int32) ([]int32, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackSfixed32s)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackSfixed32s, "sfixed32", WireTypeI32)
 }
 
 // UnpackSfixed32s unpacks sfixed32 values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain sfixed32 values.
+//
+// See also RangeSfixed32s.
 func (fc *FieldContext) UnpackSfixed32s(dst []int32) ([]int32, bool) {
-	if fc.wireType == wireTypeI32 {
-		i32 := mustGetInt32(fc.intValue)
-		dst = append(dst, i32)
-		return dst, true
+	if fc.wireType == WireTypeI32 {
+		v := int32(mustGetUint32(fc.intValue))
+		return append(dst, v), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
+	dst = growSlice(dst, len(src)/4)
 	for len(src) > 0 {
-		if len(src) < 4 {
+		u32, offset := nextPackedFixed32(src)
+		if offset <= 0 {
 			return dstOrig, false
 		}
-		u32 := binary.LittleEndian.Uint32(src)
-		src = src[4:]
-		dst = append(dst, int32(u32))
+		src = src[offset:]
+		v := int32(u32)
+		dst = append(dst, v)
 	}
 	return dst, true
 }
 
+// RangeSfixed32s calls f for every sfixed32 value at fc.
+//
+// The iteration stops when f returns false. This allows processing sfixed32 values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain sfixed32 values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackSfixed32s.
+func (fc *FieldContext) RangeSfixed32s(f func(v int32) bool) bool {
+	if fc.wireType == WireTypeI32 {
+		v := int32(mustGetUint32(fc.intValue))
+		f(v)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u32, offset := nextPackedFixed32(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		v := int32(u32)
+		if !f(v) {
+			return true
+		}
+	}
+	return true
+}
+
 // UnpackFloats unpacks float values from protobuf-encoded fields at src with the given fieldNum, appends them to dst and returns the result.
 func UnpackFloats(src []byte, fieldNum uint32, dst []float32) ([]float32, error) {
-	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackFloats)
+	return unpackArray(src, fieldNum, dst, (*FieldContext).UnpackFloats, "float", WireTypeI32)
 }
 
 // UnpackFloats unpacks float values from fc, appends them to dst and returns the result.
 //
 // False is returned if fc doesn't contain float values.
+//
+// See also RangeFloats.
 func (fc *FieldContext) UnpackFloats(dst []float32) ([]float32, bool) {
-	if fc.wireType == wireTypeI32 {
-		u32 := mustGetUint32(fc.intValue)
-		v := math.Float32frombits(u32)
-		dst = append(dst, v)
-		return dst, true
+	if fc.wireType == WireTypeI32 {
+		v := math.Float32frombits(mustGetUint32(fc.intValue))
+		return append(dst, v), true
 	}
-	if fc.wireType != wireTypeLen {
+	if fc.wireType != WireTypeLen {
 		return dst, false
 	}
 	src := fc.data
 	dstOrig := dst
+	dst = growSlice(dst, len(src)/4)
 	for len(src) > 0 {
-		if len(src) < 4 {
+		u32, offset := nextPackedFixed32(src)
+		if offset <= 0 {
 			return dstOrig, false
 		}
-		u32 := binary.LittleEndian.Uint32(src)
-		src = src[4:]
+		src = src[offset:]
 		v := math.Float32frombits(u32)
 		dst = append(dst, v)
 	}
 	return dst, true
 }
 
-func (fc *FieldContext) getField(src []byte, fieldNum uint32, neededWireType wireType) (bool, error) {
-	ok, err := fc.FieldByNum(src, fieldNum)
+// RangeFloats calls f for every float value at fc.
+//
+// The iteration stops when f returns false. This allows processing float values without the need to unpack them into a slice.
+//
+// False is returned if fc doesn't contain float values. In this case f may be already called for some values before the invalid value is detected.
+//
+// See also UnpackFloats.
+func (fc *FieldContext) RangeFloats(f func(v float32) bool) bool {
+	if fc.wireType == WireTypeI32 {
+		v := math.Float32frombits(mustGetUint32(fc.intValue))
+		f(v)
+		return true
+	}
+	if fc.wireType != WireTypeLen {
+		return false
+	}
+	src := fc.data
+	for len(src) > 0 {
+		u32, offset := nextPackedFixed32(src)
+		if offset <= 0 {
+			return false
+		}
+		src = src[offset:]
+		v := math.Float32frombits(u32)
+		if !f(v) {
+			return true
+		}
+	}
+	return true
+}
+
+// nextPackedVarint decodes the first varint from packed varints at src and returns it with the number of bytes read.
+//
+// The number of bytes read is zero or negative if src doesn't start with a valid varint - see binary.Uvarint for details.
+//
+// Range* and Unpack* functions for varint kinds decode packed values with this function, so they handle invalid data identically.
+// The function is small enough to be inlined into their loops.
+func nextPackedVarint(src []byte) (uint64, int) {
+	var u64 uint64
+	for i, b := range src {
+		if i == binary.MaxVarintLen64 {
+			return 0, -(i + 1)
+		}
+		if b < 0x80 {
+			if i == binary.MaxVarintLen64-1 && b > 1 {
+				return 0, -(i + 1)
+			}
+			return u64 | uint64(b)<<(7*i), i + 1
+		}
+		u64 |= uint64(b&0x7f) << (7 * i)
+	}
+	return 0, 0
+}
+
+// nextPackedFixed64 decodes the first 8-byte value from packed values at src and returns it with the number of bytes read.
+//
+// Zero bytes read is returned if src contains less than 8 bytes.
+//
+// See also nextPackedVarint.
+func nextPackedFixed64(src []byte) (uint64, int) {
+	if len(src) < 8 {
+		return 0, 0
+	}
+	return binary.LittleEndian.Uint64(src), 8
+}
+
+// nextPackedFixed32 decodes the first 4-byte value from packed values at src and returns it with the number of bytes read.
+//
+// Zero bytes read is returned if src contains less than 4 bytes.
+//
+// See also nextPackedVarint.
+func nextPackedFixed32(src []byte) (uint32, int) {
+	if len(src) < 4 {
+		return 0, 0
+	}
+	return binary.LittleEndian.Uint32(src), 4
+}
+
+// getField sets fc to the field with the given fieldNum and neededWireType at src.
+//
+// It returns the offset of the found field at src.
+func (fc *FieldContext) getField(src []byte, fieldNum uint32, neededWireType WireType) (int, bool, error) {
+	offset, ok, err := fc.fieldByNum(src, fieldNum)
 	if err != nil {
-		return false, err
+		return 0, false, err
 	}
 	if !ok {
-		return false, nil
+		return 0, false, nil
 	}
 	if fc.wireType != neededWireType {
-		return false, fmt.Errorf("fieldNum=%d contains unexpected wireType; got %s; want %s", fieldNum, fc.wireType, neededWireType)
+		return offset, false, newWireTypeError(offset, fieldNum, fc.wireType, neededWireType)
 	}
-	return true, nil
+	return offset, true, nil
 }
 
-func unpackArray[T any](src []byte, fieldNum uint32, dst []T, unpackFunc func(fc *FieldContext, dst []T) ([]T, bool)) ([]T, error) {
+func unpackArray[T any](src []byte, fieldNum uint32, dst []T, unpackFunc func(fc *FieldContext, dst []T) ([]T, bool), typeName string, wt WireType) ([]T, error) {
 	fc := getFieldContext()
 	defer putFieldContext(fc)
 
+	srcOrig := src
 	for len(src) > 0 {
 		var err error
+		tail := src
 		src, err = fc.NextField(src)
 		if err != nil {
+			addErrorOffset(err, len(srcOrig)-len(tail))
 			return dst, fmt.Errorf("cannot read the next field while searching for fieldNum=%d: %w", fieldNum, err)
 		}
 		if fc.FieldNum != fieldNum {
@@ -844,12 +1654,48 @@ func unpackArray[T any](src []byte, fieldNum uint32, dst []T, unpackFunc func(fc
 		var ok bool
 		dst, ok = unpackFunc(fc, dst)
 		if !ok {
-			return dst, fmt.Errorf("cannot unpack bools from field with fieldNum=%d", fieldNum)
+			kind := fc.unpackErrorKind(wt)
+			return dst, newDecodeError(kind, len(srcOrig)-len(tail), fieldNum, fc.wireType, "cannot unpack %s values from field with fieldNum=%d", typeName, fieldNum)
 		}
 	}
 	return dst, nil
 }
 
+// unpackErrorKind returns DecodeError kind for fc, which cannot be unpacked into values with the given scalar wire type.
+func (fc *FieldContext) unpackErrorKind(wt WireType) error {
+	if fc.wireType == wt {
+		// Scalar value doesn't fit the destination type.
+		return ErrOverflow
+	}
+	if fc.wireType != WireTypeLen {
+		return ErrBadWireType
+	}
+	switch wt {
+	case WireTypeVarint:
+		src := fc.data
+		for len(src) > 0 {
+			_, offset := binary.Uvarint(src)
+			if offset <= 0 {
+				return varintErrorKind(offset)
+			}
+			src = src[offset:]
+		}
+		// All the varints are valid, so some of them do not fit the destination type.
+		return ErrOverflow
+	default:
+		// Packed fixed-width values have invalid length.
+		return ErrTruncated
+	}
+}
+
+// growSlice makes sure dst has enough capacity for appending n items without re-allocation.
+func growSlice[T any](dst []T, n int) []T {
+	if n <= cap(dst)-len(dst) {
+		return dst
+	}
+	return append(dst[:cap(dst)], make([]T, n-(cap(dst)-len(dst)))...)[:len(dst)]
+}
+
 func getFieldContext() *FieldContext {
 	v := fieldContextPool.Get()
 	if v == nil {
@@ -873,7 +1719,7 @@ var fieldContextPool sync.Pool
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetInt32(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeVarint)
+	offset, ok, err := fc.getField(src, fieldNum, WireTypeVarint)
 	if err != nil {
 		return 0, false, err
 	}
@@ -882,7 +1728,7 @@ func GetInt32(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 	}
 	n, ok = getInt32(fc.intValue)
 	if !ok {
-		return 0, false, fmt.Errorf("fieldNum=%d contains too big integer %d, which cannot be converted to int32", fieldNum, fc.intValue)
+		return 0, false, newDecodeError(ErrOverflow, offset, fieldNum, fc.wireType, "fieldNum=%d contains too big integer %d, which cannot be converted to int32", fieldNum, fc.intValue)
 	}
 	return n, true, nil
 }
@@ -895,7 +1741,7 @@ func GetInt32(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetInt64(src []byte, fieldNum uint32) (n int64, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeVarint)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeVarint)
 	if err != nil {
 		return 0, false, err
 	}
@@ -913,7 +1759,7 @@ func GetInt64(src []byte, fieldNum uint32) (n int64, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetUint32(src []byte, fieldNum uint32) (n uint32, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeVarint)
+	offset, ok, err := fc.getField(src, fieldNum, WireTypeVarint)
 	if err != nil {
 		return 0, false, err
 	}
@@ -922,7 +1768,7 @@ func GetUint32(src []byte, fieldNum uint32) (n uint32, ok bool, err error) {
 	}
 	n, ok = getUint32(fc.intValue)
 	if !ok {
-		return 0, false, fmt.Errorf("fieldNum=%d contains too big integer %d, which cannot be converted to uint32", fieldNum, fc.intValue)
+		return 0, false, newDecodeError(ErrOverflow, offset, fieldNum, fc.wireType, "fieldNum=%d contains too big integer %d, which cannot be converted to uint32", fieldNum, fc.intValue)
 	}
 	return n, true, nil
 }
@@ -935,7 +1781,7 @@ func GetUint32(src []byte, fieldNum uint32) (n uint32, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetUint64(src []byte, fieldNum uint32) (n uint64, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeVarint)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeVarint)
 	if err != nil {
 		return 0, false, err
 	}
@@ -953,7 +1799,7 @@ func GetUint64(src []byte, fieldNum uint32) (n uint64, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetSint32(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeVarint)
+	offset, ok, err := fc.getField(src, fieldNum, WireTypeVarint)
 	if err != nil {
 		return 0, false, err
 	}
@@ -962,7 +1808,7 @@ func GetSint32(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 	}
 	u32, ok := getUint32(fc.intValue)
 	if !ok {
-		return 0, false, fmt.Errorf("fieldNum=%d contains too big integer %d, which cannot be converted to uint32", fieldNum, fc.intValue)
+		return 0, false, newDecodeError(ErrOverflow, offset, fieldNum, fc.wireType, "fieldNum=%d contains too big integer %d, which cannot be converted to uint32", fieldNum, fc.intValue)
 	}
 	n = decodeZigZagInt32(u32)
 	return n, true, nil
@@ -976,7 +1822,7 @@ func GetSint32(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetSint64(src []byte, fieldNum uint32) (n int64, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeVarint)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeVarint)
 	if err != nil {
 		return 0, false, err
 	}
@@ -995,7 +1841,7 @@ func GetSint64(src []byte, fieldNum uint32) (n int64, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetBool(src []byte, fieldNum uint32) (b bool, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeVarint)
+	offset, ok, err := fc.getField(src, fieldNum, WireTypeVarint)
 	if err != nil {
 		return false, false, err
 	}
@@ -1004,7 +1850,7 @@ func GetBool(src []byte, fieldNum uint32) (b bool, ok bool, err error) {
 	}
 	b, ok = getBool(fc.intValue)
 	if !ok {
-		return false, false, fmt.Errorf("fieldNum=%d contains invalid integer %d, which cannot be converted to bool", fieldNum, fc.intValue)
+		return false, false, newDecodeError(ErrOverflow, offset, fieldNum, fc.wireType, "fieldNum=%d contains invalid integer %d, which cannot be converted to bool", fieldNum, fc.intValue)
 	}
 	return b, true, nil
 }
@@ -1017,7 +1863,7 @@ func GetBool(src []byte, fieldNum uint32) (b bool, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetEnum(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeVarint)
+	offset, ok, err := fc.getField(src, fieldNum, WireTypeVarint)
 	if err != nil {
 		return 0, false, err
 	}
@@ -1026,7 +1872,7 @@ func GetEnum(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 	}
 	n, ok = getInt32(fc.intValue)
 	if !ok {
-		return 0, false, fmt.Errorf("fieldNum=%d contains invalid integer %d, which cannot be converted to enum", fieldNum, fc.intValue)
+		return 0, false, newDecodeError(ErrOverflow, offset, fieldNum, fc.wireType, "fieldNum=%d contains invalid integer %d, which cannot be converted to enum", fieldNum, fc.intValue)
 	}
 	return n, true, nil
 }
@@ -1039,7 +1885,7 @@ func GetEnum(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetFixed64(src []byte, fieldNum uint32) (n uint64, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeI64)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeI64)
 	if err != nil {
 		return 0, false, err
 	}
@@ -1057,7 +1903,7 @@ func GetFixed64(src []byte, fieldNum uint32) (n uint64, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetSfixed64(src []byte, fieldNum uint32) (n int64, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeI64)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeI64)
 	if err != nil {
 		return 0, false, err
 	}
@@ -1075,7 +1921,7 @@ func GetSfixed64(src []byte, fieldNum uint32) (n int64, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetDouble(src []byte, fieldNum uint32) (f float64, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeI64)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeI64)
 	if err != nil {
 		return 0, false, err
 	}
@@ -1095,7 +1941,7 @@ func GetDouble(src []byte, fieldNum uint32) (f float64, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetString(src []byte, fieldNum uint32) (s string, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeLen)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeLen)
 	if err != nil {
 		return "", false, err
 	}
@@ -1114,7 +1960,7 @@ func GetString(src []byte, fieldNum uint32) (s string, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetBytes(src []byte, fieldNum uint32) (b []byte, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeLen)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeLen)
 	if err != nil {
 		return nil, false, err
 	}
@@ -1133,7 +1979,7 @@ func GetBytes(src []byte, fieldNum uint32) (b []byte, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetMessageData(src []byte, fieldNum uint32) (data []byte, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeLen)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeLen)
 	if err != nil {
 		return nil, false, err
 	}
@@ -1151,7 +1997,7 @@ func GetMessageData(src []byte, fieldNum uint32) (data []byte, ok bool, err erro
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetFixed32(src []byte, fieldNum uint32) (n uint32, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeI32)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeI32)
 	if err != nil {
 		return 0, false, err
 	}
@@ -1170,7 +2016,7 @@ func GetFixed32(src []byte, fieldNum uint32) (n uint32, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetSfixed32(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeI32)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeI32)
 	if err != nil {
 		return 0, false, err
 	}
@@ -1189,7 +2035,7 @@ func GetSfixed32(src []byte, fieldNum uint32) (n int32, ok bool, err error) {
 // Otherwise use FieldContext for obtaining multiple message from protobuf-encoded src.
 func GetFloat(src []byte, fieldNum uint32) (f float32, ok bool, err error) {
 	var fc FieldContext
-	ok, err = fc.getField(src, fieldNum, wireTypeI32)
+	_, ok, err = fc.getField(src, fieldNum, WireTypeI32)
 	if err != nil {
 		return 0, false, err
 	}
@@ -1214,11 +2060,19 @@ func unsafeBytesToString(b []byte) string {
 }
 
 func getInt32(u64 uint64) (int32, bool) {
-	u32, ok := getUint32(u64)
-	if !ok {
+	if u64 <= math.MaxUint32 {
+		// Legacy 5-byte encoding for negative values, which was produced by older easyproto versions.
+		return int32(u64), true
+	}
+
+	// Negative int32 values must be sign-extended to 64 bits according to protobuf spec.
+	// See https://protobuf.dev/programming-guides/encoding/#signed-ints
+	// Other values above uint32max do not fit int32.
+	i64 := int64(u64)
+	if i64 < math.MinInt32 || i64 >= 0 {
 		return 0, false
 	}
-	return int32(u32), true
+	return int32(i64), true
 }
 
 func getUint32(u64 uint64) (uint32, bool) {
@@ -1228,6 +2082,14 @@ func getUint32(u64 uint64) (uint32, bool) {
 	return uint32(u64), true
 }
 
+func getSint32(u64 uint64) (int32, bool) {
+	u32, ok := getUint32(u64)
+	if !ok {
+		return 0, false
+	}
+	return decodeZigZagInt32(u32), true
+}
+
 func mustGetInt32(u64 uint64) int32 {
 	u32 := mustGetUint32(u64)
 	return int32(u32)
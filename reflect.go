@@ -0,0 +1,801 @@
+package easyproto
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Marshal marshals v into protobuf message, appends it to dst and returns the result.
+//
+// v must be a struct or a pointer to struct. Struct fields are marshaled according to their `proto` tags
+// with the following format: `proto:"<fieldNum>[,<kind>][,packed]"`, for example:
+//
+//	type Sample struct {
+//		Value     float64 `proto:"1"`
+//		Timestamp int64   `proto:"2,sint64"`
+//		Labels    []int32 `proto:"3,sint32,packed"`
+//	}
+//
+// <kind> is the protobuf kind as returned by Kind.String(). It is detected from the Go type if it is missing:
+// int32, int64, uint32, uint64, bool, float32, float64, string and []byte are marshaled as the protobuf kinds
+// with the same names, while int and uint are marshaled as int64 and uint64. Structs are marshaled as embedded messages,
+// while group kind must be set explicitly for them if they must be marshaled as groups.
+// Fields without `proto` tag are ignored.
+//
+// Slices are marshaled as repeated fields. They are marshaled in packed form if packed option is set.
+// Pointers are marshaled only if they aren't nil, so they can be used for optional fields.
+// Other fields are marshaled only if they contain non-zero values according to proto3 spec.
+// Maps are marshaled as repeated map entries sorted by keys, so the result is deterministic.
+//
+// Marshal uses reflection, so it is slower than the code built on top of MessageMarshaler.
+// It caches the marshaling plan per each Go type, so the struct tags are parsed only once.
+func Marshal(dst []byte, v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return dst, fmt.Errorf("cannot marshal nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return dst, fmt.Errorf("cannot marshal %T; it must be a struct or a pointer to struct", v)
+	}
+	sp, err := getStructPlan(rv.Type())
+	if err != nil {
+		return dst, err
+	}
+	m := reflectMarshalerPool.Get()
+	sp.marshal(m.MessageMarshaler(), rv)
+	dst = m.Marshal(dst)
+	reflectMarshalerPool.Put(m)
+	return dst, nil
+}
+
+var reflectMarshalerPool MarshalerPool
+
+// Unmarshal unmarshals protobuf message at src into v.
+//
+// v must be a non-nil pointer to struct with `proto` field tags. See Marshal for details on the tags format.
+//
+// Fields with `proto` tags are reset before unmarshaling, while other fields remain unchanged.
+// Unknown fields in src are skipped. Strings and byte slices are copied, so v doesn't refer to src after the return.
+//
+// Multiple occurrences of a non-repeated message field are merged into a single value according to protobuf spec.
+// The nesting depth for messages and groups is limited by 100 in order to protect from stack overflow on specially crafted messages.
+func Unmarshal(src []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal into %T; it must be a non-nil pointer to struct", v)
+	}
+	rv = rv.Elem()
+	sp, err := getStructPlan(rv.Type())
+	if err != nil {
+		return err
+	}
+	sp.reset(rv)
+	return sp.unmarshal(src, rv, 1)
+}
+
+// maxUnmarshalDepth is the maximum nesting depth for messages and groups in Unmarshal.
+const maxUnmarshalDepth = 100
+
+// structPlan contains marshaling and unmarshaling plan for a struct type.
+type structPlan struct {
+	typ    reflect.Type
+	fields []fieldPlan
+
+	// fieldIdxs maps field numbers to indexes at fields.
+	fieldIdxs map[uint32]int
+}
+
+// containerKind is the kind of Go container holding field values.
+type containerKind int
+
+const (
+	containerNone containerKind = iota
+	containerPointer
+	containerSlice
+	containerMap
+)
+
+// fieldPlan contains marshaling and unmarshaling plan for a struct field.
+type fieldPlan struct {
+	name      string
+	index     int
+	num       uint32
+	container containerKind
+	packed    bool
+
+	// value is the plan for field values. It is the plan for map values for map fields.
+	value valuePlan
+
+	// key is the plan for map keys.
+	key valuePlan
+}
+
+// valuePlan describes a single value.
+type valuePlan struct {
+	kind Kind
+
+	// isPointer is set to true for pointers to structs inside slices and maps.
+	isPointer bool
+
+	// msg is the plan for message and group values.
+	msg *structPlan
+}
+
+func (vp *valuePlan) isMessage() bool {
+	return vp.kind == KindMessage || vp.kind == KindGroup
+}
+
+var (
+	structPlans     sync.Map
+	structPlansLock sync.Mutex
+)
+
+// getStructPlan returns the plan for the struct type t.
+func getStructPlan(t reflect.Type) (*structPlan, error) {
+	if v, ok := structPlans.Load(t); ok {
+		return v.(*structPlan), nil
+	}
+
+	structPlansLock.Lock()
+	defer structPlansLock.Unlock()
+
+	// Plans for recursive types refer to each other, so they are published only after all of them are built successfully.
+	building := make(map[reflect.Type]*structPlan)
+	sp, err := newStructPlan(t, building)
+	if err != nil {
+		return nil, err
+	}
+	for t, p := range building {
+		structPlans.Store(t, p)
+	}
+	return sp, nil
+}
+
+func newStructPlan(t reflect.Type, building map[reflect.Type]*structPlan) (*structPlan, error) {
+	if v, ok := structPlans.Load(t); ok {
+		return v.(*structPlan), nil
+	}
+	if sp, ok := building[t]; ok {
+		return sp, nil
+	}
+	sp := &structPlan{
+		typ:       t,
+		fieldIdxs: make(map[uint32]int),
+	}
+	building[t] = sp
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("proto")
+		if !ok {
+			continue
+		}
+		fp, err := newFieldPlan(sf, tag, building)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use %s.%s field: %w", t, sf.Name, err)
+		}
+		fp.index = i
+		if idx, ok := sp.fieldIdxs[fp.num]; ok {
+			return nil, fmt.Errorf("fields %s.%s and %s.%s have the same number %d", t, sp.fields[idx].name, t, fp.name, fp.num)
+		}
+		sp.fieldIdxs[fp.num] = len(sp.fields)
+		sp.fields = append(sp.fields, fp)
+	}
+	return sp, nil
+}
+
+func newFieldPlan(sf reflect.StructField, tag string, building map[reflect.Type]*structPlan) (fieldPlan, error) {
+	fp := fieldPlan{
+		name: sf.Name,
+	}
+	if !sf.IsExported() {
+		return fp, fmt.Errorf("unexported fields cannot have `proto` tag")
+	}
+	parts := strings.Split(tag, ",")
+	num, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || num == 0 || num > maxFieldNum {
+		return fp, fmt.Errorf("invalid field number %q in `proto` tag; it must be in the range [1..%d]", parts[0], maxFieldNum)
+	}
+	fp.num = uint32(num)
+	var kind Kind
+	for _, part := range parts[1:] {
+		if part == "packed" {
+			fp.packed = true
+			continue
+		}
+		k, ok := kindByName(part)
+		if !ok {
+			return fp, fmt.Errorf("unknown option %q in `proto` tag", part)
+		}
+		kind = k
+	}
+
+	t := sf.Type
+	switch {
+	case t.Kind() == reflect.Map:
+		if kind != 0 || fp.packed {
+			return fp, fmt.Errorf("map fields cannot have kind or packed options")
+		}
+		fp.container = containerMap
+		if fp.key, err = newValuePlan(t.Key(), 0, building); err != nil {
+			return fp, fmt.Errorf("unsupported map key: %w", err)
+		}
+		switch fp.key.kind {
+		case KindMessage, KindGroup, KindBytes, KindDouble, KindFloat:
+			return fp, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		if fp.value, err = newValuePlan(t.Elem(), 0, building); err != nil {
+			return fp, fmt.Errorf("unsupported map value: %w", err)
+		}
+		return fp, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		fp.container = containerSlice
+		t = t.Elem()
+	case t.Kind() == reflect.Pointer:
+		fp.container = containerPointer
+		t = t.Elem()
+		if t.Kind() == reflect.Slice {
+			return fp, fmt.Errorf("pointers to slices aren't supported")
+		}
+	}
+	if fp.value, err = newValuePlan(t, kind, building); err != nil {
+		return fp, err
+	}
+	if fp.container == containerPointer && fp.value.isPointer {
+		return fp, fmt.Errorf("pointers to pointers aren't supported")
+	}
+	if fp.packed && (fp.container != containerSlice || !fp.value.kind.IsPackable()) {
+		return fp, fmt.Errorf("packed option can be used only for repeated fields with scalar numeric kinds")
+	}
+	return fp, nil
+}
+
+// newValuePlan returns the plan for values of type t with the given kind.
+//
+// The kind is detected from t if it is zero.
+func newValuePlan(t reflect.Type, kind Kind, building map[reflect.Type]*structPlan) (valuePlan, error) {
+	var vp valuePlan
+	if t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct {
+		vp.isPointer = true
+		t = t.Elem()
+	}
+	if kind == 0 {
+		kind = defaultKindForType(t)
+		if kind == 0 {
+			return vp, fmt.Errorf("unsupported Go type %s", t)
+		}
+	}
+	vp.kind = kind
+	if vp.isMessage() {
+		if t.Kind() != reflect.Struct {
+			return vp, fmt.Errorf("%s kind requires struct; got %s", kind, t)
+		}
+		sp, err := newStructPlan(t, building)
+		if err != nil {
+			return vp, err
+		}
+		vp.msg = sp
+		return vp, nil
+	}
+	if vp.isPointer || !isCompatibleKind(kind, t) {
+		return vp, fmt.Errorf("%s kind cannot be used for Go type %s", kind, t)
+	}
+	return vp, nil
+}
+
+// defaultKindForType returns the default protobuf kind for Go type t.
+func defaultKindForType(t reflect.Type) Kind {
+	switch t.Kind() {
+	case reflect.Int32:
+		return KindInt32
+	case reflect.Int64, reflect.Int:
+		return KindInt64
+	case reflect.Uint32:
+		return KindUint32
+	case reflect.Uint64, reflect.Uint:
+		return KindUint64
+	case reflect.Bool:
+		return KindBool
+	case reflect.Float64:
+		return KindDouble
+	case reflect.Float32:
+		return KindFloat
+	case reflect.String:
+		return KindString
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return KindBytes
+		}
+	case reflect.Struct:
+		return KindMessage
+	}
+	return 0
+}
+
+// isCompatibleKind returns true if values of the given kind can be stored in Go type t without overflow.
+func isCompatibleKind(kind Kind, t reflect.Type) bool {
+	switch kind {
+	case KindInt32, KindSint32, KindSfixed32, KindEnum:
+		return t.Kind() == reflect.Int32
+	case KindInt64, KindSint64, KindSfixed64:
+		return t.Kind() == reflect.Int64 || t.Kind() == reflect.Int
+	case KindUint32, KindFixed32:
+		return t.Kind() == reflect.Uint32
+	case KindUint64, KindFixed64:
+		return t.Kind() == reflect.Uint64 || t.Kind() == reflect.Uint
+	case KindBool:
+		return t.Kind() == reflect.Bool
+	case KindDouble:
+		return t.Kind() == reflect.Float64
+	case KindFloat:
+		return t.Kind() == reflect.Float32
+	case KindString:
+		return t.Kind() == reflect.String
+	case KindBytes:
+		return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+	default:
+		return false
+	}
+}
+
+// kindByName returns the kind for the given name as returned by Kind.String().
+func kindByName(name string) (Kind, bool) {
+	for k := KindInt32; k <= KindFloat; k++ {
+		if k.String() == name {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+func (sp *structPlan) marshal(mm *MessageMarshaler, rv reflect.Value) {
+	for i := range sp.fields {
+		fp := &sp.fields[i]
+		fp.marshal(mm, rv.Field(fp.index))
+	}
+}
+
+func (fp *fieldPlan) marshal(mm *MessageMarshaler, fv reflect.Value) {
+	switch fp.container {
+	case containerMap:
+		if fv.Len() == 0 {
+			return
+		}
+		keys := fv.MapKeys()
+		sortMapKeys(keys)
+		for _, k := range keys {
+			mmEntry := mm.AppendMessage(fp.num)
+			fp.key.marshal(mmEntry, MapEntryKeyFieldNum, k)
+			fp.value.marshal(mmEntry, MapEntryValueFieldNum, fv.MapIndex(k))
+		}
+	case containerSlice:
+		n := fv.Len()
+		if n == 0 {
+			return
+		}
+		if fp.packed {
+			fp.value.marshalPacked(mm, fp.num, fv)
+			return
+		}
+		for i := 0; i < n; i++ {
+			fp.value.marshal(mm, fp.num, fv.Index(i))
+		}
+	case containerPointer:
+		if !fv.IsNil() {
+			fp.value.marshal(mm, fp.num, fv.Elem())
+		}
+	default:
+		if !fv.IsZero() {
+			fp.value.marshal(mm, fp.num, fv)
+		}
+	}
+}
+
+// marshal appends v under the given fieldNum to mm.
+func (vp *valuePlan) marshal(mm *MessageMarshaler, fieldNum uint32, v reflect.Value) {
+	if vp.isPointer {
+		if v.IsNil() {
+			// Nil messages inside slices and maps are marshaled as empty messages.
+			v = reflect.Zero(v.Type().Elem())
+		} else {
+			v = v.Elem()
+		}
+	}
+	switch vp.kind {
+	case KindInt32, KindEnum:
+		mm.AppendInt32(fieldNum, int32(v.Int()))
+	case KindInt64:
+		mm.AppendInt64(fieldNum, v.Int())
+	case KindUint32:
+		mm.AppendUint32(fieldNum, uint32(v.Uint()))
+	case KindUint64:
+		mm.AppendUint64(fieldNum, v.Uint())
+	case KindSint32:
+		mm.AppendSint32(fieldNum, int32(v.Int()))
+	case KindSint64:
+		mm.AppendSint64(fieldNum, v.Int())
+	case KindBool:
+		mm.AppendBool(fieldNum, v.Bool())
+	case KindFixed64:
+		mm.AppendFixed64(fieldNum, v.Uint())
+	case KindSfixed64:
+		mm.AppendSfixed64(fieldNum, v.Int())
+	case KindDouble:
+		mm.AppendDouble(fieldNum, v.Float())
+	case KindString:
+		mm.AppendString(fieldNum, v.String())
+	case KindBytes:
+		mm.AppendBytes(fieldNum, v.Bytes())
+	case KindMessage:
+		vp.msg.marshal(mm.AppendMessage(fieldNum), v)
+	case KindGroup:
+		vp.msg.marshal(mm.AppendGroup(fieldNum), v)
+	case KindFixed32:
+		mm.AppendFixed32(fieldNum, uint32(v.Uint()))
+	case KindSfixed32:
+		mm.AppendSfixed32(fieldNum, int32(v.Int()))
+	case KindFloat:
+		mm.AppendFloat(fieldNum, float32(v.Float()))
+	default:
+		panic(fmt.Errorf("BUG: unexpected kind %s", vp.kind))
+	}
+}
+
+// marshalPacked appends values from the slice sv in packed form under the given fieldNum to mm.
+//
+// The values are marshaled with MessageMarshaler.Append*s methods.
+func (vp *valuePlan) marshalPacked(mm *MessageMarshaler, fieldNum uint32, sv reflect.Value) {
+	switch vp.kind {
+	case KindInt32, KindEnum:
+		mm.AppendInt32s(fieldNum, packedValues[int32](sv))
+	case KindInt64:
+		mm.AppendInt64s(fieldNum, packedValues[int64](sv))
+	case KindUint32:
+		mm.AppendUint32s(fieldNum, packedValues[uint32](sv))
+	case KindUint64:
+		mm.AppendUint64s(fieldNum, packedValues[uint64](sv))
+	case KindSint32:
+		mm.AppendSint32s(fieldNum, packedValues[int32](sv))
+	case KindSint64:
+		mm.AppendSint64s(fieldNum, packedValues[int64](sv))
+	case KindBool:
+		mm.AppendBools(fieldNum, packedValues[bool](sv))
+	case KindFixed64:
+		mm.AppendFixed64s(fieldNum, packedValues[uint64](sv))
+	case KindSfixed64:
+		mm.AppendSfixed64s(fieldNum, packedValues[int64](sv))
+	case KindDouble:
+		mm.AppendDoubles(fieldNum, packedValues[float64](sv))
+	case KindFixed32:
+		mm.AppendFixed32s(fieldNum, packedValues[uint32](sv))
+	case KindSfixed32:
+		mm.AppendSfixed32s(fieldNum, packedValues[int32](sv))
+	case KindFloat:
+		mm.AppendFloats(fieldNum, packedValues[float32](sv))
+	default:
+		panic(fmt.Errorf("BUG: unexpected packed kind %s", vp.kind))
+	}
+}
+
+// packedValues returns values from the slice sv as []T.
+//
+// sv is returned as is if it has []T type. Otherwise its values are converted to T, e.g. for []int or slices of named enum types.
+func packedValues[T any](sv reflect.Value) []T {
+	if vs, ok := sv.Interface().([]T); ok {
+		return vs
+	}
+	vs := make([]T, sv.Len())
+	rv := reflect.ValueOf(vs)
+	elemType := rv.Type().Elem()
+	for i := range vs {
+		rv.Index(i).Set(sv.Index(i).Convert(elemType))
+	}
+	return vs
+}
+
+// sortMapKeys sorts map keys with integer, bool or string types.
+func sortMapKeys(keys []reflect.Value) {
+	if len(keys) < 2 {
+		return
+	}
+	var less func(a, b reflect.Value) bool
+	switch keys[0].Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		less = func(a, b reflect.Value) bool { return a.Int() < b.Int() }
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		less = func(a, b reflect.Value) bool { return a.Uint() < b.Uint() }
+	case reflect.Bool:
+		less = func(a, b reflect.Value) bool { return !a.Bool() && b.Bool() }
+	default:
+		less = func(a, b reflect.Value) bool { return a.String() < b.String() }
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return less(keys[i], keys[j])
+	})
+}
+
+func (sp *structPlan) reset(rv reflect.Value) {
+	for i := range sp.fields {
+		fp := &sp.fields[i]
+		fv := rv.Field(fp.index)
+		switch fp.container {
+		case containerMap:
+			if !fv.IsNil() {
+				for _, k := range fv.MapKeys() {
+					fv.SetMapIndex(k, reflect.Value{})
+				}
+			}
+		case containerSlice:
+			fv.SetLen(0)
+		default:
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+	}
+}
+
+// unmarshal merges the message at src into rv.
+//
+// depth is the nesting depth of the message, where the top-level message has depth 1.
+func (sp *structPlan) unmarshal(src []byte, rv reflect.Value, depth int) (err error) {
+	if depth > maxUnmarshalDepth {
+		return fmt.Errorf("too deep nesting of messages in %s; max depth is %d", sp.typ, maxUnmarshalDepth)
+	}
+
+	var fc FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in %s: %w", sp.typ, err)
+		}
+		idx, ok := sp.fieldIdxs[fc.FieldNum]
+		if !ok {
+			// Skip unknown field
+			continue
+		}
+		fp := &sp.fields[idx]
+		if err := fp.unmarshal(&fc, rv.Field(fp.index), depth); err != nil {
+			return fmt.Errorf("cannot unmarshal %s.%s: %w", sp.typ, fp.name, err)
+		}
+	}
+	return nil
+}
+
+func (fp *fieldPlan) unmarshal(fc *FieldContext, fv reflect.Value, depth int) error {
+	switch fp.container {
+	case containerMap:
+		key, value, err := fc.MapEntry()
+		if err != nil {
+			return err
+		}
+		if fv.IsNil() {
+			fv.Set(reflect.MakeMap(fv.Type()))
+		}
+		kv := reflect.New(fv.Type().Key()).Elem()
+		if key.FieldNum != 0 {
+			if err := fp.key.unmarshal(&key, kv, depth); err != nil {
+				return fmt.Errorf("cannot read map key: %w", err)
+			}
+		}
+		vv := reflect.New(fv.Type().Elem()).Elem()
+		if fp.value.isMessage() || value.FieldNum != 0 {
+			if err := fp.value.unmarshal(&value, vv, depth); err != nil {
+				return fmt.Errorf("cannot read map value: %w", err)
+			}
+		}
+		fv.SetMapIndex(kv, vv)
+		return nil
+	case containerSlice:
+		if fp.value.kind.IsPackable() {
+			return fp.value.unmarshalPacked(fc, fv)
+		}
+		return fp.value.unmarshal(fc, appendSliceElem(fv), depth)
+	case containerPointer:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return fp.value.unmarshal(fc, fv.Elem(), depth)
+	default:
+		return fp.value.unmarshal(fc, fv, depth)
+	}
+}
+
+// unmarshal reads a single value from fc into v.
+//
+// Messages and groups are merged into v. depth is the nesting depth of the message containing fc.
+func (vp *valuePlan) unmarshal(fc *FieldContext, v reflect.Value, depth int) error {
+	if vp.isPointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	ok := true
+	switch vp.kind {
+	case KindInt32:
+		var x int32
+		x, ok = fc.Int32()
+		v.SetInt(int64(x))
+	case KindInt64:
+		var x int64
+		x, ok = fc.Int64()
+		v.SetInt(x)
+	case KindUint32:
+		var x uint32
+		x, ok = fc.Uint32()
+		v.SetUint(uint64(x))
+	case KindUint64:
+		var x uint64
+		x, ok = fc.Uint64()
+		v.SetUint(x)
+	case KindSint32:
+		var x int32
+		x, ok = fc.Sint32()
+		v.SetInt(int64(x))
+	case KindSint64:
+		var x int64
+		x, ok = fc.Sint64()
+		v.SetInt(x)
+	case KindBool:
+		var x bool
+		x, ok = fc.Bool()
+		v.SetBool(x)
+	case KindEnum:
+		var x int32
+		x, ok = fc.Enum()
+		v.SetInt(int64(x))
+	case KindFixed64:
+		var x uint64
+		x, ok = fc.Fixed64()
+		v.SetUint(x)
+	case KindSfixed64:
+		var x int64
+		x, ok = fc.Sfixed64()
+		v.SetInt(x)
+	case KindDouble:
+		var x float64
+		x, ok = fc.Double()
+		v.SetFloat(x)
+	case KindString:
+		var x string
+		x, ok = fc.String()
+		v.SetString(string(append([]byte(nil), x...)))
+	case KindBytes:
+		var x []byte
+		x, ok = fc.Bytes()
+		v.SetBytes(append(v.Bytes()[:0], x...))
+	case KindMessage, KindGroup:
+		var data []byte
+		if fc.FieldNum != 0 {
+			if vp.kind == KindGroup {
+				data, ok = fc.GroupData()
+			} else {
+				data, ok = fc.MessageData()
+			}
+		}
+		if ok {
+			return vp.msg.unmarshal(data, v, depth+1)
+		}
+	case KindFixed32:
+		var x uint32
+		x, ok = fc.Fixed32()
+		v.SetUint(uint64(x))
+	case KindSfixed32:
+		var x int32
+		x, ok = fc.Sfixed32()
+		v.SetInt(int64(x))
+	case KindFloat:
+		var x float32
+		x, ok = fc.Float()
+		v.SetFloat(float64(x))
+	default:
+		panic(fmt.Errorf("BUG: unexpected kind %s", vp.kind))
+	}
+	if !ok {
+		return fmt.Errorf("cannot read %s value for wireType=%s", vp.kind, fc.WireType())
+	}
+	return nil
+}
+
+// unmarshalPacked appends values from fc to the slice sv.
+//
+// fc may contain either a single value or packed values.
+func (vp *valuePlan) unmarshalPacked(fc *FieldContext, sv reflect.Value) error {
+	var ok bool
+	switch vp.kind {
+	case KindInt32:
+		ok = fc.RangeInt32s(func(x int32) bool {
+			appendSliceElem(sv).SetInt(int64(x))
+			return true
+		})
+	case KindInt64:
+		ok = fc.RangeInt64s(func(x int64) bool {
+			appendSliceElem(sv).SetInt(x)
+			return true
+		})
+	case KindUint32:
+		ok = fc.RangeUint32s(func(x uint32) bool {
+			appendSliceElem(sv).SetUint(uint64(x))
+			return true
+		})
+	case KindUint64:
+		ok = fc.RangeUint64s(func(x uint64) bool {
+			appendSliceElem(sv).SetUint(x)
+			return true
+		})
+	case KindSint32:
+		ok = fc.RangeSint32s(func(x int32) bool {
+			appendSliceElem(sv).SetInt(int64(x))
+			return true
+		})
+	case KindSint64:
+		ok = fc.RangeSint64s(func(x int64) bool {
+			appendSliceElem(sv).SetInt(x)
+			return true
+		})
+	case KindBool:
+		ok = fc.RangeBools(func(x bool) bool {
+			appendSliceElem(sv).SetBool(x)
+			return true
+		})
+	case KindEnum:
+		ok = fc.RangeInt32s(func(x int32) bool {
+			appendSliceElem(sv).SetInt(int64(x))
+			return true
+		})
+	case KindFixed64:
+		ok = fc.RangeFixed64s(func(x uint64) bool {
+			appendSliceElem(sv).SetUint(x)
+			return true
+		})
+	case KindSfixed64:
+		ok = fc.RangeSfixed64s(func(x int64) bool {
+			appendSliceElem(sv).SetInt(x)
+			return true
+		})
+	case KindDouble:
+		ok = fc.RangeDoubles(func(x float64) bool {
+			appendSliceElem(sv).SetFloat(x)
+			return true
+		})
+	case KindFixed32:
+		ok = fc.RangeFixed32s(func(x uint32) bool {
+			appendSliceElem(sv).SetUint(uint64(x))
+			return true
+		})
+	case KindSfixed32:
+		ok = fc.RangeSfixed32s(func(x int32) bool {
+			appendSliceElem(sv).SetInt(int64(x))
+			return true
+		})
+	case KindFloat:
+		ok = fc.RangeFloats(func(x float32) bool {
+			appendSliceElem(sv).SetFloat(float64(x))
+			return true
+		})
+	default:
+		panic(fmt.Errorf("BUG: unexpected packed kind %s", vp.kind))
+	}
+	if !ok {
+		return fmt.Errorf("cannot read %s values for wireType=%s", vp.kind, fc.WireType())
+	}
+	return nil
+}
+
+// appendSliceElem appends zero element to the slice sv and returns the appended element.
+func appendSliceElem(sv reflect.Value) reflect.Value {
+	n := sv.Len()
+	if n < sv.Cap() {
+		sv.SetLen(n + 1)
+		elem := sv.Index(n)
+		elem.Set(reflect.Zero(elem.Type()))
+		return elem
+	}
+	sv.Set(reflect.Append(sv, reflect.Zero(sv.Type().Elem())))
+	return sv.Index(n)
+}
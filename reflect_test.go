@@ -0,0 +1,415 @@
+package easyproto
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type reflectSample struct {
+	Value     float64 `proto:"1"`
+	Timestamp int64   `proto:"2,sint64"`
+}
+
+type reflectLabel struct {
+	Value string        `proto:"1"`
+	Next  *reflectLabel `proto:"2"`
+}
+
+type reflectTimeseries struct {
+	Name     string                  `proto:"1"`
+	Samples  []reflectSample         `proto:"2"`
+	Labels   map[string]reflectLabel `proto:"3"`
+	IDs      []uint32                `proto:"4,packed"`
+	Deltas   []int64                 `proto:"5,sint64,packed"`
+	Status   int32                   `proto:"6,enum"`
+	Ratio    *float64                `proto:"7"`
+	Flag     *bool                   `proto:"8"`
+	Names    map[int32]string        `proto:"9"`
+	First    *reflectSample          `proto:"10"`
+	Blobs    [][]byte                `proto:"11"`
+	Tags     []string                `proto:"12"`
+	F64      uint64                  `proto:"13,fixed64"`
+	Sf32     int32                   `proto:"14,sfixed32"`
+	Float    float32                 `proto:"15"`
+	Group    reflectSample           `proto:"16,group"`
+	Counts   []int                   `proto:"17"`
+	Pointers []*reflectSample        `proto:"18"`
+	Data     []byte                  `proto:"19"`
+
+	// Fields without `proto` tag must be ignored.
+	Ignored string
+	ignored int
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	ratio := 0.25
+	flag := false
+	ts := &reflectTimeseries{
+		Name: "foo",
+		Samples: []reflectSample{
+			{Value: 1.5, Timestamp: -10},
+			{},
+		},
+		Labels: map[string]reflectLabel{
+			"a": {Value: "b", Next: &reflectLabel{Value: "c"}},
+			"":  {},
+		},
+		IDs:      []uint32{1, 2, 300},
+		Deltas:   []int64{-1, 0, 1 << 40},
+		Status:   2,
+		Ratio:    &ratio,
+		Flag:     &flag,
+		Names:    map[int32]string{-1: "minus one", 5: ""},
+		First:    &reflectSample{Value: 3},
+		Blobs:    [][]byte{[]byte("x"), []byte("yz")},
+		Tags:     []string{"t1", "", "t3"},
+		F64:      1 << 63,
+		Sf32:     -5,
+		Float:    0.5,
+		Group:    reflectSample{Timestamp: 42},
+		Counts:   []int{-1, 2},
+		Pointers: []*reflectSample{{Value: 1}, {}},
+		Data:     []byte("data"),
+	}
+	data, err := Marshal(nil, ts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Marshal must return the same result for struct value
+	data2, err := Marshal(nil, *ts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(data, data2) {
+		t.Fatalf("unexpected result for struct value\ngot\n%X\nwant\n%X", data2, data)
+	}
+
+	result := &reflectTimeseries{
+		Ignored: "foo",
+		ignored: 123,
+	}
+	for i := 0; i < 3; i++ {
+		if err := Unmarshal(data, result); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result.Ignored != "foo" || result.ignored != 123 {
+			t.Fatalf("fields without `proto` tag must remain unchanged; got %q, %d", result.Ignored, result.ignored)
+		}
+		result.Ignored = ""
+		result.ignored = 0
+		if !reflect.DeepEqual(result, ts) {
+			t.Fatalf("unexpected result\ngot\n%+v\nwant\n%+v", result, ts)
+		}
+		result.Ignored = "foo"
+		result.ignored = 123
+	}
+
+	// Unmarshaled strings and bytes must not refer to data
+	for i := range data {
+		data[i] = 0
+	}
+	if result.Name != "foo" || result.Tags[2] != "t3" || string(result.Blobs[0]) != "x" || string(result.Data) != "data" {
+		t.Fatalf("unmarshaled values must not refer to the source data")
+	}
+
+	// Unmarshal empty message
+	if err := Unmarshal(nil, result); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Name != "" || len(result.Samples) != 0 || len(result.Labels) != 0 || result.Flag != nil || result.First != nil || result.Ignored != "foo" {
+		t.Fatalf("unexpected result for empty message: %+v", result)
+	}
+}
+
+func TestMarshalCompatibility(t *testing.T) {
+	ts := &reflectTimeseries{
+		Name:    "foo",
+		Samples: []reflectSample{{Value: 1.5, Timestamp: -10}},
+		Labels:  map[string]reflectLabel{"b": {}, "a": {Value: "x"}},
+		IDs:     []uint32{1, 300},
+		Deltas:  []int64{-1},
+		Tags:    []string{""},
+		Group:   reflectSample{Value: 1},
+	}
+	data, err := Marshal(nil, ts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Build the same message with MessageMarshaler
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mmSample := mm.AppendMessage(2)
+	mmSample.AppendDouble(1, 1.5)
+	mmSample.AppendSint64(2, -10)
+	mmEntry := mm.AppendMessage(3)
+	mmEntry.AppendString(1, "a")
+	mmEntry.AppendMessage(2).AppendString(1, "x")
+	mmEntry = mm.AppendMessage(3)
+	mmEntry.AppendString(1, "b")
+	mmEntry.AppendMessage(2)
+	mm.AppendUint32s(4, []uint32{1, 300})
+	mm.AppendSint64s(5, []int64{-1})
+	mm.AppendString(12, "")
+	mm.AppendGroup(16).AppendDouble(1, 1)
+	dataExpected := m.Marshal(nil)
+	if !bytes.Equal(data, dataExpected) {
+		t.Fatalf("unexpected result\ngot\n%X\nwant\n%X", data, dataExpected)
+	}
+}
+
+func TestUnmarshalCompatibility(t *testing.T) {
+	// Marshal the message with MessageMarshaler, using non-default encodings and unknown fields.
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendUint32s(4, []uint32{1, 2})
+	mm.AppendUint32(4, 3)
+	mm.AppendSint64(5, -7)
+	mm.AppendInt32(6, 2)
+	mm.AppendBool(8, false)
+	mmEntry := mm.AppendMessage(9)
+	mmEntry.AppendString(2, "missing key")
+	mmSample := mm.AppendMessage(10)
+	mmSample.AppendDouble(1, 1.5)
+	mmSample.AppendString(100, "unknown field")
+	mm.AppendInt64s(17, []int64{1, -2})
+	mm.AppendString(100, "unknown field")
+	data := m.Marshal(nil)
+
+	var ts reflectTimeseries
+	if err := Unmarshal(data, &ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ts.Name != "foo" || !reflect.DeepEqual(ts.IDs, []uint32{1, 2, 3}) || !reflect.DeepEqual(ts.Deltas, []int64{-7}) {
+		t.Fatalf("unexpected result: %+v", &ts)
+	}
+	if ts.Status != 2 || ts.Flag == nil || *ts.Flag || ts.First == nil || ts.First.Value != 1.5 {
+		t.Fatalf("unexpected result: %+v", &ts)
+	}
+	if !reflect.DeepEqual(ts.Names, map[int32]string{0: "missing key"}) || !reflect.DeepEqual(ts.Counts, []int{1, -2}) {
+		t.Fatalf("unexpected result: %+v", &ts)
+	}
+}
+
+func TestMarshalFailure(t *testing.T) {
+	f := func(v any, errExpected string) {
+		t.Helper()
+
+		_, err := Marshal(nil, v)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("unexpected error; got %q; want it to contain %q", err, errExpected)
+		}
+	}
+
+	// unsupported values
+	f(nil, "it must be a struct or a pointer to struct")
+	f(123, "it must be a struct or a pointer to struct")
+	f((*reflectSample)(nil), "cannot marshal nil")
+
+	// invalid tags
+	f(struct {
+		A int32 `proto:"0"`
+	}{}, "invalid field number")
+	f(struct {
+		A int32 `proto:"abc"`
+	}{}, "invalid field number")
+	f(struct {
+		A int32 `proto:"1,foobar"`
+	}{}, `unknown option "foobar"`)
+	f(struct {
+		a int32 `proto:"1"`
+	}{}, "unexported fields cannot have `proto` tag")
+
+	// duplicate field numbers
+	f(struct {
+		A int32 `proto:"1"`
+		B int32 `proto:"1"`
+	}{}, "have the same number 1")
+
+	// incompatible kinds
+	f(struct {
+		A int64 `proto:"1,int32"`
+	}{}, "int32 kind cannot be used for Go type int64")
+	f(struct {
+		A int32 `proto:"1,uint32"`
+	}{}, "uint32 kind cannot be used for Go type int32")
+	f(struct {
+		A string `proto:"1,message"`
+	}{}, "message kind requires struct; got string")
+	f(struct {
+		A reflectSample `proto:"1,int32"`
+	}{}, "int32 kind cannot be used for Go type easyproto.reflectSample")
+
+	// invalid packed option
+	f(struct {
+		A int32 `proto:"1,packed"`
+	}{}, "packed option can be used only for repeated fields")
+	f(struct {
+		A []string `proto:"1,packed"`
+	}{}, "packed option can be used only for repeated fields")
+
+	// unsupported types
+	f(struct {
+		A int8 `proto:"1"`
+	}{}, "unsupported Go type int8")
+	f(struct {
+		A *[]int32 `proto:"1"`
+	}{}, "pointers to slices aren't supported")
+	f(struct {
+		A map[float64]string `proto:"1"`
+	}{}, "unsupported map key type float64")
+	f(struct {
+		A map[string]int32 `proto:"1,sint32"`
+	}{}, "map fields cannot have kind or packed options")
+
+	// errors in nested structs
+	f(struct {
+		A []struct {
+			B int8 `proto:"1"`
+		} `proto:"1"`
+	}{}, "unsupported Go type int8")
+}
+
+func TestUnmarshalFailure(t *testing.T) {
+	f := func(src []byte, v any, errExpected string) {
+		t.Helper()
+
+		err := Unmarshal(src, v)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("unexpected error; got %q; want it to contain %q", err, errExpected)
+		}
+	}
+
+	// unsupported values
+	f(nil, nil, "it must be a non-nil pointer to struct")
+	f(nil, reflectSample{}, "it must be a non-nil pointer to struct")
+	f(nil, (*reflectSample)(nil), "it must be a non-nil pointer to struct")
+
+	// invalid tags
+	f(nil, &struct {
+		A int32 `proto:"1,foobar"`
+	}{}, `unknown option "foobar"`)
+
+	// invalid message
+	f([]byte{0xff}, &reflectSample{}, "cannot read next field in easyproto.reflectSample")
+
+	// invalid wire type
+	var m Marshaler
+	m.MessageMarshaler().AppendString(1, "foo")
+	data := m.Marshal(nil)
+	f(data, &reflectSample{}, "cannot unmarshal easyproto.reflectSample.Value")
+
+	// invalid wire type in nested message
+	m.Reset()
+	m.MessageMarshaler().AppendMessage(10).AppendString(2, "foo")
+	data = m.Marshal(nil)
+	f(data, &reflectTimeseries{}, "cannot unmarshal easyproto.reflectTimeseries.First: cannot unmarshal easyproto.reflectSample.Timestamp")
+
+	// too deep nesting
+	m.Reset()
+	mm := m.MessageMarshaler()
+	for i := 0; i < 2*maxUnmarshalDepth; i++ {
+		mm = mm.AppendMessage(2)
+	}
+	data = m.Marshal(nil)
+	f(data, &reflectLabel{}, "too deep nesting of messages")
+}
+
+func TestUnmarshalMergeMessages(t *testing.T) {
+	var m Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendMessage(10).AppendDouble(1, 1.5)
+	mmGroup := mm.AppendGroup(16)
+	mmGroup.AppendDouble(1, 2.5)
+	mm.AppendMessage(10).AppendSint64(2, -10)
+	mmGroup = mm.AppendGroup(16)
+	mmGroup.AppendSint64(2, 20)
+	mmLabel := mm.AppendMapEntry(3)
+	mmLabel.AppendString(MapEntryKeyFieldNum, "foo")
+	mmLabel.AppendMessage(MapEntryValueFieldNum).AppendMessage(2).AppendString(1, "bar")
+	data := m.Marshal(nil)
+
+	ts := &reflectTimeseries{
+		First: &reflectSample{
+			Value:     123,
+			Timestamp: 456,
+		},
+		Group: reflectSample{
+			Value: 789,
+		},
+	}
+	if err := Unmarshal(data, ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	firstExpected := &reflectSample{
+		Value:     1.5,
+		Timestamp: -10,
+	}
+	if !reflect.DeepEqual(ts.First, firstExpected) {
+		t.Fatalf("unexpected First; got %+v; want %+v", ts.First, firstExpected)
+	}
+	groupExpected := reflectSample{
+		Value:     2.5,
+		Timestamp: 20,
+	}
+	if ts.Group != groupExpected {
+		t.Fatalf("unexpected Group; got %+v; want %+v", ts.Group, groupExpected)
+	}
+	labelsExpected := map[string]reflectLabel{
+		"foo": {
+			Next: &reflectLabel{
+				Value: "bar",
+			},
+		},
+	}
+	if !reflect.DeepEqual(ts.Labels, labelsExpected) {
+		t.Fatalf("unexpected Labels; got %+v; want %+v", ts.Labels, labelsExpected)
+	}
+}
+
+func TestGetStructPlanCache(t *testing.T) {
+	typ := reflect.TypeOf(reflectTimeseries{})
+	sp1, err := getStructPlan(typ)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sp2, err := getStructPlan(typ)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sp1 != sp2 {
+		t.Fatalf("the plan must be cached")
+	}
+
+	// Recursive types must refer to the same plan
+	sp, err := getStructPlan(reflect.TypeOf(reflectLabel{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sp.fields[1].value.msg != sp {
+		t.Fatalf("recursive type must refer to its own plan")
+	}
+
+	// Plans for invalid types must not be cached
+	type invalid struct {
+		S reflectSample `proto:"1"`
+		A int8          `proto:"2"`
+	}
+	if _, err := getStructPlan(reflect.TypeOf(invalid{})); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if _, ok := structPlans.Load(reflect.TypeOf(invalid{})); ok {
+		t.Fatalf("the plan for invalid type must not be cached")
+	}
+}
@@ -0,0 +1,388 @@
+package easyproto
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Kind is the protobuf type of a field.
+type Kind int
+
+// The list of supported field kinds.
+//
+// See https://protobuf.dev/programming-guides/proto3/#scalar
+const (
+	KindInt32 Kind = iota + 1
+	KindInt64
+	KindUint32
+	KindUint64
+	KindSint32
+	KindSint64
+	KindBool
+	KindEnum
+	KindFixed64
+	KindSfixed64
+	KindDouble
+	KindString
+	KindBytes
+	KindMessage
+	KindGroup
+	KindFixed32
+	KindSfixed32
+	KindFloat
+)
+
+var kindNames = [...]string{
+	KindInt32:    "int32",
+	KindInt64:    "int64",
+	KindUint32:   "uint32",
+	KindUint64:   "uint64",
+	KindSint32:   "sint32",
+	KindSint64:   "sint64",
+	KindBool:     "bool",
+	KindEnum:     "enum",
+	KindFixed64:  "fixed64",
+	KindSfixed64: "sfixed64",
+	KindDouble:   "double",
+	KindString:   "string",
+	KindBytes:    "bytes",
+	KindMessage:  "message",
+	KindGroup:    "group",
+	KindFixed32:  "fixed32",
+	KindSfixed32: "sfixed32",
+	KindFloat:    "float",
+}
+
+// String returns protobuf name for k.
+func (k Kind) String() string {
+	if !k.isValid() {
+		return fmt.Sprintf("unknown (%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// WireType returns the wire type for a single value of the given k.
+func (k Kind) WireType() WireType {
+	switch k {
+	case KindInt32, KindInt64, KindUint32, KindUint64, KindSint32, KindSint64, KindBool, KindEnum:
+		return WireTypeVarint
+	case KindFixed64, KindSfixed64, KindDouble:
+		return WireTypeI64
+	case KindFixed32, KindSfixed32, KindFloat:
+		return WireTypeI32
+	case KindGroup:
+		return WireTypeSGroup
+	default:
+		return WireTypeLen
+	}
+}
+
+// IsPackable returns true if repeated values of the given k can be packed.
+func (k Kind) IsPackable() bool {
+	return k.isValid() && k.WireType() != WireTypeLen && k != KindGroup
+}
+
+func (k Kind) isValid() bool {
+	return k > 0 && int(k) < len(kindNames)
+}
+
+// FieldSchema describes a single field in MessageSchema.
+type FieldSchema struct {
+	// Num is the field number.
+	Num uint32
+
+	// Name is the field name.
+	Name string
+
+	// Kind is the field type.
+	Kind Kind
+
+	// Repeated is set to true for repeated fields.
+	Repeated bool
+
+	// Message is the schema for KindMessage and KindGroup fields.
+	//
+	// It may be nil if the contents of the embedded message must not be validated.
+	Message *MessageSchema
+}
+
+// MessageSchema describes protobuf message.
+//
+// It must be created via NewMessageSchema() and then populated with fields via Field*() methods. For example:
+//
+//	var sampleSchema = easyproto.NewMessageSchema("Sample").
+//		Field(1, "value", easyproto.KindDouble, false).
+//		Field(2, "timestamp", easyproto.KindInt64, false)
+//
+//	var timeseriesSchema = easyproto.NewMessageSchema("Timeseries").
+//		Field(1, "name", easyproto.KindString, false).
+//		MessageField(2, "samples", sampleSchema, true)
+//
+// MessageSchema can be used for validating protobuf-encoded messages via Validate()
+// and for checking Marshaler output via Marshaler.SetSchema().
+//
+// It is safe to use MessageSchema from concurrently running goroutines after it is populated with fields.
+type MessageSchema struct {
+	// name is the message name.
+	name string
+
+	// fields contains message fields sorted by field number.
+	fields []FieldSchema
+}
+
+// NewMessageSchema returns new MessageSchema for the message with the given name.
+func NewMessageSchema(name string) *MessageSchema {
+	return &MessageSchema{
+		name: name,
+	}
+}
+
+// Name returns the message name for ms.
+func (ms *MessageSchema) Name() string {
+	return ms.name
+}
+
+// Field adds the field with the given num, name and kind to ms and returns ms.
+//
+// Use MessageField() and GroupField() for adding embedded messages with known schema.
+//
+// It panics on invalid field declaration, since this is a programming error.
+func (ms *MessageSchema) Field(num uint32, name string, kind Kind, repeated bool) *MessageSchema {
+	return ms.addField(FieldSchema{
+		Num:      num,
+		Name:     name,
+		Kind:     kind,
+		Repeated: repeated,
+	})
+}
+
+// MessageField adds the embedded message field with the given num, name and msg schema to ms and returns ms.
+//
+// It panics on invalid field declaration, since this is a programming error.
+func (ms *MessageSchema) MessageField(num uint32, name string, msg *MessageSchema, repeated bool) *MessageSchema {
+	return ms.addField(FieldSchema{
+		Num:      num,
+		Name:     name,
+		Kind:     KindMessage,
+		Repeated: repeated,
+		Message:  msg,
+	})
+}
+
+// GroupField adds proto2 group field with the given num, name and msg schema to ms and returns ms.
+//
+// It panics on invalid field declaration, since this is a programming error.
+func (ms *MessageSchema) GroupField(num uint32, name string, msg *MessageSchema, repeated bool) *MessageSchema {
+	return ms.addField(FieldSchema{
+		Num:      num,
+		Name:     name,
+		Kind:     KindGroup,
+		Repeated: repeated,
+		Message:  msg,
+	})
+}
+
+func (ms *MessageSchema) addField(f FieldSchema) *MessageSchema {
+	if f.Num == 0 || f.Num > maxFieldNum {
+		panic(fmt.Errorf("BUG: invalid field number %d for the field %s.%s; it must be in the range [1..%d]", f.Num, ms.name, f.Name, maxFieldNum))
+	}
+	if !f.Kind.isValid() {
+		panic(fmt.Errorf("BUG: invalid kind for the field %s.%s: %s", ms.name, f.Name, f.Kind))
+	}
+	if f.Message != nil && f.Kind != KindMessage && f.Kind != KindGroup {
+		panic(fmt.Errorf("BUG: message schema cannot be set for the field %s.%s of kind %s", ms.name, f.Name, f.Kind))
+	}
+	if _, ok := ms.FieldByNum(f.Num); ok {
+		panic(fmt.Errorf("BUG: duplicate field number %d for the field %s.%s", f.Num, ms.name, f.Name))
+	}
+	if _, ok := ms.FieldByName(f.Name); ok {
+		panic(fmt.Errorf("BUG: duplicate field name %s.%s", ms.name, f.Name))
+	}
+
+	n := sort.Search(len(ms.fields), func(i int) bool {
+		return ms.fields[i].Num > f.Num
+	})
+	ms.fields = append(ms.fields, FieldSchema{})
+	copy(ms.fields[n+1:], ms.fields[n:])
+	ms.fields[n] = f
+	return ms
+}
+
+// maxFieldNum is the maximum field number allowed by protobuf spec.
+const maxFieldNum = 1<<29 - 1
+
+// Fields returns fields for ms sorted by field number.
+//
+// The returned slice must not be modified.
+func (ms *MessageSchema) Fields() []FieldSchema {
+	return ms.fields
+}
+
+// FieldByNum returns the field with the given num from ms.
+//
+// False is returned if ms doesn't contain the field with the given num.
+func (ms *MessageSchema) FieldByNum(num uint32) (*FieldSchema, bool) {
+	fields := ms.fields
+	n := sort.Search(len(fields), func(i int) bool {
+		return fields[i].Num >= num
+	})
+	if n < len(fields) && fields[n].Num == num {
+		return &fields[n], true
+	}
+	return nil, false
+}
+
+// FieldByName returns the field with the given name from ms.
+//
+// False is returned if ms doesn't contain the field with the given name.
+func (ms *MessageSchema) FieldByName(name string) (*FieldSchema, bool) {
+	for i := range ms.fields {
+		if ms.fields[i].Name == name {
+			return &ms.fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// Validate verifies that protobuf-encoded message at src matches ms.
+//
+// It verifies that every known field has the wire type and the value matching its kind.
+// Repeated scalar fields may be packed or unpacked. Unknown fields are ignored according to protobuf spec.
+// Embedded messages and groups with non-nil schema are validated recursively.
+//
+// *ValidationError is returned for the first invalid field in src.
+func (ms *MessageSchema) Validate(src []byte) error {
+	sv := schemaValidator{}
+	return sv.validateMessage(ms, src, 0)
+}
+
+type schemaValidator struct {
+	// path contains field numbers for the currently validated embedded messages.
+	path []uint32
+}
+
+func (sv *schemaValidator) validateMessage(ms *MessageSchema, src []byte, baseOffset int) error {
+	var fc FieldContext
+	tail := src
+	for len(tail) > 0 {
+		fieldOffset := baseOffset + len(src) - len(tail)
+		var err error
+		tail, err = fc.NextField(tail)
+		if err != nil {
+			addErrorOffset(err, fieldOffset)
+			return sv.newError(fieldOffset, err)
+		}
+		f, ok := ms.FieldByNum(fc.FieldNum)
+		if !ok {
+			// Skip unknown field
+			continue
+		}
+		if err := validateFieldValue(f, &fc); err != nil {
+			return sv.newError(fieldOffset, fmt.Errorf("invalid field %s.%s: %w", ms.name, f.Name, err))
+		}
+		if f.Message == nil {
+			continue
+		}
+
+		var data []byte
+		if f.Kind == KindGroup {
+			data, _ = fc.GroupData()
+		} else {
+			data, _ = fc.MessageData()
+		}
+		// data is located inside fc.rawField, so its offset is obtained from their capacities.
+		// This works for non-minimal varints in field tags and lengths.
+		dataOffset := fieldOffset + cap(fc.rawField) - cap(data)
+
+		sv.path = append(sv.path, fc.FieldNum)
+		err = sv.validateMessage(f.Message, data, dataOffset)
+		sv.path = sv.path[:len(sv.path)-1]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sv *schemaValidator) newError(offset int, err error) error {
+	return &ValidationError{
+		Offset: offset,
+		Path:   append([]uint32{}, sv.path...),
+		Err:    err,
+	}
+}
+
+// validateFieldValue verifies that fc contains valid value for f.
+func validateFieldValue(f *FieldSchema, fc *FieldContext) error {
+	wt := f.Kind.WireType()
+	if fc.wireType != wt && !(f.Repeated && f.Kind.IsPackable() && fc.wireType == WireTypeLen) {
+		return &WireTypeError{
+			FieldNum: fc.FieldNum,
+			Got:      fc.wireType,
+			Want:     wt,
+		}
+	}
+
+	ok := true
+	switch f.Kind {
+	case KindInt32, KindEnum:
+		ok = fc.RangeInt32s(skipInt32)
+	case KindInt64:
+		ok = fc.RangeInt64s(skipInt64)
+	case KindUint32:
+		ok = fc.RangeUint32s(skipUint32)
+	case KindUint64:
+		ok = fc.RangeUint64s(skipUint64)
+	case KindSint32:
+		ok = fc.RangeSint32s(skipInt32)
+	case KindSint64:
+		ok = fc.RangeSint64s(skipInt64)
+	case KindBool:
+		ok = fc.RangeBools(skipBool)
+	case KindFixed64:
+		ok = fc.RangeFixed64s(skipUint64)
+	case KindSfixed64:
+		ok = fc.RangeSfixed64s(skipInt64)
+	case KindDouble:
+		ok = fc.RangeDoubles(skipFloat64)
+	case KindFixed32:
+		ok = fc.RangeFixed32s(skipUint32)
+	case KindSfixed32:
+		ok = fc.RangeSfixed32s(skipInt32)
+	case KindFloat:
+		ok = fc.RangeFloats(skipFloat32)
+	}
+	if !ok {
+		return fmt.Errorf("cannot read %s value", f.Kind)
+	}
+	return nil
+}
+
+func skipInt32(_ int32) bool     { return true }
+func skipInt64(_ int64) bool     { return true }
+func skipUint32(_ uint32) bool   { return true }
+func skipUint64(_ uint64) bool   { return true }
+func skipBool(_ bool) bool       { return true }
+func skipFloat64(_ float64) bool { return true }
+func skipFloat32(_ float32) bool { return true }
+
+// SetSchema sets the schema for the message constructed at m.
+//
+// The marshaled message is validated against ms in debug builds, which are built with easyproto_debug build tag,
+// e.g. go test -tags=easyproto_debug. Marshal* functions panic on validation errors in debug builds.
+// This allows catching invalid field numbers and types in tests without any overhead in production builds.
+//
+// The schema is reset by Reset().
+func (m *Marshaler) SetSchema(ms *MessageSchema) {
+	m.schema = ms
+}
+
+// checkSchema panics if data doesn't match m.schema.
+func (m *Marshaler) checkSchema(data []byte) {
+	if m.schema == nil {
+		return
+	}
+	if err := m.schema.Validate(data); err != nil {
+		panic(fmt.Errorf("BUG: the marshaled message doesn't match the schema for %s: %w", m.schema.name, err))
+	}
+}
@@ -0,0 +1,52 @@
+//go:build easyproto_debug
+
+package easyproto
+
+import (
+	"testing"
+)
+
+func TestMarshalDebugSchemaCheck(t *testing.T) {
+	var m Marshaler
+	m.SetSchema(testTimeseriesSchema)
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendInt32(4, 1)
+	_ = m.Marshal(nil)
+
+	mm.AppendInt32(1, 123)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("expecting panic for the message, which doesn't match the schema")
+		}
+	}()
+	_ = m.Marshal(nil)
+}
+
+func TestFieldDebugInvalidFieldNum(t *testing.T) {
+	f := func(appendField func(mm *MessageMarshaler)) {
+		t.Helper()
+
+		var m Marshaler
+		defer func() {
+			t.Helper()
+			if r := recover(); r == nil {
+				t.Fatalf("expecting panic for invalid field number")
+			}
+		}()
+		appendField(m.MessageMarshaler())
+	}
+
+	f(func(mm *MessageMarshaler) {
+		DoubleField(0).Append(mm, 1.5)
+	})
+	f(func(mm *MessageMarshaler) {
+		StringField(maxFieldNum+1).Append(mm, "foo")
+	})
+	f(func(mm *MessageMarshaler) {
+		Int64sField(0).Append(mm, []int64{1})
+	})
+	f(func(mm *MessageMarshaler) {
+		MessageField(1 << 30).Append(mm)
+	})
+}
@@ -0,0 +1,8 @@
+//go:build !easyproto_debug
+
+package easyproto
+
+// isDebugBuild is set to true when building with easyproto_debug build tag.
+//
+// See Marshaler.SetSchema for details.
+const isDebugBuild = false
@@ -0,0 +1,254 @@
+package easyproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var testSampleSchema = NewMessageSchema("Sample").
+	Field(1, "value", KindDouble, false).
+	Field(2, "timestamp", KindInt64, false)
+
+var testTimeseriesSchema = NewMessageSchema("Timeseries").
+	Field(1, "name", KindString, false).
+	MessageField(2, "samples", testSampleSchema, true).
+	Field(3, "ids", KindUint32, true).
+	Field(4, "flag", KindBool, false).
+	Field(5, "status", KindEnum, false).
+	GroupField(6, "group", NewMessageSchema("Group").Field(1, "x", KindSint32, false), false).
+	Field(7, "raw", KindMessage, false)
+
+func TestKindString(t *testing.T) {
+	f := func(k Kind, sExpected string, wtExpected WireType) {
+		t.Helper()
+
+		if s := k.String(); s != sExpected {
+			t.Fatalf("unexpected string; got %q; want %q", s, sExpected)
+		}
+		if wt := k.WireType(); wt != wtExpected {
+			t.Fatalf("unexpected wireType; got %s; want %s", wt, wtExpected)
+		}
+	}
+
+	f(KindInt32, "int32", WireTypeVarint)
+	f(KindSint64, "sint64", WireTypeVarint)
+	f(KindEnum, "enum", WireTypeVarint)
+	f(KindDouble, "double", WireTypeI64)
+	f(KindString, "string", WireTypeLen)
+	f(KindMessage, "message", WireTypeLen)
+	f(KindGroup, "group", WireTypeSGroup)
+	f(KindFloat, "float", WireTypeI32)
+	f(Kind(0), "unknown (0)", WireTypeLen)
+	f(Kind(100), "unknown (100)", WireTypeLen)
+}
+
+func TestMessageSchemaFields(t *testing.T) {
+	ms := NewMessageSchema("Foo").
+		Field(3, "c", KindString, false).
+		Field(1, "a", KindInt32, false).
+		Field(2, "b", KindBytes, true)
+
+	if name := ms.Name(); name != "Foo" {
+		t.Fatalf("unexpected name; got %q; want %q", name, "Foo")
+	}
+	var nums []uint32
+	for _, f := range ms.Fields() {
+		nums = append(nums, f.Num)
+	}
+	if !reflect.DeepEqual(nums, []uint32{1, 2, 3}) {
+		t.Fatalf("unexpected field numbers; got %v; want [1 2 3]", nums)
+	}
+
+	f, ok := ms.FieldByNum(2)
+	if !ok || f.Name != "b" || f.Kind != KindBytes || !f.Repeated {
+		t.Fatalf("unexpected field #2: %+v", f)
+	}
+	f, ok = ms.FieldByName("c")
+	if !ok || f.Num != 3 {
+		t.Fatalf("unexpected field c: %+v", f)
+	}
+	if _, ok := ms.FieldByNum(4); ok {
+		t.Fatalf("unexpected field #4")
+	}
+	if _, ok := ms.FieldByName("d"); ok {
+		t.Fatalf("unexpected field d")
+	}
+}
+
+func TestMessageSchemaInvalidField(t *testing.T) {
+	f := func(addField func(ms *MessageSchema)) {
+		t.Helper()
+
+		defer func() {
+			t.Helper()
+			if r := recover(); r == nil {
+				t.Fatalf("expecting panic")
+			}
+		}()
+		ms := NewMessageSchema("Foo").Field(1, "a", KindInt32, false)
+		addField(ms)
+	}
+
+	// zero field number
+	f(func(ms *MessageSchema) {
+		ms.Field(0, "b", KindInt32, false)
+	})
+
+	// too big field number
+	f(func(ms *MessageSchema) {
+		ms.Field(1<<29, "b", KindInt32, false)
+	})
+
+	// invalid kind
+	f(func(ms *MessageSchema) {
+		ms.Field(2, "b", Kind(0), false)
+	})
+
+	// duplicate field number
+	f(func(ms *MessageSchema) {
+		ms.Field(1, "b", KindInt32, false)
+	})
+
+	// duplicate field name
+	f(func(ms *MessageSchema) {
+		ms.Field(2, "a", KindInt32, false)
+	})
+}
+
+func TestMessageSchemaValidateSuccess(t *testing.T) {
+	f := func(appendFields func(mm *MessageMarshaler)) {
+		t.Helper()
+
+		var m Marshaler
+		appendFields(m.MessageMarshaler())
+		data := m.Marshal(nil)
+		if err := testTimeseriesSchema.Validate(data); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// empty message
+	f(func(mm *MessageMarshaler) {})
+
+	// all the fields
+	f(func(mm *MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mmSample := mm.AppendMessage(2)
+		mmSample.AppendDouble(1, 1.5)
+		mmSample.AppendInt64(2, -123)
+		mmSample = mm.AppendMessage(2)
+		mmSample.AppendDouble(1, 2.5)
+		mm.AppendUint32s(3, []uint32{1, 2, 3})
+		mm.AppendUint32(3, 4)
+		mm.AppendBool(4, true)
+		mm.AppendInt32(5, -1)
+		mmGroup := mm.AppendGroup(6)
+		mmGroup.AppendSint32(1, -10)
+		mmRaw := mm.AppendMessage(7)
+		mmRaw.AppendString(100, "anything")
+	})
+
+	// unknown fields
+	f(func(mm *MessageMarshaler) {
+		mm.AppendString(100, "foo")
+		mm.AppendFixed32(101, 123)
+		mmSample := mm.AppendMessage(2)
+		mmSample.AppendString(100, "bar")
+	})
+}
+
+func TestMessageSchemaValidateFailure(t *testing.T) {
+	f := func(appendFields func(mm *MessageMarshaler), offsetExpected int, pathExpected []uint32) {
+		t.Helper()
+
+		var m Marshaler
+		appendFields(m.MessageMarshaler())
+		data := m.Marshal(nil)
+		err := testTimeseriesSchema.Validate(data)
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Fatalf("expecting *ValidationError; got %v", err)
+		}
+		if ve.Offset != offsetExpected {
+			t.Fatalf("unexpected offset; got %d; want %d", ve.Offset, offsetExpected)
+		}
+		if !reflect.DeepEqual(ve.Path, pathExpected) {
+			t.Fatalf("unexpected path; got %v; want %v", ve.Path, pathExpected)
+		}
+	}
+
+	// wrong wire type
+	f(func(mm *MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mm.AppendString(4, "bar")
+	}, 5, []uint32{})
+
+	// packed non-repeated field
+	f(func(mm *MessageMarshaler) {
+		mm.AppendBools(4, []bool{true})
+	}, 0, []uint32{})
+
+	// invalid bool value
+	f(func(mm *MessageMarshaler) {
+		mm.AppendUint64(4, 2)
+	}, 0, []uint32{})
+
+	// uint32 overflow in packed field
+	f(func(mm *MessageMarshaler) {
+		mm.AppendUint64s(3, []uint64{1, 1 << 40})
+	}, 0, []uint32{})
+
+	// wrong wire type in embedded message
+	f(func(mm *MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mmSample := mm.AppendMessage(2)
+		mmSample.AppendDouble(1, 1.5)
+		mmSample.AppendString(2, "bar")
+	}, 16, []uint32{2})
+
+	// wrong wire type in group
+	f(func(mm *MessageMarshaler) {
+		mmGroup := mm.AppendGroup(6)
+		mmGroup.AppendFixed32(1, 10)
+	}, 1, []uint32{6})
+
+	// embedded message, which cannot be parsed
+	f(func(mm *MessageMarshaler) {
+		mm.AppendBytes(2, []byte{0x08})
+	}, 2, []uint32{2})
+
+	// wrong wire type in group with non-minimal end group tag
+	data := []byte{0x33, 0x0d, 10, 0, 0, 0, 0xb4, 0x00}
+	err := testTimeseriesSchema.Validate(data)
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expecting *ValidationError; got %v", err)
+	}
+	if ve.Offset != 1 {
+		t.Fatalf("unexpected offset; got %d; want 1", ve.Offset)
+	}
+}
+
+func TestMarshalerCheckSchema(t *testing.T) {
+	var m Marshaler
+	m.SetSchema(testTimeseriesSchema)
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	data := m.Marshal(nil)
+
+	// Valid message mustn't panic
+	m.checkSchema(data)
+
+	// Marshal invalid message without schema, since Marshal panics on invalid messages in debug builds.
+	var mInvalid Marshaler
+	mm = mInvalid.MessageMarshaler()
+	mm.AppendString(4, "bar")
+	data = mInvalid.Marshal(nil)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("expecting panic for invalid message")
+		}
+	}()
+	m.checkSchema(data)
+}
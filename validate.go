@@ -0,0 +1,149 @@
+package easyproto
+
+import (
+	"fmt"
+)
+
+// ValidateOptions contains options for Validate.
+//
+// Zero values for limits mean there is no limit except of MaxDepth.
+type ValidateOptions struct {
+	// MaxSize is the maximum size of the validated message in bytes.
+	MaxSize int
+
+	// MaxDepth is the maximum nesting depth for embedded messages and groups.
+	//
+	// The top-level message has depth 1. The depth is limited by 100 if MaxDepth isn't set,
+	// since unbounded nesting may result in stack overflow when validating specially crafted messages.
+	MaxDepth int
+
+	// MaxFieldCount is the maximum number of fields in the validated message including fields in embedded messages and groups.
+	MaxFieldCount int
+
+	// Recursive enables validation of length-delimited fields, which can be parsed as embedded messages.
+	//
+	// The length-delimited fields, which cannot be parsed as messages, are treated as strings or bytes.
+	// Groups are always validated recursively, since their contents must be valid messages.
+	Recursive bool
+}
+
+// defaultValidateMaxDepth is the default value for ValidateOptions.MaxDepth.
+const defaultValidateMaxDepth = 100
+
+// ValidationError is returned by Validate for invalid messages.
+type ValidationError struct {
+	// Offset is the byte offset of the invalid field in the validated message.
+	Offset int
+
+	// Path contains field numbers for embedded messages and groups, which contain the invalid field.
+	//
+	// It is empty if the invalid field is located at the top-level message.
+	Path []uint32
+
+	// Err is the error for the invalid field.
+	Err error
+}
+
+// Error implements error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid field at offset %d, path %s: %s", e.Offset, formatPath(e.Path), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validate verifies that src contains well-formed protobuf message according to opts.
+//
+// It verifies that every field tag, varint and length is well-formed without the need in message schema.
+// *ValidationError is returned for the first problem found in src.
+func Validate(src []byte, opts ValidateOptions) error {
+	if opts.MaxSize > 0 && len(src) > opts.MaxSize {
+		return &ValidationError{
+			Err: fmt.Errorf("message size %d bytes exceeds %d bytes", len(src), opts.MaxSize),
+		}
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = defaultValidateMaxDepth
+	}
+	v := validator{
+		opts: opts,
+	}
+	return v.validateMessage(src, 0)
+}
+
+type validator struct {
+	// opts contains validation options.
+	opts ValidateOptions
+
+	// fieldCount is the number of fields seen so far.
+	fieldCount int
+
+	// path contains field numbers for the currently validated embedded messages.
+	path []uint32
+}
+
+func (v *validator) validateMessage(src []byte, baseOffset int) error {
+	if maxDepth := v.opts.MaxDepth; len(v.path) >= maxDepth {
+		return v.newError(baseOffset, fmt.Errorf("message nesting depth exceeds %d", maxDepth))
+	}
+
+	var fc FieldContext
+	tail := src
+	for len(tail) > 0 {
+		fieldOffset := baseOffset + len(src) - len(tail)
+		var err error
+		tail, err = fc.NextField(tail)
+		if err != nil {
+			addErrorOffset(err, fieldOffset)
+			return v.newError(fieldOffset, err)
+		}
+		v.fieldCount++
+		if maxFieldCount := v.opts.MaxFieldCount; maxFieldCount > 0 && v.fieldCount > maxFieldCount {
+			return v.newError(fieldOffset, fmt.Errorf("the number of fields exceeds %d", maxFieldCount))
+		}
+
+		// fc.data is located inside fc.rawField, so its offset is obtained from their capacities.
+		// This works for non-minimal varints in field tags and lengths.
+		dataOffset := fieldOffset + cap(fc.rawField) - cap(fc.data)
+		switch fc.wireType {
+		case WireTypeSGroup:
+			if err := v.validateEmbedded(fc.FieldNum, fc.data, dataOffset); err != nil {
+				return err
+			}
+		case WireTypeLen:
+			if !v.opts.Recursive || !isMessage(fc.data) {
+				continue
+			}
+			if err := v.validateEmbedded(fc.FieldNum, fc.data, dataOffset); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateEmbedded(fieldNum uint32, data []byte, dataOffset int) error {
+	v.path = append(v.path, fieldNum)
+	err := v.validateMessage(data, dataOffset)
+	v.path = v.path[:len(v.path)-1]
+	return err
+}
+
+func (v *validator) newError(offset int, err error) error {
+	return &ValidationError{
+		Offset: offset,
+		Path:   append([]uint32{}, v.path...),
+		Err:    err,
+	}
+}
+
+// isMessage returns true if data can be parsed as protobuf message.
+//
+// It uses scanFields instead of FieldContext.NextField, since the latter allocates memory on errors,
+// while the majority of strings and bytes cannot be parsed as messages.
+func isMessage(data []byte) bool {
+	_, _, se := scanFields(data, 0, 0, false)
+	return se.kind == nil
+}
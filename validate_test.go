@@ -0,0 +1,159 @@
+package easyproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateSuccess(t *testing.T) {
+	f := func(data []byte, opts ValidateOptions) {
+		t.Helper()
+		if err := Validate(data, opts); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	data := marshalPathTestMessage()
+
+	f(nil, ValidateOptions{})
+	f(data, ValidateOptions{})
+	f(data, ValidateOptions{
+		Recursive: true,
+	})
+	f(data, ValidateOptions{
+		MaxSize:       len(data),
+		MaxDepth:      3,
+		MaxFieldCount: 14,
+		Recursive:     true,
+	})
+
+	// Nested messages aren't counted without Recursive option
+	f(data, ValidateOptions{
+		MaxDepth:      1,
+		MaxFieldCount: 3,
+	})
+
+	// Strings, which cannot be parsed as messages, must be accepted
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo bar")
+	mm.AppendBytes(2, []byte{0xff, 0xff})
+	data = m.Marshal(nil)
+	mp.Put(m)
+	f(data, ValidateOptions{
+		MaxDepth:  1,
+		Recursive: true,
+	})
+}
+
+func TestValidateFailure(t *testing.T) {
+	f := func(data []byte, opts ValidateOptions, offsetExpected int, pathExpected []uint32) {
+		t.Helper()
+
+		err := Validate(data, opts)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Fatalf("expecting ValidationError; got %T: %s", err, err)
+		}
+		if ve.Offset != offsetExpected {
+			t.Fatalf("unexpected offset; got %d; want %d; err: %s", ve.Offset, offsetExpected, err)
+		}
+		if len(ve.Path) == 0 && len(pathExpected) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(ve.Path, pathExpected) {
+			t.Fatalf("unexpected path; got %v; want %v; err: %s", ve.Path, pathExpected, err)
+		}
+	}
+
+	data := marshalPathTestMessage()
+
+	// too big message
+	f(data, ValidateOptions{
+		MaxSize: len(data) - 1,
+	}, 0, nil)
+
+	// too deep nesting
+	f(data, ValidateOptions{
+		MaxDepth:  2,
+		Recursive: true,
+	}, 4, []uint32{1, 3})
+
+	// too many fields
+	f(data, ValidateOptions{
+		MaxFieldCount: 2,
+		Recursive:     true,
+	}, 4, []uint32{1, 3})
+
+	// truncated top-level field
+	f([]byte{1 << 3, 0x01, 2<<3 | byte(WireTypeI64), 0x01}, ValidateOptions{}, 2, nil)
+
+	// invalid field inside group
+	f([]byte{1 << 3, 0x01, 2<<3 | byte(WireTypeSGroup), 3 << 3, 0xff, 2<<3 | byte(WireTypeEGroup)}, ValidateOptions{}, 2, nil)
+
+	// too deep groups
+	f([]byte{1<<3 | byte(WireTypeSGroup), 2<<3 | byte(WireTypeSGroup), 2<<3 | byte(WireTypeEGroup), 1<<3 | byte(WireTypeEGroup)}, ValidateOptions{
+		MaxDepth: 2,
+	}, 2, []uint32{1, 2})
+
+	// too many fields inside nested message
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(1, 1)
+	msg := mm.AppendMessage(2)
+	msg.AppendInt64(3, 1)
+	msg.AppendInt64(4, 1)
+	data = m.Marshal(nil)
+	mp.Put(m)
+	f(data, ValidateOptions{
+		MaxFieldCount: 3,
+		Recursive:     true,
+	}, 6, []uint32{2})
+
+	// too many fields inside group with non-minimal start group tag
+	f([]byte{0x8b, 0x00, 1 << 3, 0x01, 1<<3 | byte(WireTypeEGroup)}, ValidateOptions{
+		MaxFieldCount: 1,
+	}, 2, []uint32{1})
+
+	// too deep nesting without MaxDepth
+	data = []byte{1 << 3, 0x01}
+	for i := 0; i < 2*defaultValidateMaxDepth; i++ {
+		m := mp.Get()
+		m.MessageMarshaler().AppendBytes(1, data)
+		data = m.Marshal(nil)
+		mp.Put(m)
+	}
+	err := Validate(data, ValidateOptions{
+		Recursive: true,
+	})
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expecting ValidationError; got %v", err)
+	}
+	if len(ve.Path) != defaultValidateMaxDepth {
+		t.Fatalf("unexpected path length; got %d; want %d", len(ve.Path), defaultValidateMaxDepth)
+	}
+}
+
+func TestValidateNonMessageZeroAlloc(t *testing.T) {
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo bar baz")
+	mm.AppendBytes(2, []byte{0xff, 0xff})
+	mm.AppendInt64(3, 123)
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := Validate(data, ValidateOptions{Recursive: true}); err != nil {
+			panic(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("unexpected allocations; got %v; want 0", allocs)
+	}
+}
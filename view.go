@@ -0,0 +1,107 @@
+package easyproto
+
+import (
+	"unsafe"
+)
+
+// Fixed64sView returns fixed64 values from fc.
+//
+// The returned slice refers to the underlying buffer if it is properly aligned and the platform is little-endian.
+// Otherwise the values are copied into a newly allocated slice. The returned slice mustn't be modified,
+// and it is valid while the underlying buffer isn't changed.
+//
+// False is returned if fc doesn't contain fixed64 values.
+func (fc *FieldContext) Fixed64sView() ([]uint64, bool) {
+	return packedView(fc, (*FieldContext).UnpackFixed64s)
+}
+
+// Sfixed64sView returns sfixed64 values from fc.
+//
+// The returned slice refers to the underlying buffer if it is properly aligned and the platform is little-endian.
+// Otherwise the values are copied into a newly allocated slice. The returned slice mustn't be modified,
+// and it is valid while the underlying buffer isn't changed.
+//
+// False is returned if fc doesn't contain sfixed64 values.
+func (fc *FieldContext) Sfixed64sView() ([]int64, bool) {
+	return packedView(fc, (*FieldContext).UnpackSfixed64s)
+}
+
+// DoublesView returns double values from fc.
+//
+// The returned slice refers to the underlying buffer if it is properly aligned and the platform is little-endian.
+// Otherwise the values are copied into a newly allocated slice. The returned slice mustn't be modified,
+// and it is valid while the underlying buffer isn't changed.
+//
+// False is returned if fc doesn't contain double values.
+func (fc *FieldContext) DoublesView() ([]float64, bool) {
+	return packedView(fc, (*FieldContext).UnpackDoubles)
+}
+
+// Fixed32sView returns fixed32 values from fc.
+//
+// The returned slice refers to the underlying buffer if it is properly aligned and the platform is little-endian.
+// Otherwise the values are copied into a newly allocated slice. The returned slice mustn't be modified,
+// and it is valid while the underlying buffer isn't changed.
+//
+// False is returned if fc doesn't contain fixed32 values.
+func (fc *FieldContext) Fixed32sView() ([]uint32, bool) {
+	return packedView(fc, (*FieldContext).UnpackFixed32s)
+}
+
+// Sfixed32sView returns sfixed32 values from fc.
+//
+// The returned slice refers to the underlying buffer if it is properly aligned and the platform is little-endian.
+// Otherwise the values are copied into a newly allocated slice. The returned slice mustn't be modified,
+// and it is valid while the underlying buffer isn't changed.
+//
+// False is returned if fc doesn't contain sfixed32 values.
+func (fc *FieldContext) Sfixed32sView() ([]int32, bool) {
+	return packedView(fc, (*FieldContext).UnpackSfixed32s)
+}
+
+// FloatsView returns float values from fc.
+//
+// The returned slice refers to the underlying buffer if it is properly aligned and the platform is little-endian.
+// Otherwise the values are copied into a newly allocated slice. The returned slice mustn't be modified,
+// and it is valid while the underlying buffer isn't changed.
+//
+// False is returned if fc doesn't contain float values.
+func (fc *FieldContext) FloatsView() ([]float32, bool) {
+	return packedView(fc, (*FieldContext).UnpackFloats)
+}
+
+func packedView[T uint64 | int64 | float64 | uint32 | int32 | float32](fc *FieldContext, unpackFunc func(fc *FieldContext, dst []T) ([]T, bool)) ([]T, bool) {
+	if fc.wireType == WireTypeLen {
+		if a, ok := unsafeBytesToSlice[T](fc.data); ok {
+			return a, true
+		}
+	}
+	// Fall back to copying the values.
+	return unpackFunc(fc, nil)
+}
+
+// unsafeBytesToSlice returns a slice of T items, which refers to b.
+//
+// False is returned if b cannot be converted to the slice without copying
+// because of improper alignment or big-endian platform.
+func unsafeBytesToSlice[T uint64 | int64 | float64 | uint32 | int32 | float32](b []byte) ([]T, bool) {
+	var zero T
+	itemSize := int(unsafe.Sizeof(zero))
+	if !isLittleEndian || len(b)%itemSize != 0 {
+		return nil, false
+	}
+	if len(b) == 0 {
+		return nil, true
+	}
+	p := unsafe.Pointer(&b[0])
+	if uintptr(p)%unsafe.Alignof(zero) != 0 {
+		return nil, false
+	}
+	return unsafe.Slice((*T)(p), len(b)/itemSize), true
+}
+
+// isLittleEndian is set to true on little-endian platforms, which have the same byte order as protobuf fixed-width values.
+var isLittleEndian = func() bool {
+	n := uint16(1)
+	return *(*byte)(unsafe.Pointer(&n)) == 1
+}()
@@ -0,0 +1,141 @@
+package easyproto
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestPackedView(t *testing.T) {
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendFixed64s(1, []uint64{0, 1, 1<<64 - 1})
+	mm.AppendSfixed64s(2, []int64{0, -1, 1<<63 - 1, -1 << 63})
+	mm.AppendDoubles(3, []float64{0, -1.5, 1e100})
+	mm.AppendFixed32s(4, []uint32{0, 1, 1<<32 - 1})
+	mm.AppendSfixed32s(5, []int32{0, -1, 1<<31 - 1, -1 << 31})
+	mm.AppendFloats(6, []float32{0, -1.5, 1e10})
+	mm.AppendDoubles(7, nil)
+	mm.AppendDouble(8, 1.5)
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	var fc FieldContext
+	for len(data) > 0 {
+		var err error
+		data, err = fc.NextField(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			checkPackedView(t, &fc, fc.Fixed64sView, fc.UnpackFixed64s)
+		case 2:
+			checkPackedView(t, &fc, fc.Sfixed64sView, fc.UnpackSfixed64s)
+		case 3, 7, 8:
+			checkPackedView(t, &fc, fc.DoublesView, fc.UnpackDoubles)
+		case 4:
+			checkPackedView(t, &fc, fc.Fixed32sView, fc.UnpackFixed32s)
+		case 5:
+			checkPackedView(t, &fc, fc.Sfixed32sView, fc.UnpackSfixed32s)
+		case 6:
+			checkPackedView(t, &fc, fc.FloatsView, fc.UnpackFloats)
+		default:
+			t.Fatalf("unexpected fieldNum=%d", fc.FieldNum)
+		}
+	}
+}
+
+func checkPackedView[T any](t *testing.T, fc *FieldContext, viewFunc func() ([]T, bool), unpackFunc func(dst []T) ([]T, bool)) {
+	t.Helper()
+
+	valuesExpected, ok := unpackFunc(nil)
+	if !ok {
+		t.Fatalf("cannot unpack values for fieldNum=%d", fc.FieldNum)
+	}
+	values, ok := viewFunc()
+	if !ok {
+		t.Fatalf("cannot obtain view for fieldNum=%d", fc.FieldNum)
+	}
+	if len(values) != len(valuesExpected) || (len(values) > 0 && !reflect.DeepEqual(values, valuesExpected)) {
+		t.Fatalf("unexpected values for fieldNum=%d; got %v; want %v", fc.FieldNum, values, valuesExpected)
+	}
+}
+
+func TestPackedViewAliasing(t *testing.T) {
+	if !isLittleEndian {
+		t.Skip("zero-copy views are supported only on little-endian platforms")
+	}
+
+	// Allocate 8-byte aligned buffer
+	buf := make([]uint64, 5)
+	b := unsafe.Slice((*byte)(unsafe.Pointer(&buf[0])), len(buf)*8)
+
+	f := func(data []byte, isAliasExpected bool) {
+		t.Helper()
+
+		fc := FieldContext{
+			wireType: WireTypeLen,
+			data:     data,
+		}
+		values, ok := fc.DoublesView()
+		if !ok {
+			t.Fatalf("cannot obtain doubles view")
+		}
+		if len(values) != len(data)/8 {
+			t.Fatalf("unexpected number of values; got %d; want %d", len(values), len(data)/8)
+		}
+		isAlias := unsafe.Pointer(&values[0]) == unsafe.Pointer(&data[0])
+		if isAlias != isAliasExpected {
+			t.Fatalf("unexpected aliasing; got %v; want %v", isAlias, isAliasExpected)
+		}
+		valuesExpected, _ := fc.UnpackDoubles(nil)
+		if !reflect.DeepEqual(values, valuesExpected) {
+			t.Fatalf("unexpected values; got %v; want %v", values, valuesExpected)
+		}
+	}
+
+	// aligned data must be returned without copying
+	f(b[:32], true)
+	f(b[8:40], true)
+
+	// unaligned data must be copied
+	f(b[1:33], false)
+	f(b[4:36], false)
+}
+
+func TestPackedViewFailure(t *testing.T) {
+	f := func(fc *FieldContext) {
+		t.Helper()
+
+		if _, ok := fc.Fixed64sView(); ok {
+			t.Fatalf("expecting Fixed64sView failure")
+		}
+		if _, ok := fc.Sfixed64sView(); ok {
+			t.Fatalf("expecting Sfixed64sView failure")
+		}
+		if _, ok := fc.DoublesView(); ok {
+			t.Fatalf("expecting DoublesView failure")
+		}
+		if _, ok := fc.Fixed32sView(); ok {
+			t.Fatalf("expecting Fixed32sView failure")
+		}
+		if _, ok := fc.Sfixed32sView(); ok {
+			t.Fatalf("expecting Sfixed32sView failure")
+		}
+		if _, ok := fc.FloatsView(); ok {
+			t.Fatalf("expecting FloatsView failure")
+		}
+	}
+
+	// wrong wire type
+	f(&FieldContext{
+		wireType: WireTypeVarint,
+	})
+
+	// invalid data length
+	f(&FieldContext{
+		wireType: WireTypeLen,
+		data:     make([]byte, 7),
+	})
+}
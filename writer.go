@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"math"
 	"math/bits"
+	"sort"
 	"sync"
 )
 
@@ -50,6 +51,15 @@ type Marshaler struct {
 
 	// mms contains MessageMarshaler structs for the currently marshaled message.
 	mms []MessageMarshaler
+
+	// deterministic is set to true if fields must be marshaled in the ascending order of field numbers.
+	deterministic bool
+
+	// fsSorter is used for sorting fields in deterministic mode.
+	fsSorter fieldsSorter
+
+	// schema is the schema for the marshaled message. It is checked in debug builds only.
+	schema *MessageSchema
 }
 
 // MessageMarshaler helps constructing protobuf message for marshaling.
@@ -69,6 +79,11 @@ type MessageMarshaler struct {
 	lastFieldIdx int
 }
 
+// isGroup returns true if mm has been obtained via AppendGroup().
+func (mm *MessageMarshaler) isGroup() bool {
+	return WireType(mm.tag&0x07) == WireTypeSGroup
+}
+
 func (mm *MessageMarshaler) reset() {
 	mm.m = nil
 	mm.tag = 0
@@ -77,6 +92,11 @@ func (mm *MessageMarshaler) reset() {
 }
 
 type field struct {
+	// fieldNum is the protobuf field number for the given field.
+	//
+	// It is used for sorting fields in deterministic mode.
+	fieldNum uint32
+
 	// messageSize is the size of marshaled protobuf message for the given field.
 	messageSize uint64
 
@@ -94,6 +114,7 @@ type field struct {
 }
 
 func (f *field) reset() {
+	f.fieldNum = 0
 	f.messageSize = 0
 	f.dataStart = 0
 	f.dataEnd = 0
@@ -111,6 +132,22 @@ func (m *Marshaler) Reset() {
 
 	// There is no need in resetting individual MessageMarshaler items, since they are reset in newMessageMarshalerIndex()
 	m.mms = m.mms[:0]
+
+	m.deterministic = false
+	m.fsSorter.reset()
+	m.schema = nil
+}
+
+// SetDeterministic enables or disables deterministic mode for m.
+//
+// In deterministic mode fields of every message are marshaled in the ascending order of field numbers,
+// while repeated fields with the same field number are marshaled in the order they were appended.
+// Map entries appended via AppendMap() and AppendStringStringMap() are sorted by keys.
+// So semantically equal messages are marshaled to the same bytes regardless of the order of Append* calls.
+//
+// Deterministic mode must be set before appending fields to m. It is disabled by Reset().
+func (m *Marshaler) SetDeterministic(deterministic bool) {
+	m.deterministic = deterministic
 }
 
 // MarshalWithLen marshals m, appends its length together with the marshaled m to dst and returns the result.
@@ -124,6 +161,9 @@ func (m *Marshaler) MarshalWithLen(dst []byte) []byte {
 		dst = marshalVarUint64(dst, 0)
 		return dst
 	}
+	if m.deterministic {
+		m.sortFields()
+	}
 	if firstFieldIdx := m.mm.firstFieldIdx; firstFieldIdx >= 0 {
 		f := &m.fs[firstFieldIdx]
 		messageSize := f.initMessageSize(m)
@@ -132,7 +172,14 @@ func (m *Marshaler) MarshalWithLen(dst []byte) []byte {
 			dst = dst[:0]
 		}
 		dst = marshalVarUint64(dst, messageSize)
+		dstLen := len(dst)
 		dst = f.marshal(dst, m)
+		if isDebugBuild {
+			m.checkSchema(dst[dstLen:])
+		}
+	} else {
+		// Empty message
+		dst = marshalVarUint64(dst, 0)
 	}
 	return dst
 }
@@ -147,6 +194,9 @@ func (m *Marshaler) Marshal(dst []byte) []byte {
 		// Nothing to marshal
 		return dst
 	}
+	if m.deterministic {
+		m.sortFields()
+	}
 	if firstFieldIdx := m.mm.firstFieldIdx; firstFieldIdx >= 0 {
 		f := &m.fs[firstFieldIdx]
 		messageSize := f.initMessageSize(m)
@@ -154,11 +204,85 @@ func (m *Marshaler) Marshal(dst []byte) []byte {
 			dst = make([]byte, messageSize)
 			dst = dst[:0]
 		}
+		dstLen := len(dst)
 		dst = f.marshal(dst, m)
+		if isDebugBuild {
+			m.checkSchema(dst[dstLen:])
+		}
 	}
 	return dst
 }
 
+// sortFields sorts fields of every MessageMarshaler at m by field numbers.
+func (m *Marshaler) sortFields() {
+	m.mm.sortFields()
+	for i := range m.mms {
+		mm := &m.mms[i]
+		if mm.m == nil || mm == m.mm {
+			continue
+		}
+		mm.sortFields()
+	}
+}
+
+// sortFields stably sorts mm fields by field numbers.
+func (mm *MessageMarshaler) sortFields() {
+	m := mm.m
+	fs := &m.fsSorter
+	fs.fs = m.fs
+	fs.idxs = fs.idxs[:0]
+	isSorted := true
+	for idx := mm.firstFieldIdx; idx >= 0; idx = m.fs[idx].nextFieldIdx {
+		if n := len(fs.idxs); n > 0 && m.fs[fs.idxs[n-1]].fieldNum > m.fs[idx].fieldNum {
+			isSorted = false
+		}
+		fs.idxs = append(fs.idxs, idx)
+	}
+	if isSorted {
+		// Fast path - fields are already sorted.
+		return
+	}
+
+	sort.Stable(fs)
+
+	idxs := fs.idxs
+	mm.firstFieldIdx = idxs[0]
+	for i := 0; i < len(idxs)-1; i++ {
+		m.fs[idxs[i]].nextFieldIdx = idxs[i+1]
+	}
+	lastFieldIdx := idxs[len(idxs)-1]
+	m.fs[lastFieldIdx].nextFieldIdx = -1
+	mm.lastFieldIdx = lastFieldIdx
+}
+
+// fieldsSorter implements sort.Interface for sorting field indexes by field numbers.
+type fieldsSorter struct {
+	// fs contains fields referred by idxs.
+	fs []field
+
+	// idxs contains indexes of the sorted fields in fs.
+	idxs []int
+}
+
+func (fs *fieldsSorter) reset() {
+	// remove reference to fields, so GC could release them.
+	fs.fs = nil
+
+	fs.idxs = fs.idxs[:0]
+}
+
+func (fs *fieldsSorter) Len() int {
+	return len(fs.idxs)
+}
+
+func (fs *fieldsSorter) Less(i, j int) bool {
+	return fs.fs[fs.idxs[i]].fieldNum < fs.fs[fs.idxs[j]].fieldNum
+}
+
+func (fs *fieldsSorter) Swap(i, j int) {
+	fs.idxs[i], fs.idxs[j] = fs.idxs[j], fs.idxs[i]
+}
+
 // MessageMarshaler returns message marshaler for the given m.
 func (m *Marshaler) MessageMarshaler() *MessageMarshaler {
 	if mm := m.mm; mm != nil {
@@ -199,8 +323,11 @@ func (m *Marshaler) newFieldIndex() int {
 }
 
 // AppendInt32 appends the given int32 value under the given fieldNum to mm.
+//
+// Negative values are sign-extended to 64 bits and occupy 10 bytes according to protobuf spec.
+// Use AppendSint32 for fields, which frequently contain negative values.
 func (mm *MessageMarshaler) AppendInt32(fieldNum uint32, i32 int32) {
-	mm.AppendUint64(fieldNum, uint64(uint32(i32)))
+	mm.AppendUint64(fieldNum, uint64(int64(i32)))
 }
 
 // AppendInt64 appends the given int64 value under the given fieldNum to mm.
@@ -215,7 +342,7 @@ func (mm *MessageMarshaler) AppendUint32(fieldNum, u32 uint32) {
 
 // AppendUint64 appends the given uint64 value under the given fieldNum to mm.
 func (mm *MessageMarshaler) AppendUint64(fieldNum uint32, u64 uint64) {
-	tag := makeTag(fieldNum, wireTypeVarint)
+	tag := makeTag(fieldNum, WireTypeVarint)
 
 	m := mm.m
 	dst := m.buf
@@ -228,7 +355,7 @@ func (mm *MessageMarshaler) AppendUint64(fieldNum uint32, u64 uint64) {
 	dst = marshalVarUint64(dst, u64)
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, fieldNum, dstLen, len(dst))
 }
 
 // AppendSint32 appends the given sint32 value under the given fieldNum to mm.
@@ -254,7 +381,7 @@ func (mm *MessageMarshaler) AppendBool(fieldNum uint32, v bool) {
 
 // AppendFixed64 appends fixed64 value under the given fieldNum to mm.
 func (mm *MessageMarshaler) AppendFixed64(fieldNum uint32, u64 uint64) {
-	tag := makeTag(fieldNum, wireTypeI64)
+	tag := makeTag(fieldNum, WireTypeI64)
 
 	m := mm.m
 	dst := m.buf
@@ -267,7 +394,7 @@ func (mm *MessageMarshaler) AppendFixed64(fieldNum uint32, u64 uint64) {
 	dst = marshalUint64(dst, u64)
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, fieldNum, dstLen, len(dst))
 }
 
 // AppendSfixed64 appends sfixed64 value under the given fieldNum to mm.
@@ -283,7 +410,7 @@ func (mm *MessageMarshaler) AppendDouble(fieldNum uint32, f float64) {
 
 // AppendString appends string value under the given fieldNum to mm.
 func (mm *MessageMarshaler) AppendString(fieldNum uint32, s string) {
-	tag := makeTag(fieldNum, wireTypeLen)
+	tag := makeTag(fieldNum, WireTypeLen)
 
 	m := mm.m
 	dst := m.buf
@@ -298,7 +425,7 @@ func (mm *MessageMarshaler) AppendString(fieldNum uint32, s string) {
 	dst = append(dst, s...)
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, fieldNum, dstLen, len(dst))
 }
 
 // AppendBytes appends bytes value under the given fieldNum to mm.
@@ -307,13 +434,90 @@ func (mm *MessageMarshaler) AppendBytes(fieldNum uint32, b []byte) {
 	mm.AppendString(fieldNum, s)
 }
 
+// AppendRawField appends protobuf-encoded field including its tag to mm.
+//
+// The field is written verbatim, so it must be properly encoded. For example, it may be obtained via FieldContext.RawField().
+// This allows preserving unknown fields when re-encoding the message.
+func (mm *MessageMarshaler) AppendRawField(rawField []byte) {
+	m := mm.m
+	dst := m.buf
+	dstLen := len(dst)
+	dst = append(dst, rawField...)
+	m.buf = dst
+
+	mm.appendField(m, rawFieldNum(rawField), dstLen, len(dst))
+}
+
 // AppendMessage appends protobuf message with the given fieldNum to m.
 //
 // The function returns the MessageMarshaler for constructing the appended message.
 func (mm *MessageMarshaler) AppendMessage(fieldNum uint32) *MessageMarshaler {
-	tag := makeTag(fieldNum, wireTypeLen)
+	tag := makeTag(fieldNum, WireTypeLen)
+
+	f := mm.newField()
+	f.fieldNum = fieldNum
+	m := mm.m
+	f.childMessageMarshalerIdx = m.newMessageMarshalerIndex()
+	mmChild := &m.mms[f.childMessageMarshalerIdx]
+	mmChild.tag = tag
+	return mmChild
+}
+
+// AppendMessageBytes appends protobuf-encoded message data under the given fieldNum to mm.
+//
+// This is useful for embedding already marshaled messages, e.g. cached or received from upstream,
+// without the need to re-construct them via AppendMessage().
+//
+// data is copied to mm together with the field tag and the data length,
+// so it is treated as a regular field by the Marshaler. Marshaler.Marshal() doesn't calculate the size of data
+// and doesn't traverse its fields, since the size is already known at the time of the call.
+//
+// data isn't validated, so it must contain properly encoded message. Use AppendMessageBytesValidated() for untrusted data.
+func (mm *MessageMarshaler) AppendMessageBytes(fieldNum uint32, data []byte) {
+	tag := makeTag(fieldNum, WireTypeLen)
+
+	m := mm.m
+	dst := m.buf
+	dstLen := len(dst)
+	dataLen := len(data)
+	if tag < 0x80 && dataLen < 0x80 {
+		dst = append(dst, byte(tag), byte(dataLen))
+	} else {
+		dst = marshalVarUint64(dst, tag)
+		dst = marshalVarUint64(dst, uint64(dataLen))
+	}
+	dst = append(dst, data...)
+	m.buf = dst
+
+	mm.appendField(m, fieldNum, dstLen, len(dst))
+}
+
+// AppendMessageBytesValidated validates protobuf-encoded message data with Validate() according to opts
+// and then appends it under the given fieldNum to mm.
+//
+// data isn't appended to mm if it is invalid. The returned error is *ValidationError in this case.
+//
+// See also AppendMessageBytes().
+func (mm *MessageMarshaler) AppendMessageBytesValidated(fieldNum uint32, data []byte, opts ValidateOptions) error {
+	if err := Validate(data, opts); err != nil {
+		return err
+	}
+	mm.AppendMessageBytes(fieldNum, data)
+	return nil
+}
+
+// AppendGroup appends proto2 group with the given fieldNum to mm.
+//
+// The function returns the MessageMarshaler for constructing the appended group.
+// The group fields are delimited by start group and end group tags instead of length prefix.
+// This encoding is also used by protobuf editions for message fields with DELIMITED message_encoding feature.
+//
+// The appended group can be read via FieldContext.GroupData().
+func (mm *MessageMarshaler) AppendGroup(fieldNum uint32) *MessageMarshaler {
+	tag := makeTag(fieldNum, WireTypeSGroup)
 
 	f := mm.newField()
+	f.fieldNum = fieldNum
 	m := mm.m
 	f.childMessageMarshalerIdx = m.newMessageMarshalerIndex()
 	mmChild := &m.mms[f.childMessageMarshalerIdx]
@@ -323,7 +527,7 @@ func (mm *MessageMarshaler) AppendMessage(fieldNum uint32) *MessageMarshaler {
 
 // AppendFixed32 appends fixed32 value under the given fieldNum to mm.
 func (mm *MessageMarshaler) AppendFixed32(fieldNum, u32 uint32) {
-	tag := makeTag(fieldNum, wireTypeI32)
+	tag := makeTag(fieldNum, WireTypeI32)
 
 	m := mm.m
 	dst := m.buf
@@ -336,7 +540,7 @@ func (mm *MessageMarshaler) AppendFixed32(fieldNum, u32 uint32) {
 	dst = marshalUint32(dst, u32)
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, fieldNum, dstLen, len(dst))
 }
 
 // AppendSfixed32 appends sfixed32 value under the given fieldNum to mm.
@@ -433,11 +637,11 @@ func (mm *MessageMarshaler) appendInt32s(i32s []int32) {
 	dst := m.buf
 	dstLen := len(dst)
 	for _, i32 := range i32s {
-		dst = marshalVarUint64(dst, uint64(uint32(i32)))
+		dst = marshalVarUint64(dst, uint64(int64(i32)))
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendUint32s(u32s []uint32) {
@@ -449,7 +653,7 @@ func (mm *MessageMarshaler) appendUint32s(u32s []uint32) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendSint32s(i32s []int32) {
@@ -462,7 +666,7 @@ func (mm *MessageMarshaler) appendSint32s(i32s []int32) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendInt64s(i64s []int64) {
@@ -474,7 +678,7 @@ func (mm *MessageMarshaler) appendInt64s(i64s []int64) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendUint64s(u64s []uint64) {
@@ -486,7 +690,7 @@ func (mm *MessageMarshaler) appendUint64s(u64s []uint64) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendSint64s(i64s []int64) {
@@ -499,7 +703,7 @@ func (mm *MessageMarshaler) appendSint64s(i64s []int64) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendBools(bs []bool) {
@@ -515,7 +719,7 @@ func (mm *MessageMarshaler) appendBools(bs []bool) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendFixed64s(u64s []uint64) {
@@ -527,7 +731,7 @@ func (mm *MessageMarshaler) appendFixed64s(u64s []uint64) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendSfixed64s(i64s []int64) {
@@ -539,7 +743,7 @@ func (mm *MessageMarshaler) appendSfixed64s(i64s []int64) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendFixed32s(u32s []uint32) {
@@ -551,7 +755,7 @@ func (mm *MessageMarshaler) appendFixed32s(u32s []uint32) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendSfixed32s(i32s []int32) {
@@ -563,7 +767,7 @@ func (mm *MessageMarshaler) appendSfixed32s(i32s []int32) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendDoubles(fs []float64) {
@@ -576,7 +780,7 @@ func (mm *MessageMarshaler) appendDoubles(fs []float64) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
 func (mm *MessageMarshaler) appendFloats(fs []float32) {
@@ -589,21 +793,35 @@ func (mm *MessageMarshaler) appendFloats(fs []float32) {
 	}
 	m.buf = dst
 
-	mm.appendField(m, dstLen, len(dst))
+	mm.appendField(m, 0, dstLen, len(dst))
 }
 
-func (mm *MessageMarshaler) appendField(m *Marshaler, dataStart, dataEnd int) {
+// appendField appends the field with the given fieldNum and data at m.buf[dataStart:dataEnd] to mm.
+//
+// fieldNum must be 0 for packed data, since it is appended to the MessageMarshaler obtained for packed field.
+func (mm *MessageMarshaler) appendField(m *Marshaler, fieldNum uint32, dataStart, dataEnd int) {
 	if lastFieldIdx := mm.lastFieldIdx; lastFieldIdx >= 0 {
 		if f := &m.fs[lastFieldIdx]; f.childMessageMarshalerIdx == -1 && f.dataEnd == dataStart {
-			f.dataEnd = dataEnd
-			return
+			// Deterministic mode requires that every field contains data for a single fieldNum,
+			// so fields could be sorted by fieldNum before marshaling.
+			if !m.deterministic || f.fieldNum == fieldNum {
+				f.dataEnd = dataEnd
+				return
+			}
 		}
 	}
 	f := mm.newField()
+	f.fieldNum = fieldNum
 	f.dataStart = dataStart
 	f.dataEnd = dataEnd
 }
 
+// rawFieldNum returns fieldNum for the protobuf-encoded field at rawField.
+func rawFieldNum(rawField []byte) uint32 {
+	tag, _ := binary.Uvarint(rawField)
+	return uint32(tag >> 3)
+}
+
 func (mm *MessageMarshaler) newField() *field {
 	m := mm.m
 	idx := m.newFieldIndex()
@@ -624,17 +842,20 @@ func (f *field) initMessageSize(m *Marshaler) uint64 {
 			n += uint64(f.dataEnd - f.dataStart)
 		} else {
 			mmChild := m.mms[childMessageMarshalerIdx]
-			if tag := mmChild.tag; tag < 0x80 {
-				n++
-			} else {
-				n += varuintLen(tag)
+			tagLen := uint64(1)
+			if tag := mmChild.tag; tag >= 0x80 {
+				tagLen = varuintLen(tag)
 			}
+			n += tagLen
 			messageSize := uint64(0)
 			if firstFieldIdx := mmChild.firstFieldIdx; firstFieldIdx >= 0 {
 				messageSize = m.fs[firstFieldIdx].initMessageSize(m)
 			}
 			n += messageSize
-			if messageSize < 0x80 {
+			if mmChild.isGroup() {
+				// The end group tag has the same length as the start group tag.
+				n += tagLen
+			} else if messageSize < 0x80 {
 				n++
 			} else {
 				n += varuintLen(messageSize)
@@ -657,15 +878,23 @@ func (f *field) marshal(dst []byte, m *Marshaler) []byte {
 		} else {
 			mmChild := m.mms[childMessageMarshalerIdx]
 			tag := mmChild.tag
-			messageSize := f.messageSize
-			if tag < 0x80 && messageSize < 0x80 {
-				dst = append(dst, byte(tag), byte(messageSize))
+			if mmChild.isGroup() {
+				dst = marshalVarUint64(dst, tag)
+				if firstFieldIdx := mmChild.firstFieldIdx; firstFieldIdx >= 0 {
+					dst = m.fs[firstFieldIdx].marshal(dst, m)
+				}
+				dst = marshalVarUint64(dst, endGroupTag(tag))
 			} else {
-				dst = marshalVarUint64(dst, mmChild.tag)
-				dst = marshalVarUint64(dst, f.messageSize)
-			}
-			if firstFieldIdx := mmChild.firstFieldIdx; firstFieldIdx >= 0 {
-				dst = m.fs[firstFieldIdx].marshal(dst, m)
+				messageSize := f.messageSize
+				if tag < 0x80 && messageSize < 0x80 {
+					dst = append(dst, byte(tag), byte(messageSize))
+				} else {
+					dst = marshalVarUint64(dst, mmChild.tag)
+					dst = marshalVarUint64(dst, f.messageSize)
+				}
+				if firstFieldIdx := mmChild.firstFieldIdx; firstFieldIdx >= 0 {
+					dst = m.fs[firstFieldIdx].marshal(dst, m)
+				}
 			}
 		}
 		nextFieldIdx := f.nextFieldIdx
@@ -706,10 +935,15 @@ func encodeZigZagInt32(i32 int32) uint32 {
 	return uint32((i32 << 1) ^ (i32 >> 31))
 }
 
-func makeTag(fieldNum uint32, wt wireType) uint64 {
+func makeTag(fieldNum uint32, wt WireType) uint64 {
 	return (uint64(fieldNum) << 3) | uint64(wt)
 }
 
+// endGroupTag returns the end group tag for the given start group tag.
+func endGroupTag(startGroupTag uint64) uint64 {
+	return (startGroupTag &^ 0x07) | uint64(WireTypeEGroup)
+}
+
 // varuintLen returns the number of bytes needed for varuint-encoding of u64.
 //
 // Note that it returns 0 for u64=0, so this case must be handled separately.